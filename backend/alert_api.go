@@ -0,0 +1,166 @@
+package main
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 获取告警列表
+func getAlerts(c *gin.Context) {
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	pageSize, _ := strconv.Atoi(c.DefaultQuery("page_size", "20"))
+	if page < 1 {
+		page = 1
+	}
+	if pageSize < 1 || pageSize > 100 {
+		pageSize = 20
+	}
+
+	query := db.Model(&Alert{})
+	if status := c.Query("status"); status != "" {
+		query = query.Where("status = ?", status)
+	}
+	if severity := c.Query("severity"); severity != "" {
+		query = query.Where("severity = ?", severity)
+	}
+	if rule := c.Query("rule"); rule != "" {
+		query = query.Where("rule = ?", rule)
+	}
+	if userID := c.Query("user_id"); userID != "" {
+		query = query.Where("user_id = ?", userID)
+	}
+	if startDate := c.Query("start_date"); startDate != "" {
+		query = query.Where("last_seen_at >= ?", startDate)
+	}
+	if endDate := c.Query("end_date"); endDate != "" {
+		query = query.Where("last_seen_at <= ?", endDate)
+	}
+
+	var total int64
+	query.Count(&total)
+
+	var alerts []Alert
+	offset := (page - 1) * pageSize
+	result := query.Order("last_seen_at DESC, id DESC").Limit(pageSize).Offset(offset).Find(&alerts)
+	if result.Error != nil {
+		errorResponse(c, 500, "获取告警列表失败")
+		return
+	}
+
+	successResponse(c, gin.H{
+		"alerts":    alerts,
+		"total":     total,
+		"page":      page,
+		"page_size": pageSize,
+	})
+}
+
+// 获取告警详情
+func getAlertById(c *gin.Context) {
+	var alert Alert
+	if err := db.First(&alert, c.Param("id")).Error; err != nil {
+		errorResponse(c, 404, "告警不存在")
+		return
+	}
+	successResponse(c, alert)
+}
+
+// 更新告警状态（确认或解决）
+func handleAlert(status string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req struct {
+			Note string `json:"note"`
+		}
+		c.ShouldBindJSON(&req)
+
+		var alert Alert
+		if err := db.First(&alert, c.Param("id")).Error; err != nil {
+			errorResponse(c, 404, "告警不存在")
+			return
+		}
+		if alert.Status == AlertStatusResolved {
+			errorResponse(c, 400, "告警已解决")
+			return
+		}
+
+		now := time.Now()
+		updates := map[string]interface{}{
+			"status":     status,
+			"handled_by": c.GetUint("user_id"),
+			"handled_at": &now,
+		}
+		if req.Note != "" {
+			updates["handle_note"] = req.Note
+		}
+		if err := auditDB(c).Model(&alert).Updates(updates).Error; err != nil {
+			errorResponse(c, 500, "更新告警失败")
+			return
+		}
+
+		db.First(&alert, alert.ID)
+		successResponse(c, alert)
+	}
+}
+
+// 获取告警统计
+func getAlertStats(c *gin.Context) {
+	var stats struct {
+		Total         int64                    `json:"total"`
+		Open          int64                    `json:"open"`
+		Acknowledged  int64                    `json:"acknowledged"`
+		TodayAlerts   int64                    `json:"today_alerts"`
+		SeverityStats []map[string]interface{} `json:"severity_stats"`
+		RuleStats     []map[string]interface{} `json:"rule_stats"`
+	}
+
+	db.Model(&Alert{}).Count(&stats.Total)
+	db.Model(&Alert{}).Where("status = ?", AlertStatusOpen).Count(&stats.Open)
+	db.Model(&Alert{}).Where("status = ?", AlertStatusAcknowledged).Count(&stats.Acknowledged)
+	db.Model(&Alert{}).Where("DATE(created_at) = DATE('now')").Count(&stats.TodayAlerts)
+
+	// 未解决告警按级别统计
+	db.Model(&Alert{}).
+		Select("severity, COUNT(*) as count").
+		Where("status <> ?", AlertStatusResolved).
+		Group("severity").
+		Scan(&stats.SeverityStats)
+
+	// 近7天按规则统计
+	db.Model(&Alert{}).
+		Select("rule, COUNT(*) as count").
+		Where("created_at >= DATE('now', '-7 day')").
+		Group("rule").
+		Order("count DESC").
+		Scan(&stats.RuleStats)
+
+	successResponse(c, stats)
+}
+
+// 获取当前生效的异常检测规则
+func getAnomalyRulesAPI(c *gin.Context) {
+	rules, err := getAnomalyRules()
+	if err != nil {
+		errorResponse(c, 500, err.Error())
+		return
+	}
+	successResponse(c, gin.H{
+		"rules":               rules,
+		"notify_min_severity": getConfigValue("alert_notify_min_severity", AlertSeverityHigh),
+		"email_enabled":       getConfigValue("alert_email_to", "") != "",
+		"webhook_enabled":     getConfigValue("alert_webhook_url", "") != "",
+	})
+}
+
+// 立即执行一次异常检测
+func runAnomalyDetectionAPI(c *gin.Context) {
+	created, err := runAnomalyDetection(c.Request.Context())
+	if err != nil {
+		errorResponse(c, 500, "异常检测失败: "+err.Error())
+		return
+	}
+	successResponse(c, gin.H{
+		"created": created,
+	})
+}
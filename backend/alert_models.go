@@ -0,0 +1,574 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/smtp"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 告警级别
+const (
+	AlertSeverityLow      = "low"
+	AlertSeverityMedium   = "medium"
+	AlertSeverityHigh     = "high"
+	AlertSeverityCritical = "critical"
+)
+
+// 告警状态
+const (
+	AlertStatusOpen         = "open"
+	AlertStatusAcknowledged = "acknowledged"
+	AlertStatusResolved     = "resolved"
+)
+
+// 异常检测规则类型
+const (
+	AnomalyForbiddenBurst    = "forbidden_burst"     // 同一用户短时间内大量403
+	AnomalyAdminNewIP        = "admin_new_ip"        // 管理员从新IP登录
+	AnomalyMassDelete        = "mass_delete"         // 同一用户短时间内大量删除
+	AnomalyNightConfigChange = "night_config_change" // 夜间修改指定分类的系统配置
+)
+
+// 安全告警
+type Alert struct {
+	ID          uint       `json:"id" gorm:"primaryKey"`
+	Rule        string     `json:"rule" gorm:"not null;index"`        // 触发的规则名称
+	Type        string     `json:"type" gorm:"not null"`              // 规则类型
+	Severity    string     `json:"severity" gorm:"not null;index"`    // 级别：low, medium, high, critical
+	Status      string     `json:"status" gorm:"default:open;index"`  // 状态：open, acknowledged, resolved
+	Title       string     `json:"title" gorm:"not null"`             // 标题
+	Message     string     `json:"message" gorm:"type:text"`          // 说明
+	Fingerprint string     `json:"fingerprint" gorm:"not null;index"` // 去重键：同一规则和对象在窗口期内只产生一条告警
+	UserID      uint       `json:"user_id" gorm:"index"`              // 相关用户
+	Username    string     `json:"username"`
+	IP          string     `json:"ip"`
+	Count       int        `json:"count"`                     // 触发次数（窗口期内的事件数）
+	Evidence    string     `json:"evidence" gorm:"type:text"` // 相关的操作日志/认证事件ID（JSON）
+	FirstSeenAt time.Time  `json:"first_seen_at"`             // 首次发现时间
+	LastSeenAt  time.Time  `json:"last_seen_at" gorm:"index"` // 最近发现时间
+	NotifiedAt  *time.Time `json:"notified_at"`               // 通知发送时间
+	HandledBy   uint       `json:"handled_by"`                // 处理人
+	HandledAt   *time.Time `json:"handled_at"`                // 处理时间
+	HandleNote  string     `json:"handle_note"`               // 处理说明
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+}
+
+// 异常检测规则（阈值通过系统配置 anomaly_rules 调整）
+type AnomalyRule struct {
+	Name          string   `json:"name"`           // 规则名称
+	Type          string   `json:"type"`           // 规则类型
+	Disabled      bool     `json:"disabled"`       // 是否停用
+	Severity      string   `json:"severity"`       // 告警级别
+	Threshold     int      `json:"threshold"`      // 窗口期内的事件数阈值
+	WindowMinutes int      `json:"window_minutes"` // 统计窗口（分钟）
+	LookbackDays  int      `json:"lookback_days"`  // admin_new_ip：判断新IP时回溯的天数
+	Actions       []string `json:"actions"`        // mass_delete：计为删除的操作类型
+	Category      string   `json:"category"`       // night_config_change：配置分类
+	NightStart    int      `json:"night_start"`    // night_config_change：夜间开始（时）
+	NightEnd      int      `json:"night_end"`      // night_config_change：夜间结束（时）
+}
+
+// 默认异常检测规则
+const defaultAnomalyRules = `[
+  {"name":"403_burst","type":"forbidden_burst","severity":"medium","threshold":10,"window_minutes":5},
+  {"name":"admin_new_ip","type":"admin_new_ip","severity":"high","lookback_days":30,"window_minutes":60},
+  {"name":"mass_delete","type":"mass_delete","severity":"high","threshold":20,"window_minutes":10,"actions":["delete","purge","batch_delete","bulk_delete"]},
+  {"name":"night_security_config","type":"night_config_change","severity":"high","category":"security","night_start":22,"night_end":6,"window_minutes":60}
+]`
+
+// 告警级别排序
+var alertSeverityRank = map[string]int{
+	AlertSeverityLow:      1,
+	AlertSeverityMedium:   2,
+	AlertSeverityHigh:     3,
+	AlertSeverityCritical: 4,
+}
+
+// 初始化告警
+func initAlertSystem() error {
+	// 自动迁移数据库
+	return db.AutoMigrate(&Alert{})
+}
+
+// 获取异常检测规则
+func getAnomalyRules() ([]AnomalyRule, error) {
+	var rules []AnomalyRule
+	if err := configCache.GetJSON("anomaly_rules", defaultAnomalyRules, &rules); err != nil {
+		return nil, fmt.Errorf("invalid anomaly_rules: %w", err)
+	}
+	for i := range rules {
+		if err := normalizeAnomalyRule(&rules[i]); err != nil {
+			return nil, err
+		}
+	}
+	return rules, nil
+}
+
+// 校验规则并补全默认值
+func normalizeAnomalyRule(rule *AnomalyRule) error {
+	switch rule.Type {
+	case AnomalyForbiddenBurst, AnomalyAdminNewIP, AnomalyMassDelete, AnomalyNightConfigChange:
+	default:
+		return fmt.Errorf("unknown anomaly rule type %q", rule.Type)
+	}
+	if rule.Name == "" {
+		rule.Name = rule.Type
+	}
+	if _, ok := alertSeverityRank[rule.Severity]; !ok {
+		rule.Severity = AlertSeverityMedium
+	}
+	if rule.Threshold <= 0 {
+		rule.Threshold = 1
+	}
+	if rule.WindowMinutes <= 0 {
+		rule.WindowMinutes = 60
+	}
+	if rule.LookbackDays <= 0 {
+		rule.LookbackDays = 30
+	}
+	if len(rule.Actions) == 0 {
+		rule.Actions = []string{"delete", "purge", "batch_delete", "bulk_delete"}
+	}
+	if rule.Category == "" {
+		rule.Category = "security"
+	}
+	if rule.NightStart < 0 || rule.NightStart > 23 || rule.NightEnd < 0 || rule.NightEnd > 23 {
+		return fmt.Errorf("anomaly rule %q: night hours must be between 0 and 23", rule.Name)
+	}
+	return nil
+}
+
+// 检测到的异常
+type anomalyFinding struct {
+	Fingerprint string
+	Title       string
+	Message     string
+	UserID      uint
+	Username    string
+	IP          string
+	Count       int
+	Evidence    []uint
+	SeenAt      time.Time
+}
+
+// 执行一次异常检测，返回新产生的告警数
+func runAnomalyDetection(ctx context.Context) (int, error) {
+	rules, err := getAnomalyRules()
+	if err != nil {
+		return 0, err
+	}
+
+	now := time.Now()
+	created := 0
+	for _, rule := range rules {
+		if rule.Disabled {
+			continue
+		}
+		if err := ctx.Err(); err != nil {
+			return created, err
+		}
+
+		var findings []anomalyFinding
+		since := now.Add(-time.Duration(rule.WindowMinutes) * time.Minute)
+		switch rule.Type {
+		case AnomalyForbiddenBurst:
+			findings, err = detectForbiddenBurst(rule, since)
+		case AnomalyAdminNewIP:
+			findings, err = detectAdminNewIP(rule, since)
+		case AnomalyMassDelete:
+			findings, err = detectMassDelete(rule, since)
+		case AnomalyNightConfigChange:
+			findings, err = detectNightConfigChange(rule, since)
+		}
+		if err != nil {
+			return created, fmt.Errorf("anomaly rule %q: %w", rule.Name, err)
+		}
+
+		for _, finding := range findings {
+			isNew, err := raiseAlert(rule, finding)
+			if err != nil {
+				return created, err
+			}
+			if isNew {
+				created++
+			}
+		}
+	}
+	return created, nil
+}
+
+// 同一用户在窗口期内的403次数达到阈值
+func detectForbiddenBurst(rule AnomalyRule, since time.Time) ([]anomalyFinding, error) {
+	var rows []struct {
+		UserID   uint
+		Username string
+		Count    int
+	}
+	err := db.Model(&OperationLog{}).
+		Select("user_id, MAX(username) AS username, COUNT(*) AS count").
+		Where("status = ? AND created_at >= ? AND archived = ?", 403, since, false).
+		Group("user_id").
+		Having("COUNT(*) >= ?", rule.Threshold).
+		Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+
+	findings := make([]anomalyFinding, 0, len(rows))
+	for _, row := range rows {
+		findings = append(findings, anomalyFinding{
+			Fingerprint: fmt.Sprintf("%s:user:%d", rule.Name, row.UserID),
+			Title:       fmt.Sprintf("用户 %s 频繁访问无权限的接口", row.Username),
+			Message:     fmt.Sprintf("%d 分钟内被拒绝访问 %d 次（阈值 %d）", rule.WindowMinutes, row.Count, rule.Threshold),
+			UserID:      row.UserID,
+			Username:    row.Username,
+			Count:       row.Count,
+			Evidence:    recentLogIDs(since, "status = ? AND user_id = ?", 403, row.UserID),
+			SeenAt:      time.Now(),
+		})
+	}
+	return findings, nil
+}
+
+// 批量操作的日志按实际删除的条数计数（详情中的 success），其他日志每条计1次
+const massDeleteCountExpr = "SUM(CASE WHEN json_valid(details) AND json_type(details, '$.success') = 'integer' " +
+	"THEN MAX(json_extract(details, '$.success'), 1) ELSE 1 END)"
+
+// 同一用户在窗口期内删除的数据条数达到阈值
+func detectMassDelete(rule AnomalyRule, since time.Time) ([]anomalyFinding, error) {
+	var rows []struct {
+		UserID   uint
+		Username string
+		Count    int
+	}
+	err := db.Model(&OperationLog{}).
+		Select("user_id, MAX(username) AS username, "+massDeleteCountExpr+" AS count").
+		Where("action IN ? AND status < ? AND created_at >= ? AND archived = ?", rule.Actions, 400, since, false).
+		Group("user_id").
+		Having(massDeleteCountExpr+" >= ?", rule.Threshold).
+		Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+
+	findings := make([]anomalyFinding, 0, len(rows))
+	for _, row := range rows {
+		findings = append(findings, anomalyFinding{
+			Fingerprint: fmt.Sprintf("%s:user:%d", rule.Name, row.UserID),
+			Title:       fmt.Sprintf("用户 %s 短时间内大量删除数据", row.Username),
+			Message:     fmt.Sprintf("%d 分钟内删除数据 %d 条（阈值 %d）", rule.WindowMinutes, row.Count, rule.Threshold),
+			UserID:      row.UserID,
+			Username:    row.Username,
+			Count:       row.Count,
+			Evidence:    recentLogIDs(since, "action IN ? AND status < ? AND user_id = ?", rule.Actions, 400, row.UserID),
+			SeenAt:      time.Now(),
+		})
+	}
+	return findings, nil
+}
+
+// 管理员角色（与 role_api 中受保护的系统角色一致）
+var adminRoleNames = []string{"admin", "super_admin"}
+
+// 是否为管理员：用户的 role 字段，或直接分配、通过用户组继承的管理员角色
+func isAdminUser(userID uint) bool {
+	var user User
+	if err := db.Select("id", "role").First(&user, userID).Error; err != nil {
+		return false
+	}
+	if user.Role == "admin" {
+		return true
+	}
+
+	roleIDs := getUserRoleIDs(userID)
+	if len(roleIDs) == 0 {
+		return false
+	}
+	var count int64
+	db.Model(&Role{}).Where("id IN ? AND name IN ?", roleIDs, adminRoleNames).Count(&count)
+	return count > 0
+}
+
+// 管理员从回溯期内未使用过的IP登录成功
+func detectAdminNewIP(rule AnomalyRule, since time.Time) ([]anomalyFinding, error) {
+	var events []AuthEvent
+	err := db.Model(&AuthEvent{}).
+		Where("event = ? AND success = ? AND created_at >= ?", AuthEventLogin, true, since).
+		Order("id").
+		Find(&events).Error
+	if err != nil {
+		return nil, err
+	}
+
+	var findings []anomalyFinding
+	admins := make(map[uint]bool)
+	for _, event := range events {
+		isAdmin, ok := admins[event.UserID]
+		if !ok {
+			isAdmin = isAdminUser(event.UserID)
+			admins[event.UserID] = isAdmin
+		}
+		if !isAdmin {
+			continue
+		}
+
+		var seen int64
+		db.Model(&AuthEvent{}).
+			Where("event = ? AND success = ? AND user_id = ? AND ip = ? AND id < ?", AuthEventLogin, true, event.UserID, event.IP, event.ID).
+			Where("created_at >= ?", event.CreatedAt.AddDate(0, 0, -rule.LookbackDays)).
+			Count(&seen)
+		if seen > 0 {
+			continue
+		}
+
+		// 没有任何历史登录记录时（首次登录）不告警
+		var history int64
+		db.Model(&AuthEvent{}).
+			Where("event = ? AND success = ? AND user_id = ? AND id < ?", AuthEventLogin, true, event.UserID, event.ID).
+			Count(&history)
+		if history == 0 {
+			continue
+		}
+
+		findings = append(findings, anomalyFinding{
+			Fingerprint: fmt.Sprintf("%s:user:%d:ip:%s", rule.Name, event.UserID, event.IP),
+			Title:       fmt.Sprintf("管理员 %s 从新的IP登录", event.Username),
+			Message:     fmt.Sprintf("IP %s（%s）在过去 %d 天内未用于登录该账户", event.IP, event.GeoHint, rule.LookbackDays),
+			UserID:      event.UserID,
+			Username:    event.Username,
+			IP:          event.IP,
+			Count:       1,
+			Evidence:    []uint{event.ID},
+			SeenAt:      event.CreatedAt,
+		})
+	}
+	return findings, nil
+}
+
+// 夜间修改了指定分类的系统配置
+func detectNightConfigChange(rule AnomalyRule, since time.Time) ([]anomalyFinding, error) {
+	var logs []OperationLog
+	err := db.Model(&OperationLog{}).
+		Where("resource = ? AND action <> ? AND action <> ? AND status < ? AND created_at >= ? AND archived = ?", "config", "read", "list", 400, since, false).
+		Order("id").
+		Find(&logs).Error
+	if err != nil {
+		return nil, err
+	}
+
+	var findings []anomalyFinding
+	for _, entry := range logs {
+		if !isNightHour(entry.CreatedAt.Local().Hour(), rule.NightStart, rule.NightEnd) {
+			continue
+		}
+
+		// 从字段变更中找出被修改的配置项
+		var keys []string
+		for _, diff := range parseLogDiff(entry.Details) {
+			if diff.Table != "system_configs" {
+				continue
+			}
+			var config SystemConfig
+			if db.Unscoped().Select("key", "category").First(&config, diff.RecordID).Error != nil || config.Category != rule.Category {
+				continue
+			}
+			keys = append(keys, config.Key)
+		}
+		if len(keys) == 0 {
+			continue
+		}
+
+		findings = append(findings, anomalyFinding{
+			Fingerprint: fmt.Sprintf("%s:log:%d", rule.Name, entry.ID),
+			Title:       fmt.Sprintf("用户 %s 在夜间修改了%s配置", entry.Username, rule.Category),
+			Message:     fmt.Sprintf("%s 修改了配置：%s", entry.CreatedAt.Local().Format("2006-01-02 15:04:05"), strings.Join(keys, ", ")),
+			UserID:      entry.UserID,
+			Username:    entry.Username,
+			IP:          entry.IP,
+			Count:       len(keys),
+			Evidence:    []uint{entry.ID},
+			SeenAt:      entry.CreatedAt,
+		})
+	}
+	return findings, nil
+}
+
+// 判断是否为夜间（支持跨零点，如 22 点至 6 点）
+func isNightHour(hour, start, end int) bool {
+	if start == end {
+		return false
+	}
+	if start < end {
+		return hour >= start && hour < end
+	}
+	return hour >= start || hour < end
+}
+
+// 窗口期内匹配条件的日志ID（最多20条，作为告警证据）
+func recentLogIDs(since time.Time, condition string, args ...interface{}) []uint {
+	var ids []uint
+	db.Model(&OperationLog{}).
+		Where("created_at >= ? AND archived = ?", since, false).
+		Where(condition, args...).
+		Order("id DESC").
+		Limit(20).
+		Pluck("id", &ids)
+	return ids
+}
+
+// 产生告警：窗口期内已有相同指纹的未解决告警时只更新次数，返回是否为新告警
+func raiseAlert(rule AnomalyRule, finding anomalyFinding) (bool, error) {
+	evidence, _ := json.Marshal(finding.Evidence)
+	windowStart := finding.SeenAt.Add(-time.Duration(rule.WindowMinutes) * time.Minute)
+
+	var existing Alert
+	err := db.Where("fingerprint = ? AND status <> ? AND last_seen_at >= ?", finding.Fingerprint, AlertStatusResolved, windowStart).
+		Order("id DESC").
+		Limit(1).
+		Find(&existing).Error
+	if err != nil {
+		return false, err
+	}
+	if existing.ID != 0 {
+		return false, db.Model(&existing).Updates(map[string]interface{}{
+			"count":        finding.Count,
+			"message":      finding.Message,
+			"evidence":     string(evidence),
+			"last_seen_at": finding.SeenAt,
+		}).Error
+	}
+
+	// 单条事件的告警（如新IP登录）处理后不再重复产生
+	var handled int64
+	db.Model(&Alert{}).Where("fingerprint = ? AND last_seen_at >= ?", finding.Fingerprint, windowStart).Count(&handled)
+	if handled > 0 {
+		return false, nil
+	}
+
+	alert := Alert{
+		Rule:        rule.Name,
+		Type:        rule.Type,
+		Severity:    rule.Severity,
+		Status:      AlertStatusOpen,
+		Title:       finding.Title,
+		Message:     finding.Message,
+		Fingerprint: finding.Fingerprint,
+		UserID:      finding.UserID,
+		Username:    finding.Username,
+		IP:          finding.IP,
+		Count:       finding.Count,
+		Evidence:    string(evidence),
+		FirstSeenAt: finding.SeenAt,
+		LastSeenAt:  finding.SeenAt,
+	}
+	if err := db.Create(&alert).Error; err != nil {
+		return false, err
+	}
+	appLogger.Warn("security alert raised", "alert_id", alert.ID, "rule", alert.Rule, "severity", alert.Severity, "title", alert.Title, "message", alert.Message)
+
+	go notifyAlert(alert)
+	return true, nil
+}
+
+// 发送告警通知（邮件、Webhook），低于配置级别的告警不通知
+func notifyAlert(alert Alert) {
+	minSeverity := getConfigValue("alert_notify_min_severity", AlertSeverityHigh)
+	if alertSeverityRank[alert.Severity] < alertSeverityRank[minSeverity] {
+		return
+	}
+
+	sent := false
+	if recipients := getConfigValue("alert_email_to", ""); recipients != "" {
+		if err := sendAlertEmail(alert, recipients); err != nil {
+			appLogger.Error("failed to send alert email", "alert_id", alert.ID, "error", err)
+		} else {
+			sent = true
+		}
+	}
+	if url := getConfigValue("alert_webhook_url", ""); url != "" {
+		if err := sendAlertWebhook(alert, url); err != nil {
+			appLogger.Error("failed to send alert webhook", "alert_id", alert.ID, "error", err)
+		} else {
+			sent = true
+		}
+	}
+
+	if sent {
+		now := time.Now()
+		db.Model(&Alert{}).Where("id = ?", alert.ID).Update("notified_at", &now)
+	}
+}
+
+// 通过邮件发送告警（使用邮件配置中的SMTP服务器）
+func sendAlertEmail(alert Alert, recipients string) error {
+	host := getConfigValue("mail_host", "")
+	if host == "" {
+		return fmt.Errorf("mail_host is not configured")
+	}
+	port := getConfigValue("mail_port", "587")
+	username := getConfigValue("mail_username", "")
+	from := getConfigValue("mail_from", username)
+
+	var to []string
+	for _, addr := range strings.Split(recipients, ",") {
+		if addr = strings.TrimSpace(addr); addr != "" {
+			to = append(to, addr)
+		}
+	}
+
+	subject := fmt.Sprintf("[%s] %s", strings.ToUpper(alert.Severity), alert.Title)
+	body := fmt.Sprintf("规则：%s\r\n级别：%s\r\n时间：%s\r\n用户：%s\r\nIP：%s\r\n\r\n%s\r\n",
+		alert.Rule, alert.Severity, alert.LastSeenAt.Local().Format("2006-01-02 15:04:05"), alert.Username, alert.IP, alert.Message)
+	message := "From: " + from + "\r\n" +
+		"To: " + strings.Join(to, ", ") + "\r\n" +
+		"Subject: " + subject + "\r\n" +
+		"MIME-Version: 1.0\r\n" +
+		"Content-Type: text/plain; charset=UTF-8\r\n\r\n" + body
+
+	var auth smtp.Auth
+	if username != "" {
+		auth = smtp.PlainAuth("", username, getConfigValue("mail_password", ""), host)
+	}
+	return smtp.SendMail(host+":"+port, auth, from, to, []byte(message))
+}
+
+// 通过 Webhook 发送告警（POST JSON）
+func sendAlertWebhook(alert Alert, url string) error {
+	payload, err := json.Marshal(gin.H{
+		"event": "security_alert",
+		"alert": alert,
+	})
+	if err != nil {
+		return err
+	}
+
+	client := &http.Client{Timeout: 10 * time.Second}
+	resp, err := client.Post(url, "application/json", bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook returned status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// 启动异常检测定时任务
+func startAnomalyDetector(interval time.Duration) {
+	jobRunner.Every("anomaly_detection", interval, func(ctx context.Context) {
+		if created, err := runAnomalyDetection(ctx); err != nil {
+			appLogger.Error("anomaly detection failed", "error", err)
+		} else if created > 0 {
+			appLogger.Info("anomaly detection raised alerts", "count", created)
+		}
+	})
+}
@@ -0,0 +1,461 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	gormlogger "gorm.io/gorm/logger"
+)
+
+// 应用日志级别（可在运行时修改）
+var appLogLevel = new(slog.LevelVar)
+
+// 应用日志的输出处理器：数据库初始化前输出到标准输出，读取系统配置后按配置替换
+var appLogOutput = newSwapLogHandler(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{Level: appLogLevel}))
+
+// 应用日志（不会重新赋值，修改配置时只替换 appLogOutput 中的处理器）
+var appLogger = slog.New(appLogOutput)
+
+// 应用日志配置
+type AppLogConfig struct {
+	Level         string   `json:"level"`          // debug, info, warn, error
+	Format        string   `json:"format"`         // json, text
+	Outputs       []string `json:"outputs"`        // stdout, file, syslog
+	File          string   `json:"file"`           // 日志文件路径
+	MaxSizeMB     int      `json:"max_size_mb"`    // 单个日志文件大小上限，超过后轮转
+	MaxBackups    int      `json:"max_backups"`    // 保留的历史日志文件数
+	SyslogAddress string   `json:"syslog_address"` // 本地 syslog 套接字
+}
+
+var (
+	appLogMu      sync.Mutex
+	appLogCurrent AppLogConfig
+	appLogClosers []io.Closer
+)
+
+// 读取应用日志配置
+func loadAppLogConfig() AppLogConfig {
+	return AppLogConfig{
+		Level:         configCache.Get("app_log_level", "info"),
+		Format:        configCache.Get("app_log_format", "text"),
+		Outputs:       configCache.GetList("app_log_outputs", "stdout"),
+		File:          configCache.Get("app_log_file", filepath.Join("logs", "app.log")),
+		MaxSizeMB:     configCache.GetInt("app_log_file_max_size_mb", 100),
+		MaxBackups:    configCache.GetInt("app_log_file_max_backups", 5),
+		SyslogAddress: configCache.Get("app_log_syslog_address", "/dev/log"),
+	}
+}
+
+// 订阅应用日志配置：级别直接生效，输出相关配置修改后重新创建日志（失败时保留原来的日志输出）
+func watchAppLogConfig() {
+	configCache.Subscribe(func(change ConfigChange) {
+		var level slog.Level
+		if err := level.UnmarshalText([]byte(configCache.Get("app_log_level", "info"))); err != nil {
+			appLogger.Error("invalid app_log_level, keeping current level", "value", change.NewValue)
+			return
+		}
+		appLogMu.Lock()
+		appLogLevel.Set(level)
+		appLogCurrent.Level = change.NewValue
+		appLogMu.Unlock()
+	}, "app_log_level")
+
+	configCache.Subscribe(func(change ConfigChange) {
+		if err := configureAppLogger(); err != nil {
+			appLogger.Error("failed to reconfigure application logger", "key", change.Key, "error", err)
+		}
+	}, "app_log_format", "app_log_outputs", "app_log_file", "app_log_file_max_size_mb", "app_log_file_max_backups", "app_log_syslog_address")
+}
+
+// 按系统配置创建应用日志，并接管标准库 log 和 GORM 的输出
+func configureAppLogger() error {
+	config := loadAppLogConfig()
+
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(config.Level)); err != nil {
+		return fmt.Errorf("invalid app_log_level %q", config.Level)
+	}
+	if config.Format != "json" && config.Format != "text" {
+		return fmt.Errorf("invalid app_log_format %q", config.Format)
+	}
+
+	var handlers []slog.Handler
+	var closers []io.Closer
+	fail := func(err error) error {
+		for _, closer := range closers {
+			closer.Close()
+		}
+		return err
+	}
+	for _, output := range config.Outputs {
+		switch output {
+		case "stdout":
+			handlers = append(handlers, newAppLogHandler(os.Stdout, config.Format))
+		case "file":
+			file, err := openRotatingFile(config.File, int64(config.MaxSizeMB)<<20, config.MaxBackups)
+			if err != nil {
+				return fail(fmt.Errorf("open log file: %w", err))
+			}
+			closers = append(closers, file)
+			handlers = append(handlers, newAppLogHandler(file, config.Format))
+		case "syslog":
+			handler, err := newSyslogHandler(config.SyslogAddress, config.Format)
+			if err != nil {
+				return fail(fmt.Errorf("connect syslog: %w", err))
+			}
+			closers = append(closers, handler)
+			handlers = append(handlers, handler)
+		default:
+			return fail(fmt.Errorf("unknown log output %q", output))
+		}
+	}
+	if len(handlers) == 0 {
+		handlers = append(handlers, newAppLogHandler(os.Stdout, config.Format))
+	}
+
+	appLogMu.Lock()
+	defer appLogMu.Unlock()
+
+	appLogLevel.Set(level)
+	appLogOutput.swap(multiLogHandler(handlers))
+	slog.SetDefault(appLogger)
+
+	for _, closer := range appLogClosers {
+		closer.Close()
+	}
+	appLogClosers = closers
+	appLogCurrent = config
+	return nil
+}
+
+// 关闭日志文件和 syslog 连接
+func closeAppLogger() {
+	appLogMu.Lock()
+	defer appLogMu.Unlock()
+	for _, closer := range appLogClosers {
+		closer.Close()
+	}
+	appLogClosers = nil
+}
+
+// 按格式创建日志处理器
+func newAppLogHandler(w io.Writer, format string) slog.Handler {
+	options := &slog.HandlerOptions{Level: appLogLevel}
+	if format == "json" {
+		return slog.NewJSONHandler(w, options)
+	}
+	return slog.NewTextHandler(w, options)
+}
+
+// GORM 日志（慢查询和错误）输出到应用日志，随应用日志配置切换输出
+func newGormLogger() gormlogger.Interface {
+	return gormlogger.New(slog.NewLogLogger(appLogger.Handler(), slog.LevelWarn), gormlogger.Config{
+		SlowThreshold:             200 * time.Millisecond,
+		LogLevel:                  gormlogger.Warn,
+		IgnoreRecordNotFoundError: true,
+	})
+}
+
+// 带请求字段（请求ID、用户ID）的日志
+func requestLogger(c *gin.Context) *slog.Logger {
+	logger := appLogger.With("request_id", requestIDFromContext(c))
+	if userID, exists := c.Get("user_id"); exists {
+		logger = logger.With("user_id", userID)
+	}
+	return logger
+}
+
+// 记录错误后退出
+func fatal(msg string, err error) {
+	appLogger.Error(msg, "error", err)
+	os.Exit(1)
+}
+
+// 同时写入多个输出的日志处理器
+type multiLogHandler []slog.Handler
+
+func (m multiLogHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	for _, h := range m {
+		if h.Enabled(ctx, level) {
+			return true
+		}
+	}
+	return false
+}
+
+func (m multiLogHandler) Handle(ctx context.Context, record slog.Record) error {
+	var errs []error
+	for _, h := range m {
+		if h.Enabled(ctx, record.Level) {
+			errs = append(errs, h.Handle(ctx, record.Clone()))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (m multiLogHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	handlers := make(multiLogHandler, len(m))
+	for i, h := range m {
+		handlers[i] = h.WithAttrs(attrs)
+	}
+	return handlers
+}
+
+func (m multiLogHandler) WithGroup(name string) slog.Handler {
+	handlers := make(multiLogHandler, len(m))
+	for i, h := range m {
+		handlers[i] = h.WithGroup(name)
+	}
+	return handlers
+}
+
+// 可在运行时替换的日志处理器：日志记录器和 With 派生的记录器保持不变，写入时使用当前的处理器
+type swapLogHandler struct {
+	current *atomic.Pointer[slog.Handler]
+	derive  []func(slog.Handler) slog.Handler // WithAttrs/WithGroup，写入时应用到当前处理器
+}
+
+func newSwapLogHandler(handler slog.Handler) *swapLogHandler {
+	h := &swapLogHandler{current: new(atomic.Pointer[slog.Handler])}
+	h.swap(handler)
+	return h
+}
+
+// 替换处理器，所有派生的记录器同时生效
+func (h *swapLogHandler) swap(handler slog.Handler) {
+	h.current.Store(&handler)
+}
+
+func (h *swapLogHandler) handler() slog.Handler {
+	handler := *h.current.Load()
+	for _, derive := range h.derive {
+		handler = derive(handler)
+	}
+	return handler
+}
+
+func (h *swapLogHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return (*h.current.Load()).Enabled(ctx, level)
+}
+
+func (h *swapLogHandler) Handle(ctx context.Context, record slog.Record) error {
+	return h.handler().Handle(ctx, record)
+}
+
+func (h *swapLogHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return h.with(func(handler slog.Handler) slog.Handler { return handler.WithAttrs(attrs) })
+}
+
+func (h *swapLogHandler) WithGroup(name string) slog.Handler {
+	return h.with(func(handler slog.Handler) slog.Handler { return handler.WithGroup(name) })
+}
+
+func (h *swapLogHandler) with(derive func(slog.Handler) slog.Handler) slog.Handler {
+	derived := make([]func(slog.Handler) slog.Handler, len(h.derive), len(h.derive)+1)
+	copy(derived, h.derive)
+	return &swapLogHandler{current: h.current, derive: append(derived, derive)}
+}
+
+// 按大小轮转的日志文件：app.log 写满后依次重命名为 app.log.1、app.log.2 ...
+type rotatingFile struct {
+	mu         sync.Mutex
+	path       string
+	maxSize    int64
+	maxBackups int
+	file       *os.File
+	size       int64
+}
+
+func openRotatingFile(path string, maxSize int64, maxBackups int) (*rotatingFile, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, err
+	}
+	r := &rotatingFile{path: path, maxSize: maxSize, maxBackups: maxBackups}
+	if err := r.open(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+func (r *rotatingFile) open() error {
+	file, err := os.OpenFile(r.path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return err
+	}
+	r.file, r.size = file, info.Size()
+	return nil
+}
+
+func (r *rotatingFile) rotate() error {
+	if err := r.file.Close(); err != nil {
+		return err
+	}
+	if r.maxBackups <= 0 {
+		os.Remove(r.path)
+	} else {
+		os.Remove(fmt.Sprintf("%s.%d", r.path, r.maxBackups))
+		for i := r.maxBackups - 1; i >= 1; i-- {
+			os.Rename(fmt.Sprintf("%s.%d", r.path, i), fmt.Sprintf("%s.%d", r.path, i+1))
+		}
+		if err := os.Rename(r.path, r.path+".1"); err != nil {
+			return err
+		}
+	}
+	return r.open()
+}
+
+func (r *rotatingFile) Write(p []byte) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.file == nil {
+		return 0, os.ErrClosed
+	}
+	if r.maxSize > 0 && r.size > 0 && r.size+int64(len(p)) > r.maxSize {
+		if err := r.rotate(); err != nil {
+			return 0, err
+		}
+	}
+	n, err := r.file.Write(p)
+	r.size += int64(n)
+	return n, err
+}
+
+func (r *rotatingFile) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.file == nil {
+		return nil
+	}
+	err := r.file.Close()
+	r.file = nil
+	return err
+}
+
+// syslog 处理器：通过本地 Unix 套接字发送，优先级按日志级别确定（facility 为 user）
+type syslogHandler struct {
+	inner slog.Handler
+	state *syslogState
+}
+
+type syslogState struct {
+	mu   sync.Mutex
+	conn net.Conn
+	buf  bytes.Buffer
+	tag  string
+}
+
+func newSyslogHandler(address, format string) (*syslogHandler, error) {
+	var conn net.Conn
+	var err error
+	for _, network := range []string{"unixgram", "unix"} {
+		if conn, err = net.Dial(network, address); err == nil {
+			break
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	state := &syslogState{conn: conn, tag: fmt.Sprintf("jing-admin[%d]", os.Getpid())}
+	return &syslogHandler{inner: newAppLogHandler(&state.buf, format), state: state}, nil
+}
+
+// 日志级别对应的 syslog 严重程度
+func syslogSeverity(level slog.Level) int {
+	switch {
+	case level >= slog.LevelError:
+		return 3 // err
+	case level >= slog.LevelWarn:
+		return 4 // warning
+	case level >= slog.LevelInfo:
+		return 6 // info
+	default:
+		return 7 // debug
+	}
+}
+
+func (h *syslogHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return h.inner.Enabled(ctx, level)
+}
+
+func (h *syslogHandler) Handle(ctx context.Context, record slog.Record) error {
+	s := h.state
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.buf.Reset()
+	if err := h.inner.Handle(ctx, record); err != nil {
+		return err
+	}
+	const facilityUser = 1
+	message := fmt.Sprintf("<%d>%s %s: %s", facilityUser*8+syslogSeverity(record.Level),
+		record.Time.Format(time.Stamp), s.tag, bytes.TrimRight(s.buf.Bytes(), "\n"))
+	_, err := s.conn.Write([]byte(message))
+	return err
+}
+
+func (h *syslogHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &syslogHandler{inner: h.inner.WithAttrs(attrs), state: h.state}
+}
+
+func (h *syslogHandler) WithGroup(name string) slog.Handler {
+	return &syslogHandler{inner: h.inner.WithGroup(name), state: h.state}
+}
+
+func (h *syslogHandler) Close() error {
+	return h.state.conn.Close()
+}
+
+// 获取应用日志配置和当前级别
+func getAppLogLevel(c *gin.Context) {
+	appLogMu.Lock()
+	config := appLogCurrent
+	appLogMu.Unlock()
+
+	successResponse(c, gin.H{
+		"level":  strings.ToLower(appLogLevel.Level().String()),
+		"config": config,
+	})
+}
+
+// 运行时修改应用日志级别（重启后恢复为系统配置 app_log_level）
+func updateAppLogLevel(c *gin.Context) {
+	var req struct {
+		Level string `json:"level" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		errorResponse(c, 400, "请求参数错误: "+err.Error())
+		return
+	}
+
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(req.Level)); err != nil {
+		errorResponse(c, 400, "无效的日志级别，可选 debug、info、warn、error")
+		return
+	}
+	// 先记录再修改，调高级别时这条日志也能输出
+	previous := appLogLevel.Level()
+	requestLogger(c).Warn("application log level changed", "from", previous.String(), "to", level.String())
+	appLogLevel.Set(level)
+
+	successResponse(c, gin.H{
+		"level":    strings.ToLower(level.String()),
+		"previous": strings.ToLower(previous.String()),
+	})
+}
@@ -0,0 +1,402 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// 需要自动记录字段变更的数据表
+var auditedTables = map[string]bool{
+	"users":          true,
+	"roles":          true,
+	"permissions":    true,
+	"system_configs": true,
+	"uploaded_files": true,
+	"user_groups":    true,
+	"custom_fields":  true,
+}
+
+// 不记录变更的字段
+var auditIgnoredFields = map[string]bool{
+	"created_at": true,
+	"updated_at": true,
+	"version":    true,
+}
+
+// 需要脱敏的配置键
+var auditSecretConfigKeys = map[string]bool{
+	"mail_password": true,
+}
+
+// 单次操作最多记录的行数，避免大批量更新产生过大的日志
+const auditMaxRows = 200
+
+const auditMask = "******"
+
+type auditContextKey struct{}
+
+// 单个字段的变更
+type AuditChange struct {
+	Table    string      `json:"table"`
+	RecordID string      `json:"record_id"`
+	Op       string      `json:"op"` // create, update, delete
+	Field    string      `json:"field"`
+	Old      interface{} `json:"old"`
+	New      interface{} `json:"new"`
+}
+
+// 请求范围内的变更收集器
+type AuditCollector struct {
+	mu      sync.Mutex
+	changes []AuditChange
+}
+
+// 添加变更
+func (ac *AuditCollector) Add(changes ...AuditChange) {
+	ac.mu.Lock()
+	defer ac.mu.Unlock()
+	ac.changes = append(ac.changes, changes...)
+}
+
+// 获取所有变更
+func (ac *AuditCollector) Changes() []AuditChange {
+	ac.mu.Lock()
+	defer ac.mu.Unlock()
+	return append([]AuditChange(nil), ac.changes...)
+}
+
+// 为请求创建变更收集器（由日志中间件调用）
+func attachAuditCollector(c *gin.Context) *AuditCollector {
+	collector := &AuditCollector{}
+	ctx := context.WithValue(c.Request.Context(), auditContextKey{}, collector)
+	c.Request = c.Request.WithContext(ctx)
+	return collector
+}
+
+// 获取请求的变更收集器
+func auditCollectorFrom(ctx context.Context) *AuditCollector {
+	if ctx == nil {
+		return nil
+	}
+	collector, _ := ctx.Value(auditContextKey{}).(*AuditCollector)
+	return collector
+}
+
+// 返回携带请求上下文的数据库连接，写操作通过它执行时会自动记录字段变更
+func auditDB(c *gin.Context) *gorm.DB {
+	return db.WithContext(c.Request.Context())
+}
+
+// 手动记录无法通过钩子捕获的变更（如多对多关联）
+func recordAuditChange(c *gin.Context, table string, recordID interface{}, field string, oldValue, newValue interface{}) {
+	collector := auditCollectorFrom(c.Request.Context())
+	if collector == nil {
+		return
+	}
+	collector.Add(AuditChange{
+		Table:    table,
+		RecordID: fmt.Sprint(recordID),
+		Op:       "update",
+		Field:    field,
+		Old:      oldValue,
+		New:      newValue,
+	})
+}
+
+// 注册GORM回调
+func registerAuditCallbacks(conn *gorm.DB) error {
+	if err := conn.Callback().Create().After("gorm:create").Register("audit:after_create", auditAfterCreate); err != nil {
+		return err
+	}
+	if err := conn.Callback().Update().Before("gorm:update").Register("audit:before_update", auditCaptureBefore); err != nil {
+		return err
+	}
+	if err := conn.Callback().Update().After("gorm:update").Register("audit:after_update", auditAfterUpdate); err != nil {
+		return err
+	}
+	if err := conn.Callback().Delete().Before("gorm:delete").Register("audit:before_delete", auditCaptureBefore); err != nil {
+		return err
+	}
+	return conn.Callback().Delete().After("gorm:delete").Register("audit:after_delete", auditAfterDelete)
+}
+
+// 判断当前语句是否需要审计
+func auditEnabled(tx *gorm.DB) (*AuditCollector, string) {
+	if tx.Statement.Schema == nil || tx.Error != nil {
+		return nil, ""
+	}
+	table := tx.Statement.Table
+	if !auditedTables[table] {
+		return nil, ""
+	}
+	collector := auditCollectorFrom(tx.Statement.Context)
+	if collector == nil {
+		return nil, ""
+	}
+	return collector, table
+}
+
+// 获取语句模型中已设置的主键值
+func auditPrimaryKeys(tx *gorm.DB) []interface{} {
+	field := tx.Statement.Schema.PrioritizedPrimaryField
+	if field == nil {
+		return nil
+	}
+
+	ids := []interface{}{}
+	rv := tx.Statement.ReflectValue
+	switch rv.Kind() {
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < rv.Len(); i++ {
+			if value, isZero := field.ValueOf(tx.Statement.Context, reflect.Indirect(rv.Index(i))); !isZero {
+				ids = append(ids, value)
+			}
+		}
+	case reflect.Struct:
+		if value, isZero := field.ValueOf(tx.Statement.Context, rv); !isZero {
+			ids = append(ids, value)
+		}
+	}
+	return ids
+}
+
+// 读取受影响记录的当前值
+func auditLoadRows(tx *gorm.DB, table string, ids []interface{}, where *clause.Where) []map[string]interface{} {
+	query := tx.Session(&gorm.Session{NewDB: true, SkipHooks: true}).Table(table)
+	if len(ids) > 0 {
+		query = query.Where("id IN ?", ids)
+	}
+	if where != nil {
+		query = query.Clauses(*where)
+	}
+	if len(ids) == 0 && where == nil {
+		return nil
+	}
+
+	var rows []map[string]interface{}
+	query.Limit(auditMaxRows).Find(&rows)
+	return rows
+}
+
+// 更新/删除前记录原值
+func auditCaptureBefore(tx *gorm.DB) {
+	_, table := auditEnabled(tx)
+	if table == "" {
+		return
+	}
+
+	var where *clause.Where
+	if c, ok := tx.Statement.Clauses["WHERE"]; ok {
+		if w, ok := c.Expression.(clause.Where); ok {
+			where = &w
+		}
+	}
+	rows := auditLoadRows(tx, table, auditPrimaryKeys(tx), where)
+	tx.InstanceSet("audit:before", rows)
+}
+
+// 创建后记录新值
+func auditAfterCreate(tx *gorm.DB) {
+	collector, table := auditEnabled(tx)
+	if collector == nil {
+		return
+	}
+	for _, row := range auditLoadRows(tx, table, auditPrimaryKeys(tx), nil) {
+		collector.Add(diffAuditRows(table, "create", nil, row)...)
+	}
+}
+
+// 更新后对比新旧值
+func auditAfterUpdate(tx *gorm.DB) {
+	collector, table := auditEnabled(tx)
+	if collector == nil {
+		return
+	}
+	value, ok := tx.InstanceGet("audit:before")
+	if !ok {
+		return
+	}
+	before, _ := value.([]map[string]interface{})
+	if len(before) == 0 {
+		return
+	}
+
+	ids := make([]interface{}, 0, len(before))
+	for _, row := range before {
+		ids = append(ids, row["id"])
+	}
+	after := make(map[string]map[string]interface{})
+	for _, row := range auditLoadRows(tx.Unscoped(), table, ids, nil) {
+		after[fmt.Sprint(row["id"])] = row
+	}
+
+	for _, row := range before {
+		if newRow, ok := after[fmt.Sprint(row["id"])]; ok {
+			collector.Add(diffAuditRows(table, "update", row, newRow)...)
+		}
+	}
+}
+
+// 删除后记录原值
+func auditAfterDelete(tx *gorm.DB) {
+	collector, table := auditEnabled(tx)
+	if collector == nil {
+		return
+	}
+	value, ok := tx.InstanceGet("audit:before")
+	if !ok {
+		return
+	}
+	before, _ := value.([]map[string]interface{})
+	for _, row := range before {
+		collector.Add(diffAuditRows(table, "delete", row, nil)...)
+	}
+}
+
+// 对比两行数据，生成字段变更列表（敏感字段脱敏）
+func diffAuditRows(table, op string, oldRow, newRow map[string]interface{}) []AuditChange {
+	ref := newRow
+	if ref == nil {
+		ref = oldRow
+	}
+	recordID := fmt.Sprint(ref["id"])
+
+	fields := make([]string, 0, len(ref))
+	for field := range ref {
+		if !auditIgnoredFields[field] && field != "id" {
+			fields = append(fields, field)
+		}
+	}
+	sort.Strings(fields)
+
+	changes := []AuditChange{}
+	for _, field := range fields {
+		oldValue := normalizeAuditValue(oldRow[field])
+		newValue := normalizeAuditValue(newRow[field])
+		if op == "update" && fmt.Sprint(oldValue) == fmt.Sprint(newValue) {
+			continue
+		}
+		if op != "update" && (newValue == nil || newValue == "") && (oldValue == nil || oldValue == "") {
+			continue
+		}
+		if isSecretAuditField(table, field, ref) {
+			oldValue, newValue = maskAuditValue(oldValue), maskAuditValue(newValue)
+		}
+		changes = append(changes, AuditChange{
+			Table:    table,
+			RecordID: recordID,
+			Op:       op,
+			Field:    field,
+			Old:      oldValue,
+			New:      newValue,
+		})
+	}
+	return changes
+}
+
+// 判断字段是否为敏感字段
+func isSecretAuditField(table, field string, row map[string]interface{}) bool {
+	if strings.Contains(field, "password") || strings.Contains(field, "secret") || strings.Contains(field, "token") {
+		return true
+	}
+	if table == "system_configs" && field == "value" {
+		key := fmt.Sprint(row["key"])
+		return auditSecretConfigKeys[key] || strings.Contains(key, "password") || strings.Contains(key, "secret")
+	}
+	return false
+}
+
+// 脱敏（保留是否为空的信息）
+func maskAuditValue(value interface{}) interface{} {
+	if value == nil || value == "" {
+		return value
+	}
+	return auditMask
+}
+
+// 统一数据库返回值的格式
+func normalizeAuditValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case nil:
+		return nil
+	case []byte:
+		return string(v)
+	case time.Time:
+		return v.Format(time.RFC3339)
+	case *time.Time:
+		if v == nil {
+			return nil
+		}
+		return v.Format(time.RFC3339)
+	default:
+		return v
+	}
+}
+
+// 生成日志详情：将请求中收集的字段变更合并到详情JSON中
+func buildLogDetails(c *gin.Context, details string) string {
+	collector := auditCollectorFrom(c.Request.Context())
+	if collector == nil {
+		return details
+	}
+	changes := collector.Changes()
+	if len(changes) == 0 {
+		return details
+	}
+
+	payload := map[string]interface{}{}
+	if details != "" {
+		if err := json.Unmarshal([]byte(details), &payload); err != nil {
+			payload = map[string]interface{}{"message": details}
+		}
+	}
+	payload["changes"] = changes
+
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return details
+	}
+	return string(data)
+}
+
+// 按记录分组的变更视图
+type AuditRecordDiff struct {
+	Table    string        `json:"table"`
+	RecordID string        `json:"record_id"`
+	Op       string        `json:"op"`
+	Fields   []AuditChange `json:"fields"`
+}
+
+// 从日志详情中解析变更，并按记录分组
+func parseLogDiff(details string) []AuditRecordDiff {
+	var payload struct {
+		Changes []AuditChange `json:"changes"`
+	}
+	if details == "" || json.Unmarshal([]byte(details), &payload) != nil {
+		return []AuditRecordDiff{}
+	}
+
+	diffs := []AuditRecordDiff{}
+	index := make(map[string]int)
+	for _, change := range payload.Changes {
+		key := change.Table + "#" + change.RecordID + "#" + change.Op
+		i, ok := index[key]
+		if !ok {
+			i = len(diffs)
+			index[key] = i
+			diffs = append(diffs, AuditRecordDiff{Table: change.Table, RecordID: change.RecordID, Op: change.Op})
+		}
+		diffs[i].Fields = append(diffs[i].Fields, change)
+	}
+	return diffs
+}
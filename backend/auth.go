@@ -8,6 +8,7 @@ import (
 	"github.com/gin-gonic/gin"
 	"github.com/golang-jwt/jwt/v5"
 	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
 )
 
 // JWT密钥 - 实际项目中应该从环境变量获取
@@ -60,7 +61,7 @@ func generateToken(user User) (string, error) {
 		Username: user.Username,
 		Role:     user.Role,
 		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(time.Now().Add(24 * time.Hour)), // 24小时过期
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(sessionTimeout())),
 			IssuedAt:  jwt.NewNumericDate(time.Now()),
 			Issuer:    "jing-admin",
 		},
@@ -70,6 +71,15 @@ func generateToken(user User) (string, error) {
 	return token.SignedString(jwtSecret)
 }
 
+// 会话超时时间（系统配置 session_timeout，单位秒）
+func sessionTimeout() time.Duration {
+	timeout := configCache.GetDuration("session_timeout", time.Second, 24*time.Hour)
+	if timeout <= 0 {
+		return 24 * time.Hour
+	}
+	return timeout
+}
+
 // 解析JWT令牌
 func parseToken(tokenString string) (*Claims, error) {
 	token, err := jwt.ParseWithClaims(tokenString, &Claims{}, func(token *jwt.Token) (interface{}, error) {
@@ -116,6 +126,24 @@ func authMiddleware() gin.HandlerFunc {
 			return
 		}
 
+		// 检查用户当前信息：已删除或非正常状态的用户令牌失效，需要修改密码时只允许修改密码
+		info, ok := authUsers.get(claims.UserID)
+		if !ok {
+			errorResponse(c, 401, "用户不存在")
+			c.Abort()
+			return
+		}
+		if message, inactive := inactiveUserMessages[info.State]; inactive {
+			errorResponse(c, 401, message)
+			c.Abort()
+			return
+		}
+		if info.MustChangePassword && !mustChangePasswordRoutes[c.Request.Method+" "+c.FullPath()] {
+			errorResponse(c, 403, "请先修改密码")
+			c.Abort()
+			return
+		}
+
 		// 将用户信息存储到上下文
 		c.Set("user_id", claims.UserID)
 		c.Set("username", claims.Username)
@@ -142,6 +170,7 @@ func adminMiddleware() gin.HandlerFunc {
 func login(c *gin.Context) {
 	var req LoginRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
+		recordAuthEvent(c, AuthEventLogin, 0, req.Username, false, AuthReasonBadRequest)
 		errorResponse(c, 400, "请求参数错误")
 		return
 	}
@@ -150,36 +179,78 @@ func login(c *gin.Context) {
 	var user User
 	result := db.Where("username = ?", req.Username).First(&user)
 	if result.Error != nil {
+		recordAuthEvent(c, AuthEventLogin, 0, req.Username, false, AuthReasonUnknownUser)
 		errorResponse(c, 401, "用户名或密码错误")
 		return
 	}
 
+	// 应用到期的状态变更（自动解锁、停用到期、账户过期）
+	refreshUserState(&user)
+
+	// 锁定的账户不再校验密码
+	if user.State == UserStateLocked {
+		recordAuthEvent(c, AuthEventLogin, user.ID, req.Username, false, AuthReasonLocked)
+		errorResponse(c, 401, "账户已锁定，请于 "+formatTime(user.LockedUntil)+" 后重试")
+		return
+	}
+
 	// 验证密码
 	if !checkPassword(req.Password, user.Password) {
+		if until := recordFailedLogin(&user); until != nil {
+			recordAuthEvent(c, AuthEventLogin, user.ID, req.Username, false, AuthReasonLocked)
+			errorResponse(c, 401, "密码错误次数过多，账户已锁定至 "+formatTime(until))
+			return
+		}
+		recordAuthEvent(c, AuthEventLogin, user.ID, req.Username, false, AuthReasonBadPassword)
 		errorResponse(c, 401, "用户名或密码错误")
 		return
 	}
 
 	// 检查用户状态
-	if !user.Status {
-		errorResponse(c, 401, "账户已被禁用")
+	switch user.State {
+	case UserStatePending:
+		recordAuthEvent(c, AuthEventLogin, user.ID, req.Username, false, AuthReasonPending)
+		errorResponse(c, 401, "账户待激活，请联系管理员")
+		return
+	case UserStateSuspended:
+		message := "账户已被停用"
+		if user.SuspendedReason != "" {
+			message += "：" + user.SuspendedReason
+		}
+		if user.SuspendedUntil != nil {
+			message += "（至 " + formatTime(user.SuspendedUntil) + "）"
+		}
+		recordAuthEvent(c, AuthEventLogin, user.ID, req.Username, false, AuthReasonSuspended)
+		errorResponse(c, 401, message)
+		return
+	case UserStateExpired:
+		recordAuthEvent(c, AuthEventLogin, user.ID, req.Username, false, AuthReasonExpired)
+		errorResponse(c, 401, "账户已过期，请联系管理员")
+		return
+	case UserStateArchived:
+		recordAuthEvent(c, AuthEventLogin, user.ID, req.Username, false, AuthReasonArchived)
+		errorResponse(c, 401, "账户已归档")
 		return
 	}
 
 	// 生成JWT令牌
 	token, err := generateToken(user)
 	if err != nil {
+		recordAuthEvent(c, AuthEventLogin, user.ID, req.Username, false, AuthReasonServerError)
 		errorResponse(c, 500, "生成令牌失败")
 		return
 	}
 
-	// 更新最后登录时间
+	// 更新最后登录时间，并清零登录失败次数
 	now := time.Now()
 	db.Model(&user).Updates(map[string]interface{}{
-		"last_login": &now,
-		"updated_at": now,
+		"last_login":         &now,
+		"updated_at":         now,
+		"failed_login_count": 0,
 	})
 
+	recordAuthEvent(c, AuthEventLogin, user.ID, user.Username, true, AuthReasonOK)
+
 	// 返回响应（不包含密码）
 	user.Password = ""
 	successResponse(c, LoginResponse{
@@ -192,6 +263,7 @@ func login(c *gin.Context) {
 func register(c *gin.Context) {
 	var req RegisterRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
+		recordAuthEvent(c, AuthEventRegister, 0, req.Username, false, AuthReasonBadRequest)
 		errorResponse(c, 400, "请求参数错误")
 		return
 	}
@@ -199,6 +271,7 @@ func register(c *gin.Context) {
 	// 检查用户名是否存在
 	var existingUser User
 	if err := db.Where("username = ? OR email = ?", req.Username, req.Email).First(&existingUser).Error; err == nil {
+		recordAuthEvent(c, AuthEventRegister, 0, req.Username, false, AuthReasonDuplicateUser)
 		errorResponse(c, 400, "用户名或邮箱已存在")
 		return
 	}
@@ -206,6 +279,7 @@ func register(c *gin.Context) {
 	// 加密密码
 	hashedPassword, err := hashPassword(req.Password)
 	if err != nil {
+		recordAuthEvent(c, AuthEventRegister, 0, req.Username, false, AuthReasonServerError)
 		errorResponse(c, 500, "密码加密失败")
 		return
 	}
@@ -215,21 +289,54 @@ func register(c *gin.Context) {
 		req.Role = "user"
 	}
 
-	// 创建新用户
+	// 创建新用户（开启注册审核时为待激活状态）
 	newUser := User{
 		Username: req.Username,
 		Email:    req.Email,
 		Password: hashedPassword,
 		Role:     req.Role,
 		Status:   true,
+		State:    UserStateActive,
+	}
+	requireApproval := configCache.GetBool("register_require_approval", false)
+	if requireApproval {
+		newUser.Status = false
+		newUser.State = UserStatePending
 	}
 
-	result := db.Create(&newUser)
-	if result.Error != nil {
+	err = db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&newUser).Error; err != nil {
+			return err
+		}
+		if requireApproval {
+			if err := tx.Model(&newUser).Update("status", false).Error; err != nil {
+				return err
+			}
+		}
+		return recordUserStateHistory(tx, newUser.ID, "", newUser.State, "用户注册", nil, newUser.ID, newUser.Username)
+	})
+	if err != nil {
+		recordAuthEvent(c, AuthEventRegister, 0, req.Username, false, AuthReasonServerError)
 		errorResponse(c, 500, "创建用户失败")
 		return
 	}
 
+	// 注册成功（待审核时原因为 pending）
+	if requireApproval {
+		recordAuthEvent(c, AuthEventRegister, newUser.ID, newUser.Username, true, AuthReasonPending)
+	} else {
+		recordAuthEvent(c, AuthEventRegister, newUser.ID, newUser.Username, true, AuthReasonOK)
+	}
+
+	if requireApproval {
+		newUser.Password = ""
+		successResponse(c, gin.H{
+			"message":   "注册成功，请等待管理员激活",
+			"user_info": newUser,
+		})
+		return
+	}
+
 	// 生成JWT令牌
 	token, err := generateToken(newUser)
 	if err != nil {
@@ -245,8 +352,15 @@ func register(c *gin.Context) {
 	})
 }
 
-// 登出处理（前端删除token即可）
+// 登出处理（前端删除token即可，这里只记录登出事件）
 func logout(c *gin.Context) {
+	tokenString := strings.TrimPrefix(c.GetHeader("Authorization"), "Bearer ")
+	if claims, err := parseToken(tokenString); err == nil {
+		recordAuthEvent(c, AuthEventLogout, claims.UserID, claims.Username, true, AuthReasonOK)
+	} else {
+		recordAuthEvent(c, AuthEventLogout, 0, "", false, AuthReasonInvalidToken)
+	}
+
 	successResponse(c, gin.H{
 		"message": "登出成功",
 	})
@@ -265,7 +379,9 @@ func getCurrentUser(c *gin.Context) {
 
 	// 不返回密码
 	user.Password = ""
-	successResponse(c, user)
+	users := []User{user}
+	attachUserCustomFields(users, FieldVisibilitySelfEditable, FieldVisibilityAdminOnly)
+	successResponse(c, users[0])
 }
 
 // 修改密码
@@ -303,8 +419,13 @@ func changePassword(c *gin.Context) {
 		return
 	}
 
-	// 更新密码
-	db.Model(&user).Update("password", hashedPassword)
+	// 更新密码，并清除强制修改密码标记
+	auditDB(c).Model(&user).Updates(map[string]interface{}{
+		"password":             hashedPassword,
+		"must_change_password": false,
+		"version":              gorm.Expr("version + 1"),
+	})
+	authUsers.invalidate(user.ID)
 	
 	successResponse(c, gin.H{
 		"message": "密码修改成功",
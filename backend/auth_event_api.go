@@ -0,0 +1,175 @@
+package main
+
+import (
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// 根据查询参数构建认证事件查询
+func buildAuthEventQuery(c *gin.Context) *gorm.DB {
+	query := db.Model(&AuthEvent{})
+
+	if username := c.Query("username"); username != "" {
+		query = query.Where("username LIKE ?", "%"+username+"%")
+	}
+	if userID := c.Query("user_id"); userID != "" {
+		query = query.Where("user_id = ?", userID)
+	}
+	if event := c.Query("event"); event != "" {
+		query = query.Where("event = ?", event)
+	}
+	if success := c.Query("success"); success != "" {
+		query = query.Where("success = ?", success == "true")
+	}
+	if reason := c.Query("reason"); reason != "" {
+		query = query.Where("reason = ?", reason)
+	}
+	if ip := c.Query("ip"); ip != "" {
+		query = query.Where("ip = ?", ip)
+	}
+	if startDate := c.Query("start_date"); startDate != "" {
+		query = query.Where("created_at >= ?", startDate)
+	}
+	if endDate := c.Query("end_date"); endDate != "" {
+		query = query.Where("created_at <= ?", endDate)
+	}
+	return query
+}
+
+// 获取认证事件列表
+func getAuthEvents(c *gin.Context) {
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	pageSize, _ := strconv.Atoi(c.DefaultQuery("page_size", "20"))
+	if page < 1 {
+		page = 1
+	}
+	if pageSize < 1 || pageSize > 100 {
+		pageSize = 20
+	}
+
+	query := buildAuthEventQuery(c)
+
+	var total int64
+	query.Count(&total)
+
+	var events []AuthEvent
+	offset := (page - 1) * pageSize
+	result := query.Order("created_at DESC, id DESC").Limit(pageSize).Offset(offset).Find(&events)
+	if result.Error != nil {
+		errorResponse(c, 500, "获取认证事件失败")
+		return
+	}
+
+	successResponse(c, gin.H{
+		"events":    events,
+		"total":     total,
+		"page":      page,
+		"page_size": pageSize,
+		"pages":     (total + int64(pageSize) - 1) / int64(pageSize),
+	})
+}
+
+// 获取认证事件统计信息
+func getAuthEventStats(c *gin.Context) {
+	var stats struct {
+		TotalEvents    int64                    `json:"total_events"`
+		TodayLogins    int64                    `json:"today_logins"`
+		TodayFailures  int64                    `json:"today_failures"`
+		WeekFailures   int64                    `json:"week_failures"`
+		EventStats     []map[string]interface{} `json:"event_stats"`
+		FailureReasons []map[string]interface{} `json:"failure_reasons"`
+		TopFailedUsers []map[string]interface{} `json:"top_failed_users"`
+		TopFailedIPs   []map[string]interface{} `json:"top_failed_ips"`
+		DailyStats     []map[string]interface{} `json:"daily_stats"`
+		RetentionDays  int                      `json:"retention_days"`
+	}
+
+	// 总事件数
+	db.Model(&AuthEvent{}).Count(&stats.TotalEvents)
+
+	// 今日成功登录数和失败数
+	db.Model(&AuthEvent{}).Where("event = ? AND success = ? AND DATE(created_at) = DATE('now')", AuthEventLogin, true).Count(&stats.TodayLogins)
+	db.Model(&AuthEvent{}).Where("success = ? AND DATE(created_at) = DATE('now')", false).Count(&stats.TodayFailures)
+
+	// 本周失败数
+	db.Model(&AuthEvent{}).Where("success = ? AND created_at >= DATE('now', '-7 day')", false).Count(&stats.WeekFailures)
+
+	// 按事件类型和结果统计
+	db.Model(&AuthEvent{}).
+		Select("event, success, COUNT(*) as count").
+		Group("event, success").
+		Scan(&stats.EventStats)
+
+	// 按失败原因统计
+	db.Model(&AuthEvent{}).
+		Select("reason, COUNT(*) as count").
+		Where("success = ?", false).
+		Group("reason").
+		Order("count DESC").
+		Scan(&stats.FailureReasons)
+
+	// 近7天失败次数最多的用户名和IP（前10名）
+	db.Model(&AuthEvent{}).
+		Select("username, COUNT(*) as count").
+		Where("success = ? AND created_at >= DATE('now', '-7 day')", false).
+		Group("username").
+		Order("count DESC").
+		Limit(10).
+		Scan(&stats.TopFailedUsers)
+	db.Model(&AuthEvent{}).
+		Select("ip, COUNT(*) as count").
+		Where("success = ? AND created_at >= DATE('now', '-7 day')", false).
+		Group("ip").
+		Order("count DESC").
+		Limit(10).
+		Scan(&stats.TopFailedIPs)
+
+	// 近7天每日成功/失败数
+	db.Model(&AuthEvent{}).
+		Select("DATE(created_at) as date, SUM(CASE WHEN success THEN 1 ELSE 0 END) as success, SUM(CASE WHEN success THEN 0 ELSE 1 END) as failure").
+		Where("created_at >= DATE('now', '-7 day')").
+		Group("DATE(created_at)").
+		Order("date").
+		Scan(&stats.DailyStats)
+
+	stats.RetentionDays = getAuthEventRetentionDays()
+
+	successResponse(c, stats)
+}
+
+// 立即清理超过保留期限的认证事件
+func clearOldAuthEvents(c *gin.Context) {
+	days := getAuthEventRetentionDays()
+	if days <= 0 {
+		errorResponse(c, 400, "未设置认证事件保留天数")
+		return
+	}
+
+	successResponse(c, gin.H{
+		"message":        "清理过期认证事件成功",
+		"deleted":        cleanupAuthEvents(),
+		"retention_days": days,
+	})
+}
+
+// 获取当前用户的登录记录
+func getMyAuthEvents(c *gin.Context) {
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "20"))
+	if limit < 1 || limit > 100 {
+		limit = 20
+	}
+
+	var events []AuthEvent
+	result := db.Where("user_id = ?", c.GetUint("user_id")).
+		Order("created_at DESC, id DESC").
+		Limit(limit).
+		Find(&events)
+	if result.Error != nil {
+		errorResponse(c, 500, "获取登录记录失败")
+		return
+	}
+
+	successResponse(c, events)
+}
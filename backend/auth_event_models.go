@@ -0,0 +1,239 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 认证事件类型
+const (
+	AuthEventLogin    = "login"
+	AuthEventLogout   = "logout"
+	AuthEventRegister = "register"
+)
+
+// 认证事件结果原因
+const (
+	AuthReasonOK            = "ok"
+	AuthReasonBadRequest    = "bad_request"    // 请求参数错误
+	AuthReasonUnknownUser   = "unknown_user"   // 用户不存在
+	AuthReasonBadPassword   = "bad_password"   // 密码错误
+	AuthReasonLocked        = "locked"         // 账户已锁定（包括本次失败触发锁定）
+	AuthReasonPending       = "pending"        // 账户待激活
+	AuthReasonSuspended     = "suspended"      // 账户已停用
+	AuthReasonExpired       = "expired"        // 账户已过期
+	AuthReasonArchived      = "archived"       // 账户已归档
+	AuthReasonDuplicateUser = "duplicate_user" // 注册时用户名或邮箱已存在
+	AuthReasonInvalidToken  = "invalid_token"  // 登出时令牌无效
+	AuthReasonServerError   = "server_error"   // 服务端错误
+)
+
+// 认证事件日志（登录、登出、注册，包括失败的尝试）
+type AuthEvent struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	Event     string    `json:"event" gorm:"not null;index"` // 事件类型：login, logout, register
+	Success   bool      `json:"success" gorm:"index"`        // 是否成功
+	Reason    string    `json:"reason" gorm:"index"`         // 结果原因
+	UserID    uint      `json:"user_id" gorm:"index"`        // 用户ID，用户不存在时为0
+	Username  string    `json:"username" gorm:"index"`       // 尝试使用的用户名
+	IP        string    `json:"ip" gorm:"index"`             // 客户端IP
+	UserAgent string    `json:"user_agent"`                  // 用户代理
+	GeoHint   string    `json:"geo_hint"`                    // 位置提示：代理提供的国家代码，或 loopback/private/public
+	CreatedAt time.Time `json:"created_at" gorm:"index"`
+}
+
+// 初始化认证事件日志
+func initAuthEventSystem() error {
+	// 自动迁移数据库
+	return db.AutoMigrate(&AuthEvent{})
+}
+
+// 记录认证事件
+func recordAuthEvent(c *gin.Context, event string, userID uint, username string, success bool, reason string) {
+	authEvent := AuthEvent{
+		Event:     event,
+		Success:   success,
+		Reason:    reason,
+		UserID:    userID,
+		Username:  username,
+		IP:        c.ClientIP(),
+		UserAgent: c.Request.UserAgent(),
+		GeoHint:   geoHint(c),
+		CreatedAt: time.Now(),
+	}
+
+	// 异步写入，避免影响登录响应；写入器未启动时同步写入
+	if authEventWriter != nil {
+		authEventWriter.Write(&authEvent)
+		return
+	}
+	writeAuthEvents([]*AuthEvent{&authEvent})
+}
+
+// 批量写入认证事件
+func writeAuthEvents(events []*AuthEvent) {
+	if err := db.Create(events).Error; err != nil {
+		appLogger.Error("failed to record auth events", "count", len(events), "error", err)
+	}
+}
+
+// 单批最多写入的认证事件数
+const authEventBatchSize = 100
+
+// 认证事件异步写入器：有界队列 + 单个写入协程，队列满时同步写入，不丢弃事件
+type AuthEventWriter struct {
+	queue chan *AuthEvent
+	done  chan struct{}
+
+	closeMu sync.RWMutex
+	closed  bool
+}
+
+// 全局认证事件写入器，未启动时同步写入
+var authEventWriter *AuthEventWriter
+
+// 创建并启动认证事件写入器
+func NewAuthEventWriter(queueSize int) *AuthEventWriter {
+	if queueSize <= 0 {
+		queueSize = 1000
+	}
+	w := &AuthEventWriter{
+		queue: make(chan *AuthEvent, queueSize),
+		done:  make(chan struct{}),
+	}
+	go w.run()
+	return w
+}
+
+// 启动全局认证事件写入器
+func startAuthEventWriter() {
+	authEventWriter = NewAuthEventWriter(1000)
+}
+
+// 写入一条认证事件
+func (w *AuthEventWriter) Write(event *AuthEvent) {
+	w.closeMu.RLock()
+	defer w.closeMu.RUnlock()
+	if !w.closed {
+		select {
+		case w.queue <- event:
+			return
+		default:
+		}
+	}
+	writeAuthEvents([]*AuthEvent{event})
+}
+
+// 写入协程：取出队列中已有的事件批量写入
+func (w *AuthEventWriter) run() {
+	defer close(w.done)
+	for event := range w.queue {
+		batch := []*AuthEvent{event}
+	drain:
+		for len(batch) < authEventBatchSize {
+			select {
+			case next, ok := <-w.queue:
+				if !ok {
+					break drain
+				}
+				batch = append(batch, next)
+			default:
+				break drain
+			}
+		}
+		writeAuthEvents(batch)
+	}
+}
+
+// 关闭写入器，等待队列中的事件写完
+func (w *AuthEventWriter) Close(timeout time.Duration) error {
+	w.closeMu.Lock()
+	if !w.closed {
+		w.closed = true
+		close(w.queue)
+	}
+	w.closeMu.Unlock()
+
+	select {
+	case <-w.done:
+		return nil
+	case <-time.After(timeout):
+		return errors.New("auth event writer close timeout")
+	}
+}
+
+// 推测请求来源位置：直接连接来自可信代理时使用其提供的国家代码，否则按IP类型区分
+func geoHint(c *gin.Context) string {
+	if isGeoHeaderTrustedProxy(c.RemoteIP()) {
+		for _, header := range []string{"CF-IPCountry", "X-Country-Code", "X-Geo-Country"} {
+			if country := strings.TrimSpace(c.GetHeader(header)); country != "" {
+				return strings.ToUpper(country)
+			}
+		}
+	}
+
+	ip := net.ParseIP(c.ClientIP())
+	switch {
+	case ip == nil:
+		return ""
+	case ip.IsLoopback():
+		return "loopback"
+	case ip.IsPrivate() || ip.IsLinkLocalUnicast():
+		return "private"
+	default:
+		return "public"
+	}
+}
+
+// 检查地址是否在可信代理列表中（geo_header_trusted_proxies）
+func isGeoHeaderTrustedProxy(remoteIP string) bool {
+	ip := net.ParseIP(remoteIP)
+	if ip == nil {
+		return false
+	}
+	for _, item := range configCache.GetList("geo_header_trusted_proxies", "") {
+		if _, network, err := net.ParseCIDR(item); err == nil {
+			if network.Contains(ip) {
+				return true
+			}
+		} else if trusted := net.ParseIP(item); trusted != nil && trusted.Equal(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// 获取认证事件保留天数
+func getAuthEventRetentionDays() int {
+	return configCache.GetInt("auth_event_retention_days", 90)
+}
+
+// 清理超过保留期限的认证事件（保留天数小于等于0时不自动清理）
+func cleanupAuthEvents() int64 {
+	days := getAuthEventRetentionDays()
+	if days <= 0 {
+		return 0
+	}
+	cutoff := time.Now().AddDate(0, 0, -days)
+
+	result := db.Where("created_at < ?", cutoff).Delete(&AuthEvent{})
+	if result.Error != nil {
+		appLogger.Error("failed to clean up auth events", "error", result.Error)
+		return 0
+	}
+	if result.RowsAffected > 0 {
+		appLogger.Info("removed expired auth events", "count", result.RowsAffected)
+	}
+	return result.RowsAffected
+}
+
+// 启动认证事件定时清理任务
+func startAuthEventCleaner(interval time.Duration) {
+	jobRunner.Every("auth_event_cleanup", interval, func(context.Context) { cleanupAuthEvents() })
+}
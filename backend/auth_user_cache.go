@@ -0,0 +1,72 @@
+package main
+
+import (
+	"sync"
+	"time"
+)
+
+// 认证中间件需要检查的用户信息（令牌只证明身份，用户信息以数据库为准）
+type authUserInfo struct {
+	State              string
+	MustChangePassword bool
+	loadedAt           time.Time
+}
+
+// 认证用户信息缓存：避免每个请求都查询数据库，修改用户后调用 invalidate 立即生效
+type authUserCache struct {
+	mu      sync.Mutex
+	ttl     time.Duration
+	entries map[uint]authUserInfo
+}
+
+var authUsers = &authUserCache{ttl: 30 * time.Second, entries: make(map[uint]authUserInfo)}
+
+// 获取用户信息，用户不存在（或已删除）时返回 false
+func (ac *authUserCache) get(userID uint) (authUserInfo, bool) {
+	ac.mu.Lock()
+	info, ok := ac.entries[userID]
+	ac.mu.Unlock()
+	if ok && time.Since(info.loadedAt) < ac.ttl {
+		return info, true
+	}
+
+	var user User
+	if err := db.Select("id", "state", "must_change_password").First(&user, userID).Error; err != nil {
+		ac.invalidate(userID)
+		return authUserInfo{}, false
+	}
+	info = authUserInfo{
+		State:              user.State,
+		MustChangePassword: user.MustChangePassword,
+		loadedAt:           time.Now(),
+	}
+	ac.mu.Lock()
+	ac.entries[userID] = info
+	ac.mu.Unlock()
+	return info, true
+}
+
+// 用户信息修改后使缓存失效
+func (ac *authUserCache) invalidate(userIDs ...uint) {
+	ac.mu.Lock()
+	for _, id := range userIDs {
+		delete(ac.entries, id)
+	}
+	ac.mu.Unlock()
+}
+
+// 非正常状态的用户已签发的令牌同样失效
+var inactiveUserMessages = map[string]string{
+	UserStatePending:   "账户待激活，请联系管理员",
+	UserStateLocked:    "账户已锁定",
+	UserStateSuspended: "账户已被停用",
+	UserStateExpired:   "账户已过期，请联系管理员",
+	UserStateArchived:  "账户已归档",
+}
+
+// 需要修改密码时仍允许访问的接口
+var mustChangePasswordRoutes = map[string]bool{
+	"GET /api/me":               true,
+	"POST /api/change-password": true,
+	"GET /api/my-permissions":   true,
+}
@@ -1,10 +1,12 @@
 package main
 
 import (
+	"encoding/json"
 	"errors"
 	"strconv"
 
 	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
 )
 
 // 获取系统配置列表（管理员）
@@ -24,7 +26,8 @@ func getSystemConfigs(c *gin.Context) {
 		return
 	}
 
-	// 按分类分组
+	// 附带约束并按分类分组
+	configs = withConfigConstraints(configs)
 	groupedConfigs := make(map[string][]SystemConfig)
 	for _, config := range configs {
 		groupedConfigs[config.Category] = append(groupedConfigs[config.Category], config)
@@ -66,6 +69,7 @@ func getSystemConfigByKey(c *gin.Context) {
 		return
 	}
 
+	config.Constraints = configConstraintsOf(config)
 	successResponse(c, config)
 }
 
@@ -87,7 +91,8 @@ func updateSystemConfig(c *gin.Context) {
 	}
 
 	var updateData struct {
-		Value string `json:"value" binding:"required"`
+		Value   string `json:"value" binding:"required"`
+		Comment string `json:"comment"` // 修改说明，记录在配置修订中
 	}
 	
 	if err := c.ShouldBindJSON(&updateData); err != nil {
@@ -96,19 +101,31 @@ func updateSystemConfig(c *gin.Context) {
 	}
 
 	// 验证配置值
-	if err := validateConfigValue(config.Type, updateData.Value); err != nil {
+	if err := validateSystemConfigValue(config, updateData.Value); err != nil {
 		errorResponse(c, 400, "配置值格式错误: "+err.Error())
 		return
 	}
 
-	// 更新配置
+	// 更新配置并记录修订
+	oldValue := config.Value
 	config.Value = updateData.Value
-	result = db.Save(&config)
-	if result.Error != nil {
+	batch := newConfigRevisionBatch(c, updateData.Comment)
+	err := auditDB(c).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Save(&config).Error; err != nil {
+			return err
+		}
+		if oldValue == config.Value {
+			return nil
+		}
+		return batch.record(tx, ConfigRevisionUpdate, config.Key, oldValue, config.Value, "")
+	})
+	if err != nil {
 		errorResponse(c, 500, "更新配置失败")
 		return
 	}
+	reloadConfigCache()
 
+	config.Constraints = configConstraintsOf(config)
 	successResponse(c, config)
 }
 
@@ -116,6 +133,7 @@ func updateSystemConfig(c *gin.Context) {
 func batchUpdateSystemConfigs(c *gin.Context) {
 	var req struct {
 		Configs map[string]string `json:"configs" binding:"required"`
+		Comment string            `json:"comment"` // 修改说明，记录在配置修订中
 	}
 	
 	if err := c.ShouldBindJSON(&req); err != nil {
@@ -123,8 +141,9 @@ func batchUpdateSystemConfigs(c *gin.Context) {
 		return
 	}
 
-	// 开始事务
-	tx := db.Begin()
+	// 开始事务（同一批修改共用一个修订批次，可整体回滚）
+	tx := auditDB(c).Begin()
+	batch := newConfigRevisionBatch(c, req.Comment)
 
 	for key, value := range req.Configs {
 		var config SystemConfig
@@ -143,27 +162,40 @@ func batchUpdateSystemConfigs(c *gin.Context) {
 		}
 
 		// 验证配置值
-		if err := validateConfigValue(config.Type, value); err != nil {
+		if err := validateSystemConfigValue(config, value); err != nil {
 			tx.Rollback()
 			errorResponse(c, 400, "配置 "+key+" 值格式错误: "+err.Error())
 			return
 		}
 
-		// 更新配置
+		// 更新配置并记录修订
+		oldValue := config.Value
 		config.Value = value
 		if err := tx.Save(&config).Error; err != nil {
 			tx.Rollback()
 			errorResponse(c, 500, "更新配置失败")
 			return
 		}
+		if oldValue != value {
+			if err := batch.record(tx, ConfigRevisionUpdate, key, oldValue, value, ""); err != nil {
+				tx.Rollback()
+				errorResponse(c, 500, "更新配置失败")
+				return
+			}
+		}
 	}
 
 	// 提交事务
-	tx.Commit()
+	if err := tx.Commit().Error; err != nil {
+		errorResponse(c, 500, "更新配置失败")
+		return
+	}
+	reloadConfigCache()
 
 	successResponse(c, gin.H{
-		"message": "批量更新配置成功",
-		"updated": len(req.Configs),
+		"message":  "批量更新配置成功",
+		"updated":  len(req.Configs),
+		"batch_id": batch.id,
 	})
 }
 
@@ -175,25 +207,71 @@ func createSystemConfig(c *gin.Context) {
 		return
 	}
 
-	// 检查配置键是否存在
+	// 检查配置键是否存在（已删除的配置键由唯一索引占用，重新创建时复用原记录，与修订回滚一致）
+	newConfig.ID = 0
 	var existingConfig SystemConfig
-	if err := db.Where("key = ?", newConfig.Key).First(&existingConfig).Error; err == nil {
-		errorResponse(c, 400, "配置键已存在")
+	if err := db.Unscoped().Where("key = ?", newConfig.Key).First(&existingConfig).Error; err == nil {
+		if !existingConfig.DeletedAt.Valid {
+			errorResponse(c, 400, "配置键已存在")
+			return
+		}
+		newConfig.ID = existingConfig.ID
+		newConfig.CreatedAt = existingConfig.CreatedAt
+	}
+	newConfig.DeletedAt = gorm.DeletedAt{}
+
+	// 有定义的配置以定义中的约束和元数据为准（与 initSystemConfig 一致），自定义配置检查传入的约束
+	definition, defined := findConfigDefinition(newConfig.Key)
+	if defined {
+		newConfig.Constraints = nil
+		newConfig.Type = definition.Type
+		newConfig.Category = definition.Category
+		newConfig.DisplayName = definition.DisplayName
+		newConfig.Description = definition.Description
+		newConfig.IsPublic = definition.IsPublic
+		newConfig.IsEditable = definition.IsEditable
+		// 不可编辑的配置只能恢复为默认值
+		if !definition.IsEditable {
+			newConfig.Value = definition.Default
+		}
+	} else if err := newConfig.Constraints.validate(newConfig.Type); err != nil {
+		errorResponse(c, 400, "配置约束错误: "+err.Error())
 		return
 	}
 
 	// 验证配置值
-	if err := validateConfigValue(newConfig.Type, newConfig.Value); err != nil {
+	if err := validateSystemConfigValue(newConfig, newConfig.Value); err != nil {
 		errorResponse(c, 400, "配置值格式错误: "+err.Error())
 		return
 	}
 
-	result := db.Create(&newConfig)
-	if result.Error != nil {
+	batch := newConfigRevisionBatch(c, "")
+	err := auditDB(c).Transaction(func(tx *gorm.DB) error {
+		if newConfig.ID != 0 {
+			if err := tx.Unscoped().Save(&newConfig).Error; err != nil {
+				return err
+			}
+			return batch.record(tx, ConfigRevisionCreate, newConfig.Key, "", newConfig.Value, "")
+		}
+		isEditable := newConfig.IsEditable
+		if err := tx.Create(&newConfig).Error; err != nil {
+			return err
+		}
+		// is_editable 字段有默认值，false 不会在创建时写入
+		if !isEditable {
+			if err := tx.Model(&newConfig).Update("is_editable", false).Error; err != nil {
+				return err
+			}
+		}
+		return batch.record(tx, ConfigRevisionCreate, newConfig.Key, "", newConfig.Value, "")
+	})
+	if err != nil {
 		errorResponse(c, 500, "创建配置失败")
 		return
 	}
+	reloadConfigCache()
 
+	newConfig.Constraints = configConstraintsOf(newConfig)
 	successResponse(c, newConfig)
 }
 
@@ -214,15 +292,40 @@ func deleteSystemConfig(c *gin.Context) {
 		return
 	}
 
-	result = db.Delete(&config)
-	if result.Error != nil {
+	batch := newConfigRevisionBatch(c, "")
+	err := auditDB(c).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Delete(&config).Error; err != nil {
+			return err
+		}
+		return batch.record(tx, ConfigRevisionDelete, config.Key, config.Value, "", "")
+	})
+	if err != nil {
 		errorResponse(c, 500, "删除配置失败")
 		return
 	}
+	reloadConfigCache()
 
 	successResponse(c, gin.H{"message": "配置删除成功"})
 }
 
+// 重新加载配置缓存（直接修改数据库后使用）
+func reloadSystemConfigs(c *gin.Context) {
+	changes, err := configCache.Reload()
+	if err != nil {
+		errorResponse(c, 500, "重新加载配置失败")
+		return
+	}
+	if changes == nil {
+		changes = []ConfigChange{}
+	}
+	count, loadedAt := configCache.Stats()
+	successResponse(c, gin.H{
+		"changes":   changes,
+		"total":     count,
+		"loaded_at": loadedAt,
+	})
+}
+
 // 验证配置值格式
 func validateConfigValue(configType, value string) error {
 	switch configType {
@@ -236,8 +339,9 @@ func validateConfigValue(configType, value string) error {
 			return errors.New("布尔值必须为 true 或 false")
 		}
 	case "json":
-		// 这里可以添加JSON格式验证
-		// 暂时跳过，因为需要导入encoding/json包
+		if !json.Valid([]byte(value)) {
+			return errors.New("JSON格式错误")
+		}
 	}
 	return nil
 }
@@ -245,7 +349,7 @@ func validateConfigValue(configType, value string) error {
 // 重置配置到默认值
 func resetSystemConfigToDefault(c *gin.Context) {
 	key := c.Param("key")
-	
+
 	var config SystemConfig
 	result := db.Where("key = ?", key).First(&config)
 	if result.Error != nil {
@@ -259,10 +363,209 @@ func resetSystemConfigToDefault(c *gin.Context) {
 		return
 	}
 
-	// 这里需要一个默认值的映射，或者从初始化数据中获取
-	// 为简化，暂时返回成功信息
+	definition, ok := findConfigDefinition(key)
+	if !ok {
+		errorResponse(c, 400, "该配置没有默认值")
+		return
+	}
+
+	var req struct {
+		Comment string `json:"comment"`
+	}
+	c.ShouldBindJSON(&req)
+
+	batchID, reset, ok := resetConfigsToDefault(c, []ConfigDefinition{definition}, req.Comment)
+	if !ok {
+		return
+	}
+
+	db.Where("key = ?", key).First(&config)
+	config.Constraints = configConstraintsOf(config)
+	successResponse(c, gin.H{
+		"message":  "配置重置成功",
+		"config":   config,
+		"reset":    len(reset) > 0,
+		"batch_id": batchID,
+	})
+}
+
+// 重置一个分类下的全部配置
+func resetConfigCategory(c *gin.Context) {
+	category := c.Param("category")
+
+	var definitions []ConfigDefinition
+	for _, definition := range configDefinitions {
+		if definition.Category == category {
+			definitions = append(definitions, definition)
+		}
+	}
+	if len(definitions) == 0 {
+		errorResponse(c, 404, "配置分类不存在")
+		return
+	}
+
+	var req struct {
+		Comment string `json:"comment"`
+	}
+	c.ShouldBindJSON(&req)
+
+	batchID, reset, ok := resetConfigsToDefault(c, definitions, req.Comment)
+	if !ok {
+		return
+	}
+	successResponse(c, gin.H{
+		"message":  "分类配置重置成功",
+		"category": category,
+		"reset":    reset,
+		"batch_id": batchID,
+	})
+}
+
+// 重置全部配置（需要 confirm 确认）
+func resetAllConfigs(c *gin.Context) {
+	var req struct {
+		Confirm bool   `json:"confirm"`
+		Comment string `json:"comment"`
+	}
+	c.ShouldBindJSON(&req)
+	if !req.Confirm {
+		errorResponse(c, 400, "重置全部配置需要确认（confirm 为 true）")
+		return
+	}
+
+	batchID, reset, ok := resetConfigsToDefault(c, configDefinitions, req.Comment)
+	if !ok {
+		return
+	}
+	successResponse(c, gin.H{
+		"message":  "全部配置重置成功",
+		"reset":    reset,
+		"batch_id": batchID,
+	})
+}
+
+// 将可编辑的配置恢复为默认值，记录为一个修订批次（可整体回滚）
+// 返回批次ID和实际修改的配置，失败时已写入错误响应
+func resetConfigsToDefault(c *gin.Context, definitions []ConfigDefinition, comment string) (string, []string, bool) {
+	if comment == "" {
+		comment = "重置为默认值"
+	}
+
+	var targets []configTarget
+	for _, definition := range definitions {
+		if definition.IsEditable {
+			targets = append(targets, configTarget{Key: definition.Key, Value: definition.Default})
+		}
+	}
+	changes, err := planConfigTargets(targets)
+	if err != nil {
+		errorResponse(c, 400, err.Error())
+		return "", nil, false
+	}
+
+	reset := []string{}
+	if len(changes) == 0 {
+		return "", reset, true
+	}
+	batch, err := applyConfigTargets(c, changes, comment, "")
+	if err != nil {
+		errorResponse(c, 500, "重置配置失败")
+		return "", nil, false
+	}
+	for _, change := range changes {
+		reset = append(reset, change.config.Key)
+	}
+	return batch.id, reset, true
+}
+
+// 配置定义及当前值
+type configDefinitionStatus struct {
+	ConfigDefinition
+	Value     string `json:"value"`
+	Exists    bool   `json:"exists"`
+	IsDefault bool   `json:"is_default"`
+}
+
+// 获取配置定义（默认值、类型、约束）
+func getConfigDefinitions(c *gin.Context) {
+	category := c.Query("category")
+
+	items := []configDefinitionStatus{}
+	for _, definition := range configDefinitions {
+		if category != "" && definition.Category != category {
+			continue
+		}
+		item := configDefinitionStatus{ConfigDefinition: definition}
+		if value, ok := configCache.lookup(definition.Key); ok {
+			item.Value = maskConfigValue(definition.Key, value)
+			item.Exists = true
+			item.IsDefault = value == definition.Default
+		}
+		item.Default = maskConfigValue(definition.Key, definition.Default)
+		items = append(items, item)
+	}
+
+	successResponse(c, gin.H{
+		"definitions": items,
+		"total":       len(items),
+	})
+}
+
+// 获取配置定义与数据库记录的差异
+func getConfigDrift(c *gin.Context) {
+	drifts, err := detectConfigDrift()
+	if err != nil {
+		errorResponse(c, 500, "检查配置差异失败")
+		return
+	}
+	successResponse(c, gin.H{
+		"drifts": drifts,
+		"total":  len(drifts),
+	})
+}
+
+// 按配置定义同步数据库中的元数据（类型、分类、名称、描述、公开、可编辑），不修改配置值
+func syncConfigDefinitions(c *gin.Context) {
+	drifts, err := detectConfigDrift()
+	if err != nil {
+		errorResponse(c, 500, "检查配置差异失败")
+		return
+	}
+
+	synced := []string{}
+	seen := make(map[string]bool)
+	err = auditDB(c).Transaction(func(tx *gorm.DB) error {
+		for _, drift := range drifts {
+			if drift.Kind != "metadata" || seen[drift.Key] {
+				continue
+			}
+			seen[drift.Key] = true
+			definition, _ := findConfigDefinition(drift.Key)
+			err := tx.Model(&SystemConfig{}).Where("key = ?", drift.Key).Updates(map[string]interface{}{
+				"type":         definition.Type,
+				"category":     definition.Category,
+				"display_name": definition.DisplayName,
+				"description":  definition.Description,
+				"is_public":    definition.IsPublic,
+				"is_editable":  definition.IsEditable,
+			}).Error
+			if err != nil {
+				return err
+			}
+			synced = append(synced, drift.Key)
+		}
+		return nil
+	})
+	if err != nil {
+		errorResponse(c, 500, "同步配置定义失败")
+		return
+	}
+	if len(synced) > 0 {
+		reloadConfigCache()
+	}
+
 	successResponse(c, gin.H{
-		"message": "配置重置成功",
-		"config":  config,
+		"synced": synced,
+		"total":  len(synced),
 	})
-} 
\ No newline at end of file
+}
@@ -0,0 +1,215 @@
+package main
+
+import (
+	"encoding/json"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 配置变更
+type ConfigChange struct {
+	Key      string `json:"key"`
+	OldValue string `json:"old_value"`
+	NewValue string `json:"new_value"`
+	Deleted  bool   `json:"deleted"` // 配置项被删除，之后读取返回默认值
+}
+
+// 配置变更回调
+type ConfigSubscriber func(change ConfigChange)
+
+type configSubscription struct {
+	keys map[string]bool // 为空表示订阅全部配置
+	fn   ConfigSubscriber
+}
+
+// 系统配置缓存：启动时从数据库加载，配置修改提交后刷新，并通知订阅者
+type ConfigCache struct {
+	reloadMu sync.Mutex // 串行执行 Reload：并发刷新时较早读取的旧数据不会覆盖较新的数据
+	mu       sync.RWMutex
+	values   map[string]string
+	loadedAt time.Time
+
+	subMu         sync.Mutex
+	subscriptions []configSubscription
+}
+
+// 全局配置缓存
+var configCache = &ConfigCache{}
+
+// 从数据库重新加载全部配置，返回变更的配置项（初次加载不通知订阅者）
+// 读取、替换和通知都在 reloadMu 内完成，订阅者按提交顺序收到变更，回调中不能再调用 Reload
+func (cc *ConfigCache) Reload() ([]ConfigChange, error) {
+	cc.reloadMu.Lock()
+	defer cc.reloadMu.Unlock()
+
+	var configs []SystemConfig
+	if err := db.Select("key", "value").Find(&configs).Error; err != nil {
+		return nil, err
+	}
+	values := make(map[string]string, len(configs))
+	for _, config := range configs {
+		values[config.Key] = config.Value
+	}
+
+	cc.mu.Lock()
+	old := cc.values
+	cc.values = values
+	cc.loadedAt = time.Now()
+	cc.mu.Unlock()
+
+	if old == nil {
+		return nil, nil
+	}
+
+	var changes []ConfigChange
+	for key, value := range values {
+		if oldValue, ok := old[key]; !ok || oldValue != value {
+			changes = append(changes, ConfigChange{Key: key, OldValue: oldValue, NewValue: value})
+		}
+	}
+	for key, oldValue := range old {
+		if _, ok := values[key]; !ok {
+			changes = append(changes, ConfigChange{Key: key, OldValue: oldValue, Deleted: true})
+		}
+	}
+	cc.notify(changes)
+	return changes, nil
+}
+
+// 配置修改提交后刷新缓存（失败时只记录日志，下次刷新会补上）
+func reloadConfigCache() {
+	if _, err := configCache.Reload(); err != nil {
+		appLogger.Error("failed to reload config cache", "error", err)
+	}
+}
+
+// 订阅配置变更，不指定 keys 时订阅全部配置
+func (cc *ConfigCache) Subscribe(fn ConfigSubscriber, keys ...string) {
+	sub := configSubscription{fn: fn}
+	if len(keys) > 0 {
+		sub.keys = make(map[string]bool, len(keys))
+		for _, key := range keys {
+			sub.keys[key] = true
+		}
+	}
+	cc.subMu.Lock()
+	cc.subscriptions = append(cc.subscriptions, sub)
+	cc.subMu.Unlock()
+}
+
+// 通知订阅者（按订阅顺序同步调用，回调中的 panic 不影响其他订阅者）
+func (cc *ConfigCache) notify(changes []ConfigChange) {
+	if len(changes) == 0 {
+		return
+	}
+	cc.subMu.Lock()
+	subscriptions := append([]configSubscription(nil), cc.subscriptions...)
+	cc.subMu.Unlock()
+
+	for _, change := range changes {
+		appLogger.Info("system config changed", "key", change.Key, "deleted", change.Deleted)
+		for _, sub := range subscriptions {
+			if sub.keys != nil && !sub.keys[change.Key] {
+				continue
+			}
+			func() {
+				defer func() {
+					if err := recover(); err != nil {
+						appLogger.Error("config subscriber panicked", "key", change.Key, "panic", err)
+					}
+				}()
+				sub.fn(change)
+			}()
+		}
+	}
+}
+
+// 读取配置原始值
+func (cc *ConfigCache) lookup(key string) (string, bool) {
+	cc.mu.RLock()
+	defer cc.mu.RUnlock()
+	value, ok := cc.values[key]
+	return value, ok
+}
+
+// 字符串配置，不存在时返回默认值
+func (cc *ConfigCache) Get(key, defaultValue string) string {
+	if value, ok := cc.lookup(key); ok {
+		return value
+	}
+	return defaultValue
+}
+
+// 整数配置，不存在或格式错误时返回默认值
+func (cc *ConfigCache) GetInt(key string, defaultValue int) int {
+	return int(cc.GetInt64(key, int64(defaultValue)))
+}
+
+func (cc *ConfigCache) GetInt64(key string, defaultValue int64) int64 {
+	value, ok := cc.lookup(key)
+	if !ok {
+		return defaultValue
+	}
+	n, err := strconv.ParseInt(strings.TrimSpace(value), 10, 64)
+	if err != nil {
+		appLogger.Warn("invalid integer config, using default", "key", key, "value", value, "default", defaultValue)
+		return defaultValue
+	}
+	return n
+}
+
+// 布尔配置，不存在或格式错误时返回默认值
+func (cc *ConfigCache) GetBool(key string, defaultValue bool) bool {
+	value, ok := cc.lookup(key)
+	if !ok {
+		return defaultValue
+	}
+	b, err := strconv.ParseBool(strings.TrimSpace(value))
+	if err != nil {
+		appLogger.Warn("invalid boolean config, using default", "key", key, "value", value, "default", defaultValue)
+		return defaultValue
+	}
+	return b
+}
+
+// 时长配置：纯数字按 unit 计（如 session_timeout 的秒），也支持 30m、1h30m 这样的写法
+func (cc *ConfigCache) GetDuration(key string, unit, defaultValue time.Duration) time.Duration {
+	value, ok := cc.lookup(key)
+	if !ok {
+		return defaultValue
+	}
+	value = strings.TrimSpace(value)
+	if n, err := strconv.ParseInt(value, 10, 64); err == nil {
+		return time.Duration(n) * unit
+	}
+	if d, err := time.ParseDuration(value); err == nil {
+		return d
+	}
+	appLogger.Warn("invalid duration config, using default", "key", key, "value", value, "default", defaultValue.String())
+	return defaultValue
+}
+
+// 逗号分隔的列表配置（去掉空白和空项）
+func (cc *ConfigCache) GetList(key, defaultValue string) []string {
+	var items []string
+	for _, item := range strings.Split(cc.Get(key, defaultValue), ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// JSON 配置解析到 v，配置不存在时解析默认值
+func (cc *ConfigCache) GetJSON(key, defaultValue string, v interface{}) error {
+	return json.Unmarshal([]byte(cc.Get(key, defaultValue)), v)
+}
+
+// 缓存状态
+func (cc *ConfigCache) Stats() (int, time.Time) {
+	cc.mu.RLock()
+	defer cc.mu.RUnlock()
+	return len(cc.values), cc.loadedAt
+}
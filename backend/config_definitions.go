@@ -0,0 +1,402 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// 配置值约束（返回给前端用于生成表单控件）
+type ConfigConstraints struct {
+	Min       *float64              `json:"min,omitempty"`        // 数值下限
+	Max       *float64              `json:"max,omitempty"`        // 数值上限
+	Integer   bool                  `json:"integer,omitempty"`    // 只允许整数
+	MinLength int                   `json:"min_length,omitempty"` // 最小长度（字符数）
+	MaxLength int                   `json:"max_length,omitempty"` // 最大长度（字符数，0表示不限制）
+	Pattern   string                `json:"pattern,omitempty"`    // 正则校验
+	Options   []string              `json:"options,omitempty"`    // 可选值
+	List      *ConfigListConstraint `json:"list,omitempty"`       // 逗号分隔的列表，按列表项校验
+	Schema    json.RawMessage       `json:"schema,omitempty"`     // json 类型配置的 JSON Schema
+}
+
+// 列表配置的约束（如 upload_allowed_types）
+type ConfigListConstraint struct {
+	Options  []string `json:"options,omitempty"`   // 列表项可选值
+	Pattern  string   `json:"pattern,omitempty"`   // 列表项正则校验
+	MinItems int      `json:"min_items,omitempty"` // 最少项数
+	MaxItems int      `json:"max_items,omitempty"` // 最多项数（0表示不限制）
+	Unique   bool     `json:"unique,omitempty"`    // 不允许重复项
+}
+
+// 配置定义：默认值、类型和约束的唯一来源，用于初始化、重置和检查数据库中的配置
+type ConfigDefinition struct {
+	Key         string             `json:"key"`
+	Type        string             `json:"type"` // string, number, boolean, json
+	Category    string             `json:"category"`
+	Default     string             `json:"default"`
+	DisplayName string             `json:"display_name"`
+	Description string             `json:"description"`
+	IsPublic    bool               `json:"is_public"`
+	IsEditable  bool               `json:"is_editable"`
+	Constraints *ConfigConstraints `json:"constraints,omitempty"`
+}
+
+func intRange(min, max float64) *ConfigConstraints {
+	return &ConfigConstraints{Min: &min, Max: &max, Integer: true}
+}
+
+func intAtLeast(min float64) *ConfigConstraints {
+	return &ConfigConstraints{Min: &min, Integer: true}
+}
+
+func oneOf(options ...string) *ConfigConstraints {
+	return &ConfigConstraints{Options: options}
+}
+
+func listOf(list ConfigListConstraint) *ConfigConstraints {
+	return &ConfigConstraints{List: &list}
+}
+
+func jsonSchemaOf(schema string) *ConfigConstraints {
+	return &ConfigConstraints{Schema: json.RawMessage(schema)}
+}
+
+// 配置值格式
+const (
+	configEmailPattern     = `^[^@\s]+@[^@\s]+\.[^@\s]+$`
+	configOptEmailPattern  = `^$|` + configEmailPattern
+	configOptURLPattern    = `^$|^https?://\S+$`
+	configFileExtPattern   = `^[a-z0-9]+$`
+	configHostnamePattern  = `^$|^[A-Za-z0-9.-]+$`
+	configIPOrCIDRPattern  = `^([0-9]{1,3}(\.[0-9]{1,3}){3}(/[0-9]{1,2})?|[0-9A-Fa-f]*:[0-9A-Fa-f:.]*(/[0-9]{1,3})?)$`
+	logRetentionRuleSchema = `{
+  "type": "array",
+  "minItems": 1,
+  "items": {
+    "type": "object",
+    "properties": {
+      "resource": {"type": "string"},
+      "action": {"type": "string"},
+      "days": {"type": "integer"}
+    },
+    "required": ["days"],
+    "additionalProperties": false
+  }
+}`
+	anomalyRuleSchema = `{
+  "type": "array",
+  "items": {
+    "type": "object",
+    "properties": {
+      "name": {"type": "string", "maxLength": 64},
+      "type": {"enum": ["forbidden_burst", "admin_new_ip", "mass_delete", "night_config_change"]},
+      "disabled": {"type": "boolean"},
+      "severity": {"enum": ["low", "medium", "high", "critical"]},
+      "threshold": {"type": "integer", "minimum": 1},
+      "window_minutes": {"type": "integer", "minimum": 1},
+      "lookback_days": {"type": "integer", "minimum": 1},
+      "actions": {"type": "array", "items": {"type": "string"}, "uniqueItems": true},
+      "category": {"type": "string"},
+      "night_start": {"type": "integer", "minimum": 0, "maximum": 23},
+      "night_end": {"type": "integer", "minimum": 0, "maximum": 23}
+    },
+    "required": ["type"],
+    "additionalProperties": false
+  }
+}`
+)
+
+// 系统配置定义
+var configDefinitions = []ConfigDefinition{
+	// 基础配置
+	{Key: "site_name", Type: "string", Category: "basic", Default: "Jing Admin", DisplayName: "网站名称", Description: "系统显示的名称", IsPublic: true, IsEditable: true, Constraints: &ConfigConstraints{MinLength: 1, MaxLength: 100}},
+	{Key: "site_description", Type: "string", Category: "basic", Default: "现代化管理后台系统", DisplayName: "网站描述", Description: "系统描述信息", IsPublic: true, IsEditable: true, Constraints: &ConfigConstraints{MaxLength: 500}},
+	{Key: "site_keywords", Type: "string", Category: "basic", Default: "管理系统,后台,Vue3,Go", DisplayName: "网站关键词", Description: "SEO关键词", IsPublic: true, IsEditable: true},
+	{Key: "site_logo", Type: "string", Category: "basic", Default: "/logo.png", DisplayName: "网站Logo", Description: "网站Logo图片地址", IsPublic: true, IsEditable: true},
+	{Key: "copyright", Type: "string", Category: "basic", Default: "© 2024 Jing Admin. All Rights Reserved.", DisplayName: "版权信息", Description: "网站版权信息", IsPublic: true, IsEditable: true},
+
+	// 邮件配置
+	{Key: "mail_host", Type: "string", Category: "mail", Default: "", DisplayName: "邮件服务器", Description: "SMTP服务器地址", IsPublic: false, IsEditable: true, Constraints: &ConfigConstraints{Pattern: configHostnamePattern}},
+	{Key: "mail_port", Type: "number", Category: "mail", Default: "587", DisplayName: "邮件端口", Description: "SMTP服务器端口", IsPublic: false, IsEditable: true, Constraints: intRange(1, 65535)},
+	{Key: "mail_username", Type: "string", Category: "mail", Default: "", DisplayName: "邮箱用户名", Description: "发送邮件的用户名", IsPublic: false, IsEditable: true},
+	{Key: "mail_password", Type: "string", Category: "mail", Default: "", DisplayName: "邮箱密码", Description: "发送邮件的密码", IsPublic: false, IsEditable: true},
+	{Key: "mail_from", Type: "string", Category: "mail", Default: "", DisplayName: "发件人", Description: "邮件发送者地址", IsPublic: false, IsEditable: true, Constraints: &ConfigConstraints{Pattern: configOptEmailPattern}},
+
+	// 安全配置
+	{Key: "session_timeout", Type: "number", Category: "security", Default: "3600", DisplayName: "会话超时", Description: "用户会话超时时间（秒）", IsPublic: false, IsEditable: true, Constraints: intAtLeast(60)},
+	{Key: "password_min_length", Type: "number", Category: "security", Default: "6", DisplayName: "密码最小长度", Description: "用户密码最小长度要求", IsPublic: true, IsEditable: true, Constraints: intRange(1, 128)},
+	{Key: "enable_captcha", Type: "boolean", Category: "security", Default: "false", DisplayName: "启用验证码", Description: "登录时是否启用验证码", IsPublic: true, IsEditable: true},
+	{Key: "max_login_attempts", Type: "number", Category: "security", Default: "5", DisplayName: "最大登录尝试", Description: "账户锁定前的最大登录尝试次数", IsPublic: false, IsEditable: true, Constraints: intAtLeast(1)},
+	{Key: "login_lock_minutes", Type: "number", Category: "security", Default: "30", DisplayName: "登录锁定时长", Description: "登录失败次数过多时账户锁定的时长（分钟）", IsPublic: false, IsEditable: true, Constraints: intAtLeast(1)},
+	{Key: "register_require_approval", Type: "boolean", Category: "security", Default: "false", DisplayName: "注册需审核", Description: "新注册账户是否需要管理员激活", IsPublic: true, IsEditable: true},
+
+	// 系统配置
+	{Key: "system_version", Type: "string", Category: "system", Default: "1.0.0", DisplayName: "系统版本", Description: "当前系统版本号", IsPublic: true, IsEditable: false},
+	{Key: "upload_max_size", Type: "number", Category: "system", Default: "10485760", DisplayName: "上传文件大小限制", Description: "文件上传大小限制（字节）", IsPublic: false, IsEditable: true, Constraints: intAtLeast(1)},
+	{Key: "upload_allowed_types", Type: "string", Category: "system", Default: "jpg,jpeg,png,gif,pdf,doc,docx,xls,xlsx", DisplayName: "允许上传类型", Description: "允许上传的文件类型", IsPublic: false, IsEditable: true, Constraints: listOf(ConfigListConstraint{Pattern: configFileExtPattern, MinItems: 1, Unique: true})},
+	{Key: "recycle_bin_retention_days", Type: "number", Category: "system", Default: "30", DisplayName: "回收站保留天数", Description: "已删除的用户、角色、权限在回收站中保留的天数，0表示不自动清理", IsPublic: false, IsEditable: true, Constraints: intAtLeast(0)},
+	{Key: "auth_event_retention_days", Type: "number", Category: "security", Default: "90", DisplayName: "认证事件保留天数", Description: "登录、登出、注册事件的保留天数，0表示不自动清理", IsPublic: false, IsEditable: true, Constraints: intAtLeast(0)},
+	{Key: "geo_header_trusted_proxies", Type: "string", Category: "security", Default: "", DisplayName: "地区请求头可信代理", Description: "只信任来自这些代理（逗号分隔的IP或CIDR）的 CF-IPCountry 等国家代码请求头，为空时不使用这些请求头", IsPublic: false, IsEditable: true, Constraints: listOf(ConfigListConstraint{Pattern: configIPOrCIDRPattern, Unique: true})},
+	{Key: "log_queue_size", Type: "number", Category: "system", Default: "1000", DisplayName: "日志队列大小", Description: "操作日志异步写入队列的容量，重启后生效", IsPublic: false, IsEditable: true, Constraints: intAtLeast(1)},
+	{Key: "log_batch_size", Type: "number", Category: "system", Default: "100", DisplayName: "日志批量写入大小", Description: "操作日志每批写入数据库的最大条数，重启后生效", IsPublic: false, IsEditable: true, Constraints: intAtLeast(1)},
+	{Key: "log_flush_interval_ms", Type: "number", Category: "system", Default: "1000", DisplayName: "日志刷新间隔", Description: "操作日志未攒够一批时的最长等待时间（毫秒），重启后生效", IsPublic: false, IsEditable: true, Constraints: intAtLeast(10)},
+	{Key: "log_overflow_policy", Type: "string", Category: "system", Default: "block", DisplayName: "日志队列溢出策略", Description: "队列满时的处理方式：block 阻塞等待，drop_oldest 丢弃最早的日志，spill 写入磁盘稍后补写；重启后生效", IsPublic: false, IsEditable: true, Constraints: oneOf(LogOverflowBlock, LogOverflowDropOldest, LogOverflowSpill)},
+	{Key: "log_retention_rules", Type: "json", Category: "system", Default: defaultLogRetentionRules, DisplayName: "操作日志保留规则", Description: `按顺序匹配的保留规则，resource/action 为空表示任意，days 小于等于0表示永久保留，小于最短保留天数时按最短保留天数计算，例如 [{"resource":"auth","days":180},{"action":"read","days":30},{"days":90}]`, IsPublic: false, IsEditable: true, Constraints: jsonSchemaOf(logRetentionRuleSchema)},
+	{Key: "log_retention_min_days", Type: "number", Category: "system", Default: "30", DisplayName: "操作日志最短保留天数", Description: "保留规则中更短的天数按此计算，防止通过修改保留规则清除近期的操作日志；不允许在线修改", IsPublic: false, IsEditable: false, Constraints: intAtLeast(1)},
+	{Key: "log_archive_dir", Type: "string", Category: "system", Default: "logs/archive", DisplayName: "操作日志归档目录", Description: "过期日志按日期归档为压缩的 JSON Lines 文件后再删除；为防止归档被转移到不受控的位置，不允许在线修改", IsPublic: false, IsEditable: false, Constraints: &ConfigConstraints{MinLength: 1}},
+	{Key: "log_export_max_rows", Type: "number", Category: "system", Default: "100000", DisplayName: "日志导出行数上限", Description: "单次导出操作日志的最大行数", IsPublic: false, IsEditable: true, Constraints: intAtLeast(1)},
+	{Key: "anomaly_rules", Type: "json", Category: "security", Default: defaultAnomalyRules, DisplayName: "异常检测规则", Description: `安全异常检测规则及阈值，type 可选 forbidden_burst（403突增）、admin_new_ip（管理员新IP登录）、mass_delete（批量删除）、night_config_change（夜间修改配置），disabled 为 true 时停用`, IsPublic: false, IsEditable: true, Constraints: jsonSchemaOf(anomalyRuleSchema)},
+	{Key: "alert_notify_min_severity", Type: "string", Category: "security", Default: "high", DisplayName: "告警通知级别", Description: "达到该级别的告警才发送通知：low, medium, high, critical", IsPublic: false, IsEditable: true, Constraints: oneOf(AlertSeverityLow, AlertSeverityMedium, AlertSeverityHigh, AlertSeverityCritical)},
+	{Key: "alert_email_to", Type: "string", Category: "security", Default: "", DisplayName: "告警通知邮箱", Description: "接收安全告警的邮箱，多个用逗号分隔，为空不发送；使用邮件配置中的SMTP服务器", IsPublic: false, IsEditable: true, Constraints: listOf(ConfigListConstraint{Pattern: configEmailPattern, Unique: true})},
+	{Key: "alert_webhook_url", Type: "string", Category: "security", Default: "", DisplayName: "告警Webhook地址", Description: "安全告警以JSON格式POST到该地址，为空不发送", IsPublic: false, IsEditable: true, Constraints: &ConfigConstraints{Pattern: configOptURLPattern}},
+	{Key: "log_append_only", Type: "boolean", Category: "security", Default: "true", DisplayName: "操作日志只追加", Description: "开启后不允许删除单条操作日志，清理旧日志时生成签名检查点；为防止管理员清除自己的操作痕迹，不允许在线修改", IsPublic: false, IsEditable: false},
+	{Key: "app_log_level", Type: "string", Category: "system", Default: "info", DisplayName: "应用日志级别", Description: "应用日志的最低级别：debug, info, warn, error；运行时可通过日志级别接口临时修改", IsPublic: false, IsEditable: true, Constraints: oneOf("debug", "info", "warn", "error")},
+	{Key: "app_log_format", Type: "string", Category: "system", Default: "text", DisplayName: "应用日志格式", Description: "应用日志的输出格式：json 或 text", IsPublic: false, IsEditable: true, Constraints: oneOf("json", "text")},
+	{Key: "app_log_outputs", Type: "string", Category: "system", Default: "stdout", DisplayName: "应用日志输出", Description: "逗号分隔的输出：stdout 标准输出，file 轮转文件，syslog 本地 syslog 套接字", IsPublic: false, IsEditable: true, Constraints: listOf(ConfigListConstraint{Options: []string{"stdout", "file", "syslog"}, MinItems: 1, Unique: true})},
+	{Key: "app_log_file", Type: "string", Category: "system", Default: "logs/app.log", DisplayName: "应用日志文件", Description: "输出到文件时的日志文件路径", IsPublic: false, IsEditable: true, Constraints: &ConfigConstraints{MinLength: 1}},
+	{Key: "app_log_file_max_size_mb", Type: "number", Category: "system", Default: "100", DisplayName: "日志文件大小上限", Description: "单个应用日志文件的大小上限（MB），超过后轮转", IsPublic: false, IsEditable: true, Constraints: intAtLeast(1)},
+	{Key: "app_log_file_max_backups", Type: "number", Category: "system", Default: "5", DisplayName: "日志文件保留数", Description: "轮转后保留的历史日志文件数", IsPublic: false, IsEditable: true, Constraints: intAtLeast(0)},
+	{Key: "app_log_syslog_address", Type: "string", Category: "system", Default: "/dev/log", DisplayName: "syslog 套接字", Description: "输出到 syslog 时使用的本地 Unix 套接字", IsPublic: false, IsEditable: true, Constraints: &ConfigConstraints{MinLength: 1}},
+	{Key: "pagination_size", Type: "number", Category: "system", Default: "20", DisplayName: "分页大小", Description: "默认分页大小", IsPublic: true, IsEditable: true, Constraints: intRange(1, 100)},
+}
+
+// 按配置键索引
+var configDefinitionIndex = func() map[string]ConfigDefinition {
+	index := make(map[string]ConfigDefinition, len(configDefinitions))
+	for _, definition := range configDefinitions {
+		index[definition.Key] = definition
+	}
+	return index
+}()
+
+// 查找配置定义
+func findConfigDefinition(key string) (ConfigDefinition, bool) {
+	definition, ok := configDefinitionIndex[key]
+	return definition, ok
+}
+
+// 检查约束本身是否有效（适用的配置类型、正则表达式、JSON Schema）
+func (cc *ConfigConstraints) validate(configType string) error {
+	if cc == nil {
+		return nil
+	}
+	if (cc.Min != nil || cc.Max != nil || cc.Integer) && configType != "number" {
+		return errors.New("数值约束只适用于 number 类型")
+	}
+	if len(cc.Schema) > 0 && configType != "json" {
+		return errors.New("JSON Schema 只适用于 json 类型")
+	}
+	if cc.Pattern != "" {
+		if _, err := regexp.Compile(cc.Pattern); err != nil {
+			return errors.New("正则表达式格式错误")
+		}
+	}
+	if cc.List != nil && cc.List.Pattern != "" {
+		if _, err := regexp.Compile(cc.List.Pattern); err != nil {
+			return errors.New("列表项正则表达式格式错误")
+		}
+	}
+	if len(cc.Schema) > 0 {
+		if _, err := parseJSONSchema(cc.Schema); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// 检查配置值是否满足约束
+func (cc *ConfigConstraints) check(value string) error {
+	if cc == nil {
+		return nil
+	}
+	if cc.Min != nil || cc.Max != nil || cc.Integer {
+		n, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return errors.New("必须为数字")
+		}
+		if cc.Integer && n != math.Trunc(n) {
+			return errors.New("必须为整数")
+		}
+		if cc.Min != nil && n < *cc.Min {
+			return fmt.Errorf("不能小于 %v", *cc.Min)
+		}
+		if cc.Max != nil && n > *cc.Max {
+			return fmt.Errorf("不能大于 %v", *cc.Max)
+		}
+	}
+
+	length := len([]rune(value))
+	if length < cc.MinLength {
+		if cc.MinLength == 1 {
+			return errors.New("不能为空")
+		}
+		return fmt.Errorf("长度不能小于 %d", cc.MinLength)
+	}
+	if cc.MaxLength > 0 && length > cc.MaxLength {
+		return fmt.Errorf("长度不能超过 %d", cc.MaxLength)
+	}
+	if cc.Pattern != "" {
+		if matched, _ := regexp.MatchString(cc.Pattern, value); !matched {
+			return errors.New("格式不正确")
+		}
+	}
+	if len(cc.Options) > 0 && !containsSlice(cc.Options, value) {
+		return fmt.Errorf("可选值为 %s", strings.Join(cc.Options, ", "))
+	}
+
+	if cc.List != nil {
+		if err := cc.List.check(value); err != nil {
+			return err
+		}
+	}
+	if len(cc.Schema) > 0 {
+		schema, err := parseJSONSchema(cc.Schema)
+		if err != nil {
+			return err
+		}
+		if err := schema.ValidateJSON(value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// 检查逗号分隔的列表（忽略空项，与 ConfigCache.GetList 一致）
+func (lc *ConfigListConstraint) check(value string) error {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	if len(items) < lc.MinItems {
+		return fmt.Errorf("至少需要 %d 项", lc.MinItems)
+	}
+	if lc.MaxItems > 0 && len(items) > lc.MaxItems {
+		return fmt.Errorf("最多 %d 项", lc.MaxItems)
+	}
+	seen := make(map[string]bool, len(items))
+	for _, item := range items {
+		if lc.Unique && seen[item] {
+			return fmt.Errorf("存在重复项 %s", item)
+		}
+		seen[item] = true
+		if lc.Pattern != "" {
+			if matched, _ := regexp.MatchString(lc.Pattern, item); !matched {
+				return fmt.Errorf("%s 格式不正确", item)
+			}
+		}
+		if len(lc.Options) > 0 && !containsSlice(lc.Options, item) {
+			return fmt.Errorf("%s 不在可选值 %s 中", item, strings.Join(lc.Options, ", "))
+		}
+	}
+	return nil
+}
+
+// 配置的约束：有定义的配置使用定义中的约束，自定义配置使用创建时保存的约束
+func configConstraintsOf(config SystemConfig) *ConfigConstraints {
+	if definition, ok := findConfigDefinition(config.Key); ok {
+		return definition.Constraints
+	}
+	return config.Constraints
+}
+
+// 返回给前端的配置附带约束，用于生成表单控件
+func withConfigConstraints(configs []SystemConfig) []SystemConfig {
+	for i := range configs {
+		configs[i].Constraints = configConstraintsOf(configs[i])
+	}
+	return configs
+}
+
+// 校验配置值：先按类型校验，再检查约束
+func validateSystemConfigValue(config SystemConfig, value string) error {
+	if err := validateConfigValue(config.Type, value); err != nil {
+		return err
+	}
+	return configConstraintsOf(config).check(value)
+}
+
+// 配置定义与数据库记录的差异
+type ConfigDrift struct {
+	Key      string `json:"key"`
+	Kind     string `json:"kind"`            // missing 缺失, deleted 已删除, metadata 元数据不一致, invalid 值不满足定义, unknown 没有定义
+	Field    string `json:"field,omitempty"` // 不一致的字段
+	Expected string `json:"expected,omitempty"`
+	Actual   string `json:"actual,omitempty"`
+}
+
+// 比较配置定义和数据库中的配置
+func detectConfigDrift() ([]ConfigDrift, error) {
+	var configs []SystemConfig
+	if err := db.Unscoped().Order("id").Find(&configs).Error; err != nil {
+		return nil, err
+	}
+
+	drifts := []ConfigDrift{}
+	seen := make(map[string]bool, len(configs))
+	for _, config := range configs {
+		seen[config.Key] = true
+		definition, ok := findConfigDefinition(config.Key)
+		if !ok {
+			if !config.DeletedAt.Valid {
+				drifts = append(drifts, ConfigDrift{Key: config.Key, Kind: "unknown"})
+			}
+			continue
+		}
+		if config.DeletedAt.Valid {
+			drifts = append(drifts, ConfigDrift{Key: config.Key, Kind: "deleted"})
+			continue
+		}
+
+		for _, field := range []struct{ name, expected, actual string }{
+			{"type", definition.Type, config.Type},
+			{"category", definition.Category, config.Category},
+			{"display_name", definition.DisplayName, config.DisplayName},
+			{"description", definition.Description, config.Description},
+			{"is_public", strconv.FormatBool(definition.IsPublic), strconv.FormatBool(config.IsPublic)},
+			{"is_editable", strconv.FormatBool(definition.IsEditable), strconv.FormatBool(config.IsEditable)},
+		} {
+			if field.expected != field.actual {
+				drifts = append(drifts, ConfigDrift{Key: config.Key, Kind: "metadata", Field: field.name, Expected: field.expected, Actual: field.actual})
+			}
+		}
+
+		if err := validateSystemConfigValue(SystemConfig{Key: config.Key, Type: definition.Type}, config.Value); err != nil {
+			drifts = append(drifts, ConfigDrift{Key: config.Key, Kind: "invalid", Field: "value", Expected: err.Error(), Actual: maskConfigValue(config.Key, config.Value)})
+		}
+	}
+
+	for _, definition := range configDefinitions {
+		if !seen[definition.Key] {
+			drifts = append(drifts, ConfigDrift{Key: definition.Key, Kind: "missing"})
+		}
+	}
+	return drifts, nil
+}
+
+// 启动时检查配置差异并记录日志
+func checkConfigDrift() {
+	drifts, err := detectConfigDrift()
+	if err != nil {
+		appLogger.Error("failed to check config drift", "error", err)
+		return
+	}
+	for _, drift := range drifts {
+		if drift.Kind == "unknown" {
+			appLogger.Info("system config has no definition", "key", drift.Key)
+			continue
+		}
+		appLogger.Warn("system config drifted from definition",
+			"key", drift.Key,
+			"kind", drift.Kind,
+			"field", drift.Field,
+			"expected", drift.Expected,
+			"actual", drift.Actual,
+		)
+	}
+}
@@ -0,0 +1,361 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// 获取配置修订列表（可按配置键、批次、操作人筛选）
+func getConfigRevisions(c *gin.Context) {
+	listConfigRevisions(c, db.Model(&ConfigRevision{}).Where(configRevisionFilters(c)))
+}
+
+// 获取单个配置的修改历史
+func getConfigHistory(c *gin.Context) {
+	listConfigRevisions(c, db.Model(&ConfigRevision{}).Where("key = ?", c.Param("key")))
+}
+
+func configRevisionFilters(c *gin.Context) map[string]interface{} {
+	filters := map[string]interface{}{}
+	for _, name := range []string{"key", "batch_id", "user_id", "action"} {
+		if value := c.Query(name); value != "" {
+			filters[name] = value
+		}
+	}
+	return filters
+}
+
+func listConfigRevisions(c *gin.Context, query *gorm.DB) {
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	pageSize, _ := strconv.Atoi(c.DefaultQuery("page_size", "20"))
+	if page < 1 {
+		page = 1
+	}
+	if pageSize < 1 || pageSize > 100 {
+		pageSize = 20
+	}
+
+	var total int64
+	query.Count(&total)
+
+	var revisions []ConfigRevision
+	offset := (page - 1) * pageSize
+	if err := query.Order("id DESC").Limit(pageSize).Offset(offset).Find(&revisions).Error; err != nil {
+		errorResponse(c, 500, "获取配置修订失败")
+		return
+	}
+	maskConfigRevisions(revisions)
+
+	successResponse(c, gin.H{
+		"revisions": revisions,
+		"total":     total,
+		"page":      page,
+		"page_size": pageSize,
+	})
+}
+
+// 解析时间点参数，为空时为当前时间
+func parseConfigTimeQuery(c *gin.Context, name string) (time.Time, error) {
+	value := c.Query(name)
+	if value == "" {
+		return time.Now(), nil
+	}
+	t, err := parseStatTime(value, time.Local)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("%s 时间格式错误", name)
+	}
+	return t, nil
+}
+
+// 获取指定时间点的全部配置
+func getConfigSnapshot(c *gin.Context) {
+	at, err := parseConfigTimeQuery(c, "at")
+	if err != nil {
+		errorResponse(c, 400, err.Error())
+		return
+	}
+
+	values, err := configSnapshotAt(at)
+	if err != nil {
+		errorResponse(c, 500, "获取配置快照失败")
+		return
+	}
+	for key, value := range values {
+		values[key] = maskConfigValue(key, value)
+	}
+
+	successResponse(c, gin.H{
+		"at":      at,
+		"configs": values,
+		"total":   len(values),
+	})
+}
+
+// 比较两个时间点的配置（to 默认为当前时间）
+func getConfigDiff(c *gin.Context) {
+	if c.Query("from") == "" {
+		errorResponse(c, 400, "请指定 from 时间")
+		return
+	}
+	from, err := parseConfigTimeQuery(c, "from")
+	if err != nil {
+		errorResponse(c, 400, err.Error())
+		return
+	}
+	to, err := parseConfigTimeQuery(c, "to")
+	if err != nil {
+		errorResponse(c, 400, err.Error())
+		return
+	}
+
+	fromValues, err := configSnapshotAt(from)
+	if err != nil {
+		errorResponse(c, 500, "获取配置快照失败")
+		return
+	}
+	toValues, err := configSnapshotAt(to)
+	if err != nil {
+		errorResponse(c, 500, "获取配置快照失败")
+		return
+	}
+
+	diffs := diffConfigSnapshots(fromValues, toValues)
+	for i := range diffs {
+		diffs[i].From = maskConfigValue(diffs[i].Key, diffs[i].From)
+		diffs[i].To = maskConfigValue(diffs[i].Key, diffs[i].To)
+	}
+
+	successResponse(c, gin.H{
+		"from":  from,
+		"to":    to,
+		"diffs": diffs,
+		"total": len(diffs),
+	})
+}
+
+// 配置目标状态（回滚、重置为默认值时使用）
+type configTarget struct {
+	Key     string
+	Value   string
+	Deleted bool // 恢复为已删除
+}
+
+// 达到目标状态需要的单项修改
+type configTargetChange struct {
+	config  SystemConfig
+	target  configTarget
+	action  string
+	current string
+}
+
+// 检查目标状态，返回需要修改的配置；校验失败时返回面向用户的错误
+func planConfigTargets(targets []configTarget) ([]configTargetChange, error) {
+	var changes []configTargetChange
+	for _, target := range targets {
+		var config SystemConfig
+		if err := db.Unscoped().Where("key = ?", target.Key).First(&config).Error; err != nil {
+			if target.Deleted {
+				continue
+			}
+			return nil, fmt.Errorf("配置 %s 不存在", target.Key)
+		}
+		if !config.IsEditable {
+			return nil, fmt.Errorf("配置 %s 不允许编辑", target.Key)
+		}
+
+		deleted := config.DeletedAt.Valid
+		var action string
+		switch {
+		case target.Deleted && deleted, !target.Deleted && !deleted && config.Value == target.Value:
+			continue
+		case target.Deleted:
+			action = ConfigRevisionDelete
+		case deleted:
+			action = ConfigRevisionCreate
+		default:
+			action = ConfigRevisionUpdate
+		}
+		if !target.Deleted {
+			if err := validateSystemConfigValue(config, target.Value); err != nil {
+				return nil, fmt.Errorf("配置 %s 值格式错误: %s", target.Key, err.Error())
+			}
+		}
+
+		current := config.Value
+		if deleted {
+			current = ""
+		}
+		changes = append(changes, configTargetChange{config: config, target: target, action: action, current: current})
+	}
+	return changes, nil
+}
+
+// 在一个事务中应用修改，并记录为新的修订批次（rollbackOf 为回滚目标，重置时为空）
+func applyConfigTargets(c *gin.Context, changes []configTargetChange, comment, rollbackOf string) (*configRevisionBatch, error) {
+	batch := newConfigRevisionBatch(c, comment)
+	err := auditDB(c).Transaction(func(tx *gorm.DB) error {
+		for _, change := range changes {
+			config := change.config
+			switch change.action {
+			case ConfigRevisionDelete:
+				if err := tx.Delete(&config).Error; err != nil {
+					return err
+				}
+			default:
+				config.Value = change.target.Value
+				config.DeletedAt = gorm.DeletedAt{}
+				if err := tx.Unscoped().Save(&config).Error; err != nil {
+					return err
+				}
+			}
+			if err := batch.record(tx, change.action, config.Key, change.current, change.target.Value, rollbackOf); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	reloadConfigCache()
+	return batch, nil
+}
+
+// 将配置回滚到指定修订后的值（before 为 true 时恢复为该修订之前的值）
+func rollbackConfigKey(c *gin.Context) {
+	key := c.Param("key")
+
+	var req struct {
+		RevisionID uint   `json:"revision_id" binding:"required"`
+		Before     bool   `json:"before"`
+		Comment    string `json:"comment"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		errorResponse(c, 400, "请求参数错误")
+		return
+	}
+
+	var revision ConfigRevision
+	if err := db.Where("id = ? AND key = ?", req.RevisionID, key).First(&revision).Error; err != nil {
+		errorResponse(c, 404, "修订记录不存在")
+		return
+	}
+
+	target := configTarget{Key: key, Value: revision.NewValue, Deleted: revision.Action == ConfigRevisionDelete}
+	if req.Before {
+		target = configTarget{Key: key, Value: revision.OldValue, Deleted: revision.Action == ConfigRevisionCreate}
+	}
+	changes, err := planConfigTargets([]configTarget{target})
+	if err != nil {
+		errorResponse(c, 400, err.Error())
+		return
+	}
+	if len(changes) == 0 {
+		errorResponse(c, 400, "配置已是该修订的值")
+		return
+	}
+
+	batch, err := applyConfigTargets(c, changes, req.Comment, strconv.FormatUint(uint64(revision.ID), 10))
+	if err != nil {
+		errorResponse(c, 500, "回滚配置失败")
+		return
+	}
+
+	var config SystemConfig
+	db.Unscoped().Where("key = ?", key).First(&config)
+	config.Constraints = configConstraintsOf(config)
+	successResponse(c, gin.H{
+		"config":   config,
+		"deleted":  target.Deleted,
+		"batch_id": batch.id,
+	})
+}
+
+// 撤销一个修订批次：批次中的配置恢复为修改前的值
+// 批次之后配置又被修改过时视为冲突，需要 force 才会覆盖
+func rollbackConfigBatch(c *gin.Context) {
+	batchID := c.Param("batch_id")
+
+	var req struct {
+		Comment string `json:"comment"`
+		Force   bool   `json:"force"`
+	}
+	c.ShouldBindJSON(&req)
+
+	var revisions []ConfigRevision
+	if err := db.Where("batch_id = ?", batchID).Order("id").Find(&revisions).Error; err != nil {
+		errorResponse(c, 500, "获取修订批次失败")
+		return
+	}
+	if len(revisions) == 0 {
+		errorResponse(c, 404, "修订批次不存在")
+		return
+	}
+
+	// 每个配置恢复为批次中第一次修改前的值，并以最后一次修改后的值检查冲突
+	var targets []configTarget
+	first := make(map[string]ConfigRevision)
+	last := make(map[string]ConfigRevision)
+	for _, revision := range revisions {
+		if _, ok := first[revision.Key]; !ok {
+			first[revision.Key] = revision
+			targets = append(targets, configTarget{
+				Key:     revision.Key,
+				Value:   revision.OldValue,
+				Deleted: revision.Action == ConfigRevisionCreate,
+			})
+		}
+		last[revision.Key] = revision
+	}
+
+	if !req.Force {
+		var conflicts []string
+		for key, revision := range last {
+			var config SystemConfig
+			exists := db.Where("key = ?", key).First(&config).Error == nil
+			if revision.Action == ConfigRevisionDelete {
+				if exists {
+					conflicts = append(conflicts, key)
+				}
+			} else if !exists || config.Value != revision.NewValue {
+				conflicts = append(conflicts, key)
+			}
+		}
+		if len(conflicts) > 0 {
+			sort.Strings(conflicts)
+			errorResponse(c, 409, "以下配置在该批次之后已被修改："+strings.Join(conflicts, ", ")+"，确认覆盖请设置 force")
+			return
+		}
+	}
+
+	changes, err := planConfigTargets(targets)
+	if err != nil {
+		errorResponse(c, 400, err.Error())
+		return
+	}
+	if len(changes) == 0 {
+		errorResponse(c, 400, "配置已是该批次修改前的值")
+		return
+	}
+
+	batch, err := applyConfigTargets(c, changes, req.Comment, batchID)
+	if err != nil {
+		errorResponse(c, 500, "回滚配置失败")
+		return
+	}
+
+	keys := make([]string, 0, len(changes))
+	for _, change := range changes {
+		keys = append(keys, change.config.Key)
+	}
+	successResponse(c, gin.H{
+		"rolled_back": keys,
+		"batch_id":    batch.id,
+	})
+}
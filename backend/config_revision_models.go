@@ -0,0 +1,148 @@
+package main
+
+import (
+	"sort"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// 配置修改类型
+const (
+	ConfigRevisionCreate = "create"
+	ConfigRevisionUpdate = "update"
+	ConfigRevisionDelete = "delete"
+)
+
+// 配置修订记录：每次修改配置值保存一条，同一次请求中的修改属于同一批次
+// 回滚同样按实际效果记录为 create/update/delete，并在 RollbackOf 中记录回滚目标
+type ConfigRevision struct {
+	ID         uint      `json:"id" gorm:"primaryKey"`
+	Key        string    `json:"key" gorm:"not null;index"`
+	Action     string    `json:"action" gorm:"not null"` // create, update, delete
+	OldValue   string    `json:"old_value" gorm:"type:text"`
+	NewValue   string    `json:"new_value" gorm:"type:text"`
+	BatchID    string    `json:"batch_id" gorm:"not null;index"`
+	RollbackOf string    `json:"rollback_of,omitempty"` // 回滚的目标（修订ID或批次ID）
+	Comment    string    `json:"comment"`
+	UserID     uint      `json:"user_id" gorm:"index"`
+	Username   string    `json:"username"`
+	RequestID  string    `json:"request_id"`
+	CreatedAt  time.Time `json:"created_at" gorm:"index"`
+}
+
+// 初始化配置修订
+func initConfigRevisionSystem() error {
+	// 自动迁移数据库
+	return db.AutoMigrate(&ConfigRevision{})
+}
+
+// 一次请求内的配置修订（批次ID、操作人、备注相同）
+type configRevisionBatch struct {
+	id        string
+	comment   string
+	userID    uint
+	username  string
+	requestID string
+}
+
+// 按当前请求创建修订批次
+func newConfigRevisionBatch(c *gin.Context, comment string) *configRevisionBatch {
+	return &configRevisionBatch{
+		id:        newRequestID(),
+		comment:   comment,
+		userID:    c.GetUint("user_id"),
+		username:  c.GetString("username"),
+		requestID: requestIDFromContext(c),
+	}
+}
+
+// 在配置修改所在的事务中记录修订
+func (b *configRevisionBatch) record(tx *gorm.DB, action, key, oldValue, newValue, rollbackOf string) error {
+	return tx.Create(&ConfigRevision{
+		Key:        key,
+		Action:     action,
+		OldValue:   oldValue,
+		NewValue:   newValue,
+		BatchID:    b.id,
+		RollbackOf: rollbackOf,
+		Comment:    b.comment,
+		UserID:     b.userID,
+		Username:   b.username,
+		RequestID:  b.requestID,
+	}).Error
+}
+
+// 指定时间点的全部配置值：从当前值开始，按时间倒序撤销之后的修订
+func configSnapshotAt(at time.Time) (map[string]string, error) {
+	var configs []SystemConfig
+	if err := db.Select("key", "value").Find(&configs).Error; err != nil {
+		return nil, err
+	}
+	values := make(map[string]string, len(configs))
+	for _, config := range configs {
+		values[config.Key] = config.Value
+	}
+
+	var revisions []ConfigRevision
+	err := db.Select("key", "action", "old_value").
+		Where("created_at > ?", at).
+		Order("id DESC").
+		Find(&revisions).Error
+	if err != nil {
+		return nil, err
+	}
+	for _, revision := range revisions {
+		if revision.Action == ConfigRevisionCreate {
+			delete(values, revision.Key)
+		} else {
+			values[revision.Key] = revision.OldValue
+		}
+	}
+	return values, nil
+}
+
+// 配置差异
+type ConfigDiff struct {
+	Key    string `json:"key"`
+	Status string `json:"status"` // added, removed, changed
+	From   string `json:"from"`
+	To     string `json:"to"`
+}
+
+// 比较两个配置快照
+func diffConfigSnapshots(from, to map[string]string) []ConfigDiff {
+	diffs := []ConfigDiff{}
+	for key, value := range to {
+		old, ok := from[key]
+		switch {
+		case !ok:
+			diffs = append(diffs, ConfigDiff{Key: key, Status: "added", To: value})
+		case old != value:
+			diffs = append(diffs, ConfigDiff{Key: key, Status: "changed", From: old, To: value})
+		}
+	}
+	for key, value := range from {
+		if _, ok := to[key]; !ok {
+			diffs = append(diffs, ConfigDiff{Key: key, Status: "removed", From: value})
+		}
+	}
+	sort.Slice(diffs, func(i, j int) bool { return diffs[i].Key < diffs[j].Key })
+	return diffs
+}
+
+// 敏感配置在修订、快照和差异中脱敏显示
+func maskConfigValue(key, value string) string {
+	if auditSecretConfigKeys[key] && value != "" {
+		return auditMask
+	}
+	return value
+}
+
+func maskConfigRevisions(revisions []ConfigRevision) {
+	for i := range revisions {
+		revisions[i].OldValue = maskConfigValue(revisions[i].Key, revisions[i].OldValue)
+		revisions[i].NewValue = maskConfigValue(revisions[i].Key, revisions[i].NewValue)
+	}
+}
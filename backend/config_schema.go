@@ -0,0 +1,273 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// JSON Schema（支持常用子集）：type、enum、properties、required、additionalProperties、
+// items、minItems、maxItems、uniqueItems、minimum、maximum、minLength、maxLength、pattern
+type jsonSchema struct {
+	Type                 jsonSchemaTypes        `json:"type,omitempty"`
+	Enum                 []interface{}          `json:"enum,omitempty"`
+	Properties           map[string]*jsonSchema `json:"properties,omitempty"`
+	Required             []string               `json:"required,omitempty"`
+	AdditionalProperties *bool                  `json:"additionalProperties,omitempty"`
+	Items                *jsonSchema            `json:"items,omitempty"`
+	MinItems             *int                   `json:"minItems,omitempty"`
+	MaxItems             *int                   `json:"maxItems,omitempty"`
+	UniqueItems          bool                   `json:"uniqueItems,omitempty"`
+	Minimum              *float64               `json:"minimum,omitempty"`
+	Maximum              *float64               `json:"maximum,omitempty"`
+	MinLength            *int                   `json:"minLength,omitempty"`
+	MaxLength            *int                   `json:"maxLength,omitempty"`
+	Pattern              string                 `json:"pattern,omitempty"`
+
+	pattern *regexp.Regexp
+}
+
+// type 可以是单个类型或类型数组
+type jsonSchemaTypes []string
+
+func (t *jsonSchemaTypes) UnmarshalJSON(data []byte) error {
+	var single string
+	if err := json.Unmarshal(data, &single); err == nil {
+		*t = jsonSchemaTypes{single}
+		return nil
+	}
+	var multiple []string
+	if err := json.Unmarshal(data, &multiple); err != nil {
+		return errors.New("type 必须为字符串或字符串数组")
+	}
+	*t = multiple
+	return nil
+}
+
+var jsonSchemaTypeNames = map[string]bool{
+	"object": true, "array": true, "string": true, "number": true, "integer": true, "boolean": true, "null": true,
+}
+
+// 支持的关键字；title、description、default、$schema 只作说明，不参与校验
+// 其他关键字（oneOf、$ref、format 等）会被拒绝，避免误以为已生效
+var jsonSchemaKeywords = map[string]bool{
+	"type": true, "enum": true, "properties": true, "required": true, "additionalProperties": true,
+	"items": true, "minItems": true, "maxItems": true, "uniqueItems": true, "minimum": true, "maximum": true,
+	"minLength": true, "maxLength": true, "pattern": true,
+	"title": true, "description": true, "default": true, "$schema": true,
+}
+
+// 解析 JSON Schema，并检查关键字、类型名和正则表达式
+func parseJSONSchema(data []byte) (*jsonSchema, error) {
+	var raw interface{}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, fmt.Errorf("JSON Schema 格式错误: %w", err)
+	}
+	if err := checkJSONSchemaKeywords("$", raw); err != nil {
+		return nil, err
+	}
+
+	var schema jsonSchema
+	if err := json.Unmarshal(data, &schema); err != nil {
+		return nil, fmt.Errorf("JSON Schema 格式错误: %w", err)
+	}
+	if err := schema.compile("$"); err != nil {
+		return nil, err
+	}
+	return &schema, nil
+}
+
+// 检查 Schema（及 properties、items 中的子 Schema）都是对象且只使用支持的关键字
+func checkJSONSchemaKeywords(path string, raw interface{}) error {
+	schema, ok := raw.(map[string]interface{})
+	if !ok {
+		return fmt.Errorf("JSON Schema %s: 必须为对象", path)
+	}
+	keywords := make([]string, 0, len(schema))
+	for keyword := range schema {
+		keywords = append(keywords, keyword)
+	}
+	sort.Strings(keywords)
+	for _, keyword := range keywords {
+		if !jsonSchemaKeywords[keyword] {
+			return fmt.Errorf("JSON Schema %s: 不支持的关键字 %s", path, keyword)
+		}
+	}
+
+	if properties, ok := schema["properties"]; ok {
+		properties, ok := properties.(map[string]interface{})
+		if !ok {
+			return fmt.Errorf("JSON Schema %s: properties 必须为对象", path)
+		}
+		for name, property := range properties {
+			if err := checkJSONSchemaKeywords(path+"."+name, property); err != nil {
+				return err
+			}
+		}
+	}
+	if items, ok := schema["items"]; ok {
+		return checkJSONSchemaKeywords(path+"[]", items)
+	}
+	return nil
+}
+
+func (s *jsonSchema) compile(path string) error {
+	for _, name := range s.Type {
+		if !jsonSchemaTypeNames[name] {
+			return fmt.Errorf("JSON Schema %s: 不支持的类型 %q", path, name)
+		}
+	}
+	if s.Pattern != "" {
+		pattern, err := regexp.Compile(s.Pattern)
+		if err != nil {
+			return fmt.Errorf("JSON Schema %s: 正则表达式格式错误", path)
+		}
+		s.pattern = pattern
+	}
+	for name, property := range s.Properties {
+		if property == nil {
+			return fmt.Errorf("JSON Schema %s.%s: 必须为对象", path, name)
+		}
+		if err := property.compile(path + "." + name); err != nil {
+			return err
+		}
+	}
+	if s.Items != nil {
+		return s.Items.compile(path + "[]")
+	}
+	return nil
+}
+
+// 按 Schema 校验 JSON 文本
+func (s *jsonSchema) ValidateJSON(value string) error {
+	var data interface{}
+	if err := json.Unmarshal([]byte(value), &data); err != nil {
+		return errors.New("JSON格式错误")
+	}
+	return s.validate("$", data)
+}
+
+// JSON 值的类型名
+func jsonTypeOf(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case float64:
+		if v == math.Trunc(v) {
+			return "integer"
+		}
+		return "number"
+	case string:
+		return "string"
+	case []interface{}:
+		return "array"
+	case map[string]interface{}:
+		return "object"
+	}
+	return "unknown"
+}
+
+func (s *jsonSchema) validate(path string, value interface{}) error {
+	if len(s.Type) > 0 {
+		actual := jsonTypeOf(value)
+		matched := false
+		for _, expected := range s.Type {
+			if expected == actual || (expected == "number" && actual == "integer") {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return fmt.Errorf("%s 应为 %s 类型", path, strings.Join(s.Type, " 或 "))
+		}
+	}
+
+	if len(s.Enum) > 0 {
+		matched := false
+		for _, option := range s.Enum {
+			if reflect.DeepEqual(option, value) {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			options, _ := json.Marshal(s.Enum)
+			return fmt.Errorf("%s 可选值为 %s", path, options)
+		}
+	}
+
+	switch v := value.(type) {
+	case float64:
+		if s.Minimum != nil && v < *s.Minimum {
+			return fmt.Errorf("%s 不能小于 %v", path, *s.Minimum)
+		}
+		if s.Maximum != nil && v > *s.Maximum {
+			return fmt.Errorf("%s 不能大于 %v", path, *s.Maximum)
+		}
+	case string:
+		length := len([]rune(v))
+		if s.MinLength != nil && length < *s.MinLength {
+			return fmt.Errorf("%s 长度不能小于 %d", path, *s.MinLength)
+		}
+		if s.MaxLength != nil && length > *s.MaxLength {
+			return fmt.Errorf("%s 长度不能超过 %d", path, *s.MaxLength)
+		}
+		if s.pattern != nil && !s.pattern.MatchString(v) {
+			return fmt.Errorf("%s 格式不正确", path)
+		}
+	case []interface{}:
+		if s.MinItems != nil && len(v) < *s.MinItems {
+			return fmt.Errorf("%s 至少需要 %d 项", path, *s.MinItems)
+		}
+		if s.MaxItems != nil && len(v) > *s.MaxItems {
+			return fmt.Errorf("%s 最多 %d 项", path, *s.MaxItems)
+		}
+		if s.UniqueItems {
+			for i := range v {
+				for j := 0; j < i; j++ {
+					if reflect.DeepEqual(v[i], v[j]) {
+						return fmt.Errorf("%s 存在重复项", path)
+					}
+				}
+			}
+		}
+		if s.Items != nil {
+			for i, item := range v {
+				if err := s.Items.validate(fmt.Sprintf("%s[%d]", path, i), item); err != nil {
+					return err
+				}
+			}
+		}
+	case map[string]interface{}:
+		for _, name := range s.Required {
+			if _, ok := v[name]; !ok {
+				return fmt.Errorf("%s 缺少字段 %s", path, name)
+			}
+		}
+		names := make([]string, 0, len(v))
+		for name := range v {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			property, ok := s.Properties[name]
+			if !ok {
+				if s.AdditionalProperties != nil && !*s.AdditionalProperties {
+					return fmt.Errorf("%s 不允许字段 %s", path, name)
+				}
+				continue
+			}
+			if err := property.validate(path+"."+name, v[name]); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
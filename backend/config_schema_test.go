@@ -0,0 +1,63 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseJSONSchemaRejectsInvalidSchemas(t *testing.T) {
+	tests := []struct {
+		name   string
+		schema string
+		want   string
+	}{
+		{"null schema", `null`, "$: 必须为对象"},
+		{"null property", `{"type":"object","properties":{"days":null}}`, "$.days: 必须为对象"},
+		{"null items", `{"type":"array","items":null}`, "$[]: 必须为对象"},
+		{"non-object properties", `{"properties":[]}`, "properties 必须为对象"},
+		{"unsupported keyword", `{"type":"object","oneOf":[]}`, "不支持的关键字 oneOf"},
+		{"unsupported nested keyword", `{"type":"array","items":{"type":"object","properties":{"url":{"type":"string","format":"uri"}}}}`, "$[].url: 不支持的关键字 format"},
+		{"unsupported type", `{"type":"date"}`, `不支持的类型 "date"`},
+		{"invalid pattern", `{"type":"string","pattern":"("}`, "正则表达式格式错误"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := parseJSONSchema([]byte(tt.schema))
+			if err == nil {
+				t.Fatalf("expected error for %s", tt.schema)
+			}
+			if !strings.Contains(err.Error(), tt.want) {
+				t.Fatalf("error = %q, want it to contain %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseJSONSchemaAcceptsSupportedKeywords(t *testing.T) {
+	schema, err := parseJSONSchema([]byte(`{
+		"$schema": "http://json-schema.org/draft-07/schema#",
+		"title": "rules",
+		"type": "array",
+		"items": {
+			"type": "object",
+			"required": ["days"],
+			"additionalProperties": false,
+			"properties": {
+				"resource": {"type": ["string", "null"], "pattern": "^[a-z_]*$"},
+				"days": {"type": "integer", "minimum": 0}
+			}
+		}
+	}`))
+	if err != nil {
+		t.Fatalf("parse schema: %v", err)
+	}
+	if err := schema.ValidateJSON(`[{"resource":"auth","days":180},{"resource":null,"days":30}]`); err != nil {
+		t.Fatalf("valid value rejected: %v", err)
+	}
+	if err := schema.ValidateJSON(`[{"resource":"auth"}]`); err == nil {
+		t.Fatal("missing required property accepted")
+	}
+	if err := schema.ValidateJSON(`[{"days":1,"extra":true}]`); err == nil {
+		t.Fatal("additional property accepted")
+	}
+}
@@ -0,0 +1,143 @@
+package main
+
+import (
+	"github.com/gin-gonic/gin"
+)
+
+// 获取自定义字段定义列表（管理员）
+func getCustomFieldList(c *gin.Context) {
+	fields := getCustomFields()
+	successResponse(c, gin.H{
+		"fields": fields,
+		"total":  len(fields),
+	})
+}
+
+// 获取当前用户可见的自定义字段定义
+func getProfileCustomFields(c *gin.Context) {
+	fields := getCustomFields(FieldVisibilitySelfEditable, FieldVisibilityAdminOnly)
+	successResponse(c, gin.H{
+		"fields": fields,
+		"total":  len(fields),
+	})
+}
+
+// 创建自定义字段
+func createCustomField(c *gin.Context) {
+	var field CustomField
+	if err := c.ShouldBindJSON(&field); err != nil {
+		errorResponse(c, 400, "请求参数错误")
+		return
+	}
+	if field.Type == "" {
+		field.Type = "string"
+	}
+	if field.Visibility == "" {
+		field.Visibility = FieldVisibilitySelfEditable
+	}
+	if field.Label == "" {
+		errorResponse(c, 400, "字段显示名称不能为空")
+		return
+	}
+	if err := validateCustomFieldDefinition(&field); err != nil {
+		errorResponse(c, 400, err.Error())
+		return
+	}
+
+	// 检查键名是否存在
+	var existing CustomField
+	if err := db.Where("key = ?", field.Key).First(&existing).Error; err == nil {
+		errorResponse(c, 400, "字段键名已存在")
+		return
+	}
+
+	if err := auditDB(c).Create(&field).Error; err != nil {
+		errorResponse(c, 500, "创建自定义字段失败")
+		return
+	}
+
+	successResponse(c, field)
+}
+
+// 更新自定义字段（键名不可修改）
+func updateCustomField(c *gin.Context) {
+	id := c.Param("id")
+	var field CustomField
+	if err := db.First(&field, id).Error; err != nil {
+		errorResponse(c, 404, "自定义字段不存在")
+		return
+	}
+
+	var req struct {
+		Label      *string `json:"label" binding:"omitempty,min=1,max=50"`
+		Type       *string `json:"type"`
+		Options    *string `json:"options"`
+		Pattern    *string `json:"pattern"`
+		MaxLength  *int    `json:"max_length"`
+		Required   *bool   `json:"required"`
+		Visibility *string `json:"visibility"`
+		SortOrder  *int    `json:"sort_order"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		errorResponse(c, 400, validationErrorMessage(err))
+		return
+	}
+
+	if req.Label != nil {
+		field.Label = *req.Label
+	}
+	if req.Type != nil {
+		field.Type = *req.Type
+	}
+	if req.Options != nil {
+		field.Options = *req.Options
+	}
+	if req.Pattern != nil {
+		field.Pattern = *req.Pattern
+	}
+	if req.MaxLength != nil {
+		field.MaxLength = *req.MaxLength
+	}
+	if req.Required != nil {
+		field.Required = *req.Required
+	}
+	if req.Visibility != nil {
+		field.Visibility = *req.Visibility
+	}
+	if req.SortOrder != nil {
+		field.SortOrder = *req.SortOrder
+	}
+
+	if err := validateCustomFieldDefinition(&field); err != nil {
+		errorResponse(c, 400, err.Error())
+		return
+	}
+
+	if err := auditDB(c).Save(&field).Error; err != nil {
+		errorResponse(c, 500, "更新自定义字段失败")
+		return
+	}
+
+	successResponse(c, field)
+}
+
+// 删除自定义字段（同时删除所有用户的字段值）
+func deleteCustomField(c *gin.Context) {
+	id := c.Param("id")
+	var field CustomField
+	if err := db.First(&field, id).Error; err != nil {
+		errorResponse(c, 404, "自定义字段不存在")
+		return
+	}
+
+	if err := auditDB(c).Where("field_id = ?", field.ID).Delete(&UserCustomFieldValue{}).Error; err != nil {
+		errorResponse(c, 500, "删除自定义字段失败")
+		return
+	}
+	if err := auditDB(c).Delete(&field).Error; err != nil {
+		errorResponse(c, 500, "删除自定义字段失败")
+		return
+	}
+
+	successResponse(c, gin.H{"message": "自定义字段删除成功"})
+}
@@ -0,0 +1,244 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// 自定义字段可见性
+const (
+	FieldVisibilitySelfEditable = "self_editable" // 用户本人可查看和编辑
+	FieldVisibilityAdminOnly    = "admin_only"    // 用户本人只读，仅管理员可编辑
+	FieldVisibilityHidden       = "hidden"        // 仅管理员可查看和编辑
+)
+
+// 用户自定义资料字段定义（由管理员维护）
+type CustomField struct {
+	ID         uint   `json:"id" gorm:"primaryKey"`
+	Key        string `json:"key" gorm:"not null;uniqueIndex:idx_custom_fields_key_active,where:deleted_at IS NULL"` // 字段键名，如 employee_no
+	Label      string `json:"label" gorm:"not null"`                                                                 // 显示名称，同时作为CSV列名
+	Type       string `json:"type" gorm:"not null;default:string"`                                                   // 字段类型：string, number, date, boolean, select
+	Options    string `json:"options"`                                                                               // select 类型的可选项，逗号分隔
+	Pattern    string `json:"pattern"`                                                                               // 正则校验（string 类型）
+	MaxLength  int    `json:"max_length"`                                                                            // 最大长度（0表示不限制）
+	Required   bool   `json:"required" gorm:"default:false"`                                                         // 是否必填
+	Visibility string `json:"visibility" gorm:"not null;default:self_editable"`                                      // 可见性：self_editable, admin_only, hidden
+	SortOrder  int    `json:"sort_order" gorm:"default:0"`                                                           // 排序
+	gorm.Model
+}
+
+// 用户自定义字段值
+type UserCustomFieldValue struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	UserID    uint      `json:"user_id" gorm:"not null;uniqueIndex:idx_user_custom_field"`
+	FieldID   uint      `json:"field_id" gorm:"not null;uniqueIndex:idx_user_custom_field"`
+	Value     string    `json:"value" gorm:"type:text"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+var customFieldKeyPattern = regexp.MustCompile(`^[a-z][a-z0-9_]{0,49}$`)
+
+// 初始化自定义字段系统
+func initCustomFieldSystem() error {
+	// 自动迁移数据库
+	return db.AutoMigrate(&CustomField{}, &UserCustomFieldValue{})
+}
+
+// 校验字段定义
+func validateCustomFieldDefinition(field *CustomField) error {
+	if !customFieldKeyPattern.MatchString(field.Key) {
+		return errors.New("字段键名只能包含小写字母、数字和下划线，且以字母开头")
+	}
+	switch field.Type {
+	case "string", "number", "date", "boolean":
+	case "select":
+		if len(splitCustomFieldOptions(field.Options)) == 0 {
+			return errors.New("select 类型必须提供可选项")
+		}
+	default:
+		return fmt.Errorf("不支持的字段类型: %s", field.Type)
+	}
+	switch field.Visibility {
+	case FieldVisibilitySelfEditable, FieldVisibilityAdminOnly, FieldVisibilityHidden:
+	default:
+		return fmt.Errorf("不支持的可见性: %s", field.Visibility)
+	}
+	if field.Pattern != "" {
+		if _, err := regexp.Compile(field.Pattern); err != nil {
+			return errors.New("正则表达式格式错误")
+		}
+	}
+	return nil
+}
+
+// 校验字段值
+func validateCustomFieldValue(field CustomField, value string) error {
+	if value == "" {
+		if field.Required {
+			return fmt.Errorf("%s 为必填项", field.Label)
+		}
+		return nil
+	}
+	if field.MaxLength > 0 && len([]rune(value)) > field.MaxLength {
+		return fmt.Errorf("%s 长度不能超过 %d", field.Label, field.MaxLength)
+	}
+
+	switch field.Type {
+	case "number":
+		if _, err := strconv.ParseFloat(value, 64); err != nil {
+			return fmt.Errorf("%s 必须为数字", field.Label)
+		}
+	case "date":
+		if _, err := time.Parse("2006-01-02", value); err != nil {
+			return fmt.Errorf("%s 必须为日期（YYYY-MM-DD）", field.Label)
+		}
+	case "boolean":
+		if value != "true" && value != "false" {
+			return fmt.Errorf("%s 必须为 true 或 false", field.Label)
+		}
+	case "select":
+		if !containsSlice(splitCustomFieldOptions(field.Options), value) {
+			return fmt.Errorf("%s 的值不在可选项中", field.Label)
+		}
+	}
+
+	if field.Pattern != "" {
+		if matched, _ := regexp.MatchString(field.Pattern, value); !matched {
+			return fmt.Errorf("%s 格式不正确", field.Label)
+		}
+	}
+	return nil
+}
+
+// 拆分可选项
+func splitCustomFieldOptions(options string) []string {
+	items := []string{}
+	for _, item := range strings.Split(options, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// 获取字段定义（按排序），visibilities 为空时返回全部
+func getCustomFields(visibilities ...string) []CustomField {
+	var fields []CustomField
+	query := db.Order("sort_order, id")
+	if len(visibilities) > 0 {
+		query = query.Where("visibility IN ?", visibilities)
+	}
+	query.Find(&fields)
+	return fields
+}
+
+// 批量加载用户的自定义字段值，返回 用户ID -> 字段键名 -> 值
+func loadUserCustomFields(userIDs []uint, fields []CustomField) map[uint]map[string]string {
+	result := make(map[uint]map[string]string)
+	if len(userIDs) == 0 || len(fields) == 0 {
+		return result
+	}
+
+	fieldKeys := make(map[uint]string)
+	fieldIDs := make([]uint, 0, len(fields))
+	for _, field := range fields {
+		fieldKeys[field.ID] = field.Key
+		fieldIDs = append(fieldIDs, field.ID)
+	}
+
+	var values []UserCustomFieldValue
+	db.Where("user_id IN ? AND field_id IN ?", userIDs, fieldIDs).Find(&values)
+	for _, v := range values {
+		if result[v.UserID] == nil {
+			result[v.UserID] = make(map[string]string)
+		}
+		result[v.UserID][fieldKeys[v.FieldID]] = v.Value
+	}
+	return result
+}
+
+// 填充用户的自定义字段（只包含指定可见性的字段）
+func attachUserCustomFields(users []User, visibilities ...string) {
+	fields := getCustomFields(visibilities...)
+	if len(fields) == 0 || len(users) == 0 {
+		return
+	}
+
+	userIDs := make([]uint, 0, len(users))
+	for _, user := range users {
+		userIDs = append(userIDs, user.ID)
+	}
+	values := loadUserCustomFields(userIDs, fields)
+
+	for i := range users {
+		users[i].CustomFields = make(map[string]string)
+		for _, field := range fields {
+			users[i].CustomFields[field.Key] = values[users[i].ID][field.Key]
+		}
+	}
+}
+
+// 校验待保存的自定义字段值，isAdmin 为 false 时只允许修改本人可编辑的字段
+func checkUserCustomFields(values map[string]string, isAdmin bool) ([]CustomField, error) {
+	if len(values) == 0 {
+		return nil, nil
+	}
+
+	fieldMap := make(map[string]CustomField)
+	for _, field := range getCustomFields() {
+		fieldMap[field.Key] = field
+	}
+
+	fields := make([]CustomField, 0, len(values))
+	for key, value := range values {
+		field, ok := fieldMap[key]
+		if !ok {
+			return nil, fmt.Errorf("自定义字段不存在: %s", key)
+		}
+		if !isAdmin && field.Visibility != FieldVisibilitySelfEditable {
+			return nil, fmt.Errorf("无权修改字段: %s", field.Label)
+		}
+		if err := validateCustomFieldValue(field, value); err != nil {
+			return nil, err
+		}
+		fields = append(fields, field)
+	}
+	return fields, nil
+}
+
+// 检查必填字段是否都已提供
+func checkRequiredCustomFields(values map[string]string) error {
+	for _, field := range getCustomFields() {
+		if field.Required && values[field.Key] == "" {
+			return fmt.Errorf("%s 为必填项", field.Label)
+		}
+	}
+	return nil
+}
+
+// 保存用户的自定义字段值（调用前需先通过 checkUserCustomFields 校验）
+func saveUserCustomFields(tx *gorm.DB, userID uint, fields []CustomField, values map[string]string) error {
+	for _, field := range fields {
+		value := UserCustomFieldValue{
+			UserID:  userID,
+			FieldID: field.ID,
+			Value:   values[field.Key],
+		}
+		err := tx.Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "user_id"}, {Name: "field_id"}},
+			DoUpdates: clause.AssignmentColumns([]string{"value", "updated_at"}),
+		}).Create(&value).Error
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
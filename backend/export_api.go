@@ -3,6 +3,7 @@ package main
 import (
 	"encoding/csv"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
@@ -24,8 +25,15 @@ func exportUsersCSV(c *gin.Context) {
 	w := csv.NewWriter(c.Writer)
 	defer w.Flush()
 
+	// 自定义字段作为附加列（列名为字段显示名称）
+	customFields := getCustomFields()
+	attachUserCustomFields(users)
+
 	// 写入表头
 	headers := []string{"ID", "用户名", "邮箱", "角色", "状态", "真实姓名", "手机", "部门", "职位", "简介", "最后登录", "创建时间"}
+	for _, field := range customFields {
+		headers = append(headers, field.Label)
+	}
 	w.Write(headers)
 
 	// 写入数据
@@ -44,6 +52,9 @@ func exportUsersCSV(c *gin.Context) {
 			formatTime(user.LastLogin),
 			user.CreatedAt.Format("2006-01-02 15:04:05"),
 		}
+		for _, field := range customFields {
+			row = append(row, user.CustomFields[field.Key])
+		}
 		w.Write(row)
 	}
 }
@@ -115,6 +126,59 @@ func exportPermissionsCSV(c *gin.Context) {
 	}
 }
 
+// 导出用户组数据为CSV（成员和角色以“|”分隔）
+func exportUserGroupsCSV(c *gin.Context) {
+	var groups []UserGroup
+	result := db.Preload("Members").Preload("Roles").Find(&groups)
+	if result.Error != nil {
+		errorResponse(c, 500, "获取用户组数据失败")
+		return
+	}
+
+	// 上级组名称
+	groupNames := make(map[uint]string)
+	for _, group := range groups {
+		groupNames[group.ID] = group.Name
+	}
+
+	c.Header("Content-Disposition", "attachment; filename=user_groups_"+time.Now().Format("20060102_150405")+".csv")
+	c.Header("Content-Type", "text/csv; charset=utf-8")
+
+	w := csv.NewWriter(c.Writer)
+	defer w.Flush()
+
+	// 写入表头
+	headers := []string{"ID", "组名", "显示名", "描述", "上级组", "成员", "角色", "创建时间"}
+	w.Write(headers)
+
+	// 写入数据
+	for _, group := range groups {
+		parent := ""
+		if group.ParentID != nil {
+			parent = groupNames[*group.ParentID]
+		}
+		members := make([]string, 0, len(group.Members))
+		for _, member := range group.Members {
+			members = append(members, member.Username)
+		}
+		roles := make([]string, 0, len(group.Roles))
+		for _, role := range group.Roles {
+			roles = append(roles, role.Name)
+		}
+		row := []string{
+			strconv.FormatUint(uint64(group.ID), 10),
+			group.Name,
+			group.DisplayName,
+			group.Description,
+			parent,
+			strings.Join(members, "|"),
+			strings.Join(roles, "|"),
+			group.CreatedAt.Format("2006-01-02 15:04:05"),
+		}
+		w.Write(row)
+	}
+}
+
 // 工具函数：状态转换
 func ifThenElse(cond bool, a, b string) string {
 	if cond {
@@ -123,6 +187,19 @@ func ifThenElse(cond bool, a, b string) string {
 	return b
 }
 
+// 工具函数：解析日期（YYYY-MM-DD）或时间（RFC3339、YYYY-MM-DD HH:MM:SS）
+func parseDateTime(value string) (time.Time, error) {
+	layouts := []string{time.RFC3339, "2006-01-02 15:04:05", "2006-01-02"}
+	var err error
+	for _, layout := range layouts {
+		var t time.Time
+		if t, err = time.ParseInLocation(layout, value, time.Local); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, err
+}
+
 // 工具函数：格式化时间
 func formatTime(t *time.Time) string {
 	if t == nil {
@@ -7,6 +7,7 @@ import (
 	"strings"
 
 	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
 )
 
 // 导入用户数据（CSV）
@@ -48,6 +49,16 @@ func importUsersCSV(c *gin.Context) {
 		}
 	}
 
+	// 自定义字段列（列名可以是字段显示名称或键名）
+	customFieldColumns := make(map[string]int)
+	for _, field := range getCustomFields() {
+		if idx, ok := headerMap[field.Label]; ok {
+			customFieldColumns[field.Key] = idx
+		} else if idx, ok := headerMap[field.Key]; ok {
+			customFieldColumns[field.Key] = idx
+		}
+	}
+
 	// 统计
 	successCount := 0
 	errorRows := []string{}
@@ -80,6 +91,22 @@ func importUsersCSV(c *gin.Context) {
 			continue
 		}
 
+		// 读取并校验自定义字段
+		customValues := make(map[string]string)
+		for key, idx := range customFieldColumns {
+			if idx < len(record) {
+				customValues[key] = strings.TrimSpace(record[idx])
+			}
+		}
+		customFields, err := checkUserCustomFields(customValues, true)
+		if err == nil {
+			err = checkRequiredCustomFields(customValues)
+		}
+		if err != nil {
+			errorRows = append(errorRows, fmt.Sprintf("自定义字段错误: %s (%v)", username, err))
+			continue
+		}
+
 		// 默认密码
 		defaultPassword := "123456"
 		hashedPassword, _ := hashPassword(defaultPassword)
@@ -90,8 +117,24 @@ func importUsersCSV(c *gin.Context) {
 			Password: hashedPassword,
 			Role: role,
 			Status: status,
+			State: ifThenElse(status, UserStateActive, UserStateSuspended),
 		}
-		if err := db.Create(&user).Error; err != nil {
+		err = auditDB(c).Transaction(func(tx *gorm.DB) error {
+			if err := tx.Create(&user).Error; err != nil {
+				return err
+			}
+			// status 字段有默认值，false 不会在创建时写入
+			if !status {
+				if err := tx.Model(&user).Updates(userStateUpdates(UserStateSuspended, "导入时禁用", nil)).Error; err != nil {
+					return err
+				}
+			}
+			if err := recordUserStateHistory(tx, user.ID, "", user.State, "CSV导入", nil, c.GetUint("user_id"), c.GetString("username")); err != nil {
+				return err
+			}
+			return saveUserCustomFields(tx, user.ID, customFields, customValues)
+		})
+		if err != nil {
 			errorRows = append(errorRows, fmt.Sprintf("导入失败: %s (%v)", username, err))
 			continue
 		}
@@ -182,7 +225,7 @@ func importRolesCSV(c *gin.Context) {
 			Description: desc,
 			Status: status,
 		}
-		if err := db.Create(&role).Error; err != nil {
+		if err := auditDB(c).Create(&role).Error; err != nil {
 			errorRows = append(errorRows, fmt.Sprintf("导入失败: %s (%v)", name, err))
 			continue
 		}
@@ -274,16 +317,172 @@ func importPermissionsCSV(c *gin.Context) {
 			Action: action,
 			Description: desc,
 		}
-		if err := db.Create(&perm).Error; err != nil {
+		if err := auditDB(c).Create(&perm).Error; err != nil {
+			errorRows = append(errorRows, fmt.Sprintf("导入失败: %s (%v)", name, err))
+			continue
+		}
+		successCount++
+	}
+
+	successResponse(c, gin.H{
+		"success": successCount,
+		"failed": len(errorRows),
+		"errors": errorRows,
+	})
+} 
+
+// 导入用户组数据（CSV）
+func importUserGroupsCSV(c *gin.Context) {
+	file, err := c.FormFile("file")
+	if err != nil {
+		errorResponse(c, 400, "请上传CSV文件")
+		return
+	}
+
+	f, err := file.Open()
+	if err != nil {
+		errorResponse(c, 500, "文件读取失败")
+		return
+	}
+	defer f.Close()
+
+	r := csv.NewReader(f)
+	r.FieldsPerRecord = -1
+
+	headers, err := r.Read()
+	if err != nil {
+		errorResponse(c, 400, "CSV文件格式错误")
+		return
+	}
+
+	headerMap := make(map[string]int)
+	for i, h := range headers {
+		headerMap[strings.TrimSpace(h)] = i
+	}
+
+	required := []string{"组名", "显示名"}
+	for _, col := range required {
+		if _, ok := headerMap[col]; !ok {
+			errorResponse(c, 400, fmt.Sprintf("缺少必需列: %s", col))
+			return
+		}
+	}
+
+	// 读取可选列
+	optional := func(record []string, col string) string {
+		if idx, ok := headerMap[col]; ok && idx < len(record) {
+			return strings.TrimSpace(record[idx])
+		}
+		return ""
+	}
+	splitList := func(value string) []string {
+		items := []string{}
+		for _, item := range strings.Split(value, "|") {
+			if item = strings.TrimSpace(item); item != "" {
+				items = append(items, item)
+			}
+		}
+		return items
+	}
+
+	successCount := 0
+	errorRows := []string{}
+
+	// 第一遍创建用户组，第二遍再处理上级组、成员和角色，避免行顺序影响上级组引用
+	type pendingGroup struct {
+		group   UserGroup
+		parent  string
+		members []string
+		roles   []string
+	}
+	pending := []pendingGroup{}
+
+	for {
+		record, err := r.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			errorRows = append(errorRows, fmt.Sprintf("读取行失败: %v", err))
+			continue
+		}
+
+		name := strings.TrimSpace(record[headerMap["组名"]])
+		displayName := strings.TrimSpace(record[headerMap["显示名"]])
+		if name == "" || displayName == "" {
+			errorRows = append(errorRows, fmt.Sprintf("组名/显示名不能为空: %v", record))
+			continue
+		}
+
+		// 检查是否已存在
+		var existing UserGroup
+		if err := db.Where("name = ?", name).First(&existing).Error; err == nil {
+			errorRows = append(errorRows, fmt.Sprintf("用户组已存在: %s", name))
+			continue
+		}
+
+		group := UserGroup{
+			Name:        name,
+			DisplayName: displayName,
+			Description: optional(record, "描述"),
+		}
+		if err := auditDB(c).Create(&group).Error; err != nil {
 			errorRows = append(errorRows, fmt.Sprintf("导入失败: %s (%v)", name, err))
 			continue
 		}
+		pending = append(pending, pendingGroup{
+			group:   group,
+			parent:  optional(record, "上级组"),
+			members: splitList(optional(record, "成员")),
+			roles:   splitList(optional(record, "角色")),
+		})
 		successCount++
 	}
 
+	for _, item := range pending {
+		group := item.group
+		if item.parent != "" {
+			var parent UserGroup
+			if err := db.Where("name = ?", item.parent).First(&parent).Error; err != nil {
+				errorRows = append(errorRows, fmt.Sprintf("上级组不存在: %s -> %s", group.Name, item.parent))
+			} else if groupParentCreatesCycle(group.ID, parent.ID) {
+				// 按行顺序设置上级组，后设置的形成循环的行报错
+				errorRows = append(errorRows, fmt.Sprintf("上级组形成循环嵌套: %s -> %s", group.Name, item.parent))
+			} else if err := auditDB(c).Model(&group).Update("parent_id", parent.ID).Error; err != nil {
+				errorRows = append(errorRows, fmt.Sprintf("设置上级组失败: %s -> %s (%v)", group.Name, item.parent, err))
+			}
+		}
+		if len(item.members) > 0 {
+			var users []User
+			db.Where("username IN ?", item.members).Find(&users)
+			if len(users) != len(item.members) {
+				errorRows = append(errorRows, fmt.Sprintf("部分成员不存在: %s", group.Name))
+			}
+			if len(users) > 0 {
+				if err := db.Model(&group).Association("Members").Append(&users); err != nil {
+					errorRows = append(errorRows, fmt.Sprintf("添加成员失败: %s (%v)", group.Name, err))
+				}
+				recordAuditChange(c, "user_groups", group.ID, "members", []string{}, userGroupMemberNames(group.ID))
+			}
+		}
+		if len(item.roles) > 0 {
+			var roles []Role
+			db.Where("name IN ?", item.roles).Find(&roles)
+			if len(roles) != len(item.roles) {
+				errorRows = append(errorRows, fmt.Sprintf("部分角色不存在: %s", group.Name))
+			}
+			if len(roles) > 0 {
+				if err := db.Model(&group).Association("Roles").Append(&roles); err != nil {
+					errorRows = append(errorRows, fmt.Sprintf("分配角色失败: %s (%v)", group.Name, err))
+				}
+				recordAuditChange(c, "user_groups", group.ID, "roles", []string{}, userGroupRoleNames(group.ID))
+			}
+		}
+	}
+
 	successResponse(c, gin.H{
 		"success": successCount,
 		"failed": len(errorRows),
 		"errors": errorRows,
 	})
-} 
\ No newline at end of file
+}
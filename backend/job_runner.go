@@ -0,0 +1,72 @@
+package main
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// 后台定时任务运行器：任务启动时执行一次，之后按间隔执行；服务关闭时取消 context 并等待任务退出
+type JobRunner struct {
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// 全局任务运行器
+var jobRunner = newJobRunner()
+
+func newJobRunner() *JobRunner {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &JobRunner{ctx: ctx, cancel: cancel}
+}
+
+// 按间隔执行任务，任务应在 ctx 取消后尽快返回
+func (r *JobRunner) Every(name string, interval time.Duration, fn func(ctx context.Context)) {
+	r.wg.Add(1)
+	go func() {
+		defer r.wg.Done()
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			r.run(name, fn)
+			select {
+			case <-r.ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// 执行一次任务，panic 只记录日志，不影响下次执行
+func (r *JobRunner) run(name string, fn func(ctx context.Context)) {
+	defer func() {
+		if err := recover(); err != nil {
+			appLogger.Error("background job panicked", "job", name, "panic", err)
+		}
+	}()
+	if r.ctx.Err() == nil {
+		fn(r.ctx)
+	}
+}
+
+// 取消所有任务（注册到 srv.RegisterOnShutdown）
+func (r *JobRunner) Stop() {
+	r.cancel()
+}
+
+// 等待任务退出，ctx 结束时不再等待
+func (r *JobRunner) Wait(ctx context.Context) error {
+	done := make(chan struct{})
+	go func() {
+		r.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -1,33 +1,24 @@
 package main
 
 import (
+	"os"
+	"path/filepath"
 	"strconv"
 
 	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
 )
 
-// 获取操作日志列表
-func getOperationLogs(c *gin.Context) {
-	// 分页参数
-	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
-	pageSize, _ := strconv.Atoi(c.DefaultQuery("page_size", "20"))
-	if page < 1 {
-		page = 1
-	}
-	if pageSize > 100 {
-		pageSize = 100
-	}
-	
-	// 筛选参数
+// 根据筛选参数构建操作日志查询（列表和导出共用）
+func buildOperationLogQuery(c *gin.Context) *gorm.DB {
 	username := c.Query("username")
 	action := c.Query("action")
 	resource := c.Query("resource")
 	startDate := c.Query("start_date")
 	endDate := c.Query("end_date")
-	
-	// 构建查询
-	query := db.Model(&OperationLog{}).Preload("User")
-	
+
+	query := db.Model(&OperationLog{}).Where("archived = ?", false)
+
 	if username != "" {
 		query = query.Where("username LIKE ?", "%"+username+"%")
 	}
@@ -37,12 +28,35 @@ func getOperationLogs(c *gin.Context) {
 	if resource != "" {
 		query = query.Where("resource = ?", resource)
 	}
+	if requestID := c.Query("request_id"); requestID != "" {
+		query = query.Where("request_id = ?", requestID)
+	}
 	if startDate != "" {
 		query = query.Where("created_at >= ?", startDate)
 	}
 	if endDate != "" {
 		query = query.Where("created_at <= ?", endDate)
 	}
+	if q := c.Query("q"); q != "" {
+		query = applyLogSearch(query, q)
+	}
+	return query
+}
+
+// 获取操作日志列表
+func getOperationLogs(c *gin.Context) {
+	// 分页参数
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	pageSize, _ := strconv.Atoi(c.DefaultQuery("page_size", "20"))
+	if page < 1 {
+		page = 1
+	}
+	if pageSize > 100 {
+		pageSize = 100
+	}
+	
+	// 构建查询
+	query := buildOperationLogQuery(c).Preload("User")
 	
 	// 获取总数
 	var total int64
@@ -57,16 +71,28 @@ func getOperationLogs(c *gin.Context) {
 		errorResponse(c, 500, "获取操作日志失败")
 		return
 	}
+
+	// 全文检索时附加高亮片段
+	if q := c.Query("q"); q != "" {
+		attachLogSnippets(logs, q)
+	}
 	
 	successResponse(c, gin.H{
-		"logs":      logs,
-		"total":     total,
-		"page":      page,
-		"page_size": pageSize,
-		"pages":     (total + int64(pageSize) - 1) / int64(pageSize),
+		"logs":        logs,
+		"total":       total,
+		"page":        page,
+		"page_size":   pageSize,
+		"pages":       (total + int64(pageSize) - 1) / int64(pageSize),
+		"search_mode": logSearchMode(),
 	})
 }
 
+// 操作日志详情（包含按记录分组的字段变更）
+type OperationLogDetail struct {
+	OperationLog
+	Diff []AuditRecordDiff `json:"diff"`
+}
+
 // 根据ID获取操作日志详情
 func getOperationLogById(c *gin.Context) {
 	id := c.Param("id")
@@ -77,11 +103,19 @@ func getOperationLogById(c *gin.Context) {
 		return
 	}
 	
-	successResponse(c, log)
+	successResponse(c, OperationLogDetail{
+		OperationLog: log,
+		Diff:         parseLogDiff(log.Details),
+	})
 }
 
 // 删除操作日志
 func deleteOperationLog(c *gin.Context) {
+	if isLogAppendOnly() {
+		errorResponse(c, 403, "操作日志为只追加模式，不允许删除")
+		return
+	}
+
 	id := c.Param("id")
 	result := db.Delete(&OperationLog{}, id)
 	if result.Error != nil {
@@ -107,6 +141,11 @@ func batchDeleteOperationLogs(c *gin.Context) {
 		errorResponse(c, 400, "请求参数错误")
 		return
 	}
+
+	if isLogAppendOnly() {
+		errorResponse(c, 403, "操作日志为只追加模式，不允许删除")
+		return
+	}
 	
 	result := db.Where("id IN ?", req.IDs).Delete(&OperationLog{})
 	if result.Error != nil {
@@ -120,21 +159,130 @@ func batchDeleteOperationLogs(c *gin.Context) {
 	})
 }
 
-// 清空操作日志（保留最近30天）
+// 清空旧日志（立即执行保留策略，过期日志归档后删除）
 func clearOldOperationLogs(c *gin.Context) {
-	// 删除30天前的日志
-	result := db.Where("created_at < DATE('now', '-30 day')").Delete(&OperationLog{})
-	if result.Error != nil {
-		errorResponse(c, 500, "清空旧日志失败")
+	result, err := applyLogRetention(c.Request.Context())
+	if err != nil {
+		errorResponse(c, 500, "清空旧日志失败: "+err.Error())
 		return
 	}
-	
+
 	successResponse(c, gin.H{
-		"message": "清空旧日志成功",
-		"deleted": result.RowsAffected,
+		"message":     "清空旧日志成功",
+		"archived":    result.Archived,
+		"deleted":     result.Deleted,
+		"stubbed":     result.Stubbed,
+		"archive_dir": result.ArchiveDir,
 	})
 }
 
+// 获取日志保留规则和归档文件列表
+func getLogArchives(c *gin.Context) {
+	rules, err := getLogRetentionRules()
+	if err != nil {
+		errorResponse(c, 500, "日志保留规则格式错误")
+		return
+	}
+
+	dir := getLogArchiveDir()
+	files, err := listLogArchives(dir)
+	if err != nil {
+		errorResponse(c, 500, "获取归档文件失败")
+		return
+	}
+
+	successResponse(c, gin.H{
+		"rules":       rules,
+		"archive_dir": dir,
+		"archives":    files,
+		"total":       len(files),
+	})
+}
+
+// 下载日志归档文件
+func downloadLogArchive(c *gin.Context) {
+	path, err := resolveLogArchivePath(getLogArchiveDir(), c.Query("name"))
+	if err != nil {
+		errorResponse(c, 400, "归档文件名无效")
+		return
+	}
+	if _, err := os.Stat(path); err != nil {
+		errorResponse(c, 404, "归档文件不存在")
+		return
+	}
+
+	c.FileAttachment(path, filepath.Base(path))
+}
+
+// 验证操作日志哈希链
+func verifyOperationLogs(c *gin.Context) {
+	// 先写入队列中的日志
+	if operationLogWriter != nil {
+		operationLogWriter.Flush()
+	}
+
+	report, err := verifyLogChain()
+	if err != nil {
+		errorResponse(c, 500, "验证操作日志失败")
+		return
+	}
+	successResponse(c, report)
+}
+
+// 获取日志保留检查点
+func getLogCheckpoints(c *gin.Context) {
+	var checkpoints []LogCheckpoint
+	if err := db.Order("id DESC").Find(&checkpoints).Error; err != nil {
+		errorResponse(c, 500, "获取检查点失败")
+		return
+	}
+	successResponse(c, gin.H{
+		"checkpoints": checkpoints,
+		"total":       len(checkpoints),
+		"append_only": isLogAppendOnly(),
+	})
+}
+
+// 当前的检索方式
+func logSearchMode() string {
+	if logSearchFTS {
+		return "fts5"
+	}
+	return "like"
+}
+
+// 重建操作日志全文索引
+func rebuildOperationLogSearch(c *gin.Context) {
+	if !logSearchFTS {
+		errorResponse(c, 501, "当前构建不支持FTS5全文检索（需使用 -tags sqlite_fts5 编译），正在使用LIKE检索")
+		return
+	}
+
+	// 先写入队列中的日志
+	if operationLogWriter != nil {
+		operationLogWriter.Flush()
+	}
+
+	count, err := rebuildLogSearchIndex()
+	if err != nil {
+		errorResponse(c, 500, "重建全文索引失败")
+		return
+	}
+	successResponse(c, gin.H{
+		"message": "全文索引重建成功",
+		"indexed": count,
+	})
+}
+
+// 获取日志写入器运行指标
+func getLogWriterStats(c *gin.Context) {
+	if operationLogWriter == nil {
+		errorResponse(c, 503, "日志写入器未启动")
+		return
+	}
+	successResponse(c, operationLogWriter.Stats())
+}
+
 // 获取操作日志统计信息
 func getOperationLogStats(c *gin.Context) {
 	var stats struct {
@@ -146,29 +294,32 @@ func getOperationLogStats(c *gin.Context) {
 		UserStats      []map[string]interface{} `json:"user_stats"`
 	}
 	
+	// 已归档的日志不参与统计
+	logs := db.Model(&OperationLog{}).Where("archived = ?", false)
+
 	// 总日志数
-	db.Model(&OperationLog{}).Count(&stats.TotalLogs)
+	logs.Session(&gorm.Session{}).Count(&stats.TotalLogs)
 	
 	// 今日日志数
-	db.Model(&OperationLog{}).Where("DATE(created_at) = DATE('now')").Count(&stats.TodayLogs)
+	logs.Session(&gorm.Session{}).Where("DATE(created_at) = DATE('now')").Count(&stats.TodayLogs)
 	
 	// 本周日志数
-	db.Model(&OperationLog{}).Where("created_at >= DATE('now', '-7 day')").Count(&stats.WeekLogs)
+	logs.Session(&gorm.Session{}).Where("created_at >= DATE('now', '-7 day')").Count(&stats.WeekLogs)
 	
 	// 按操作类型统计
-	db.Model(&OperationLog{}).
+	logs.Session(&gorm.Session{}).
 		Select("action, COUNT(*) as count").
 		Group("action").
 		Scan(&stats.ActionStats)
 	
 	// 按资源类型统计
-	db.Model(&OperationLog{}).
+	logs.Session(&gorm.Session{}).
 		Select("resource, COUNT(*) as count").
 		Group("resource").
 		Scan(&stats.ResourceStats)
 	
 	// 按用户统计（前10名）
-	db.Model(&OperationLog{}).
+	logs.Session(&gorm.Session{}).
 		Select("username, COUNT(*) as count").
 		Group("username").
 		Order("count DESC").
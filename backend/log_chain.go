@@ -0,0 +1,344 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// 日志保留检查点：只追加模式下清理旧日志时记录被删除部分的最后一个哈希，
+// 使剩余日志仍能从检查点开始验证
+type LogCheckpoint struct {
+	ID            uint      `json:"id" gorm:"primaryKey"`
+	UpToLogID     uint      `json:"up_to_log_id" gorm:"not null"` // 已删除的最后一条日志ID（包含）
+	LastHash      string    `json:"last_hash" gorm:"not null"`    // 已删除的最后一条日志的哈希
+	RemovedCount  int64     `json:"removed_count"`                // 本次删除的日志数
+	OperatorID    uint      `json:"operator_id"`                  // 操作人ID，0表示系统
+	OperatorName  string    `json:"operator_name"`                // 操作人用户名
+	PrevSignature string    `json:"prev_signature"`               // 上一个检查点的签名
+	Signature     string    `json:"signature" gorm:"not null"`    // 检查点签名
+	CreatedAt     time.Time `json:"created_at"`
+}
+
+// 哈希链验证结果
+type LogChainReport struct {
+	Valid         bool           `json:"valid"`
+	Checked       int64          `json:"checked"`        // 验证的日志数
+	LegacyEntries int64          `json:"legacy_entries"` // 启用哈希链之前的日志数（不参与验证）
+	Checkpoints   int            `json:"checkpoints"`    // 检查点数量
+	StartHash     string         `json:"start_hash"`     // 验证起点哈希（来自最近的检查点）
+	LastHash      string         `json:"last_hash"`      // 链尾哈希
+	FirstBroken   *LogChainBreak `json:"first_broken"`   // 第一处断链
+}
+
+// 断链信息
+type LogChainBreak struct {
+	LogID        uint   `json:"log_id"`
+	CheckpointID uint   `json:"checkpoint_id,omitempty"`
+	Reason       string `json:"reason"`
+	Expected     string `json:"expected"`
+	Actual       string `json:"actual"`
+}
+
+// 写入日志时串行计算哈希链
+var logChainMu sync.Mutex
+
+// 擦除个人数据后的假名格式（见 pseudonymize）
+var pseudonymPattern = regexp.MustCompile(`^(ip|ua|pii)_[0-9a-f]{16}$`)
+
+// 哈希链和检查点签名密钥（部署密钥，与JWT密钥分开）
+var logChainKey []byte
+
+// 初始化哈希链
+func initLogChain() error {
+	var err error
+	if logChainKey, err = loadDeploymentSecret("JING_ADMIN_LOG_CHAIN_KEY", "log_chain.key"); err != nil {
+		return err
+	}
+	return db.AutoMigrate(&LogCheckpoint{})
+}
+
+// 是否启用只追加模式（禁止删除单条日志，清理旧日志时生成检查点）
+func isLogAppendOnly() bool {
+	return configCache.GetBool("log_append_only", true)
+}
+
+// 计算HMAC
+func logChainMAC(parts ...string) string {
+	mac := hmac.New(sha256.New, logChainKey)
+	mac.Write([]byte(strings.Join(parts, "\x1f")))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// 个人信息字段按假名参与哈希，擦除个人数据后哈希保持不变
+func chainPersonalField(prefix, value string) string {
+	if value == "" || pseudonymPattern.MatchString(value) {
+		return value
+	}
+	return pseudonymize(prefix, value)
+}
+
+// 详情中用户资料变更的个人信息按假名参与哈希，擦除时替换后哈希保持不变
+func chainDetails(details string) string {
+	return pseudonymizeLogDetails(details, func(string) bool { return true })
+}
+
+// 计算日志内容哈希（用户名是 user_id 的冗余副本，且擦除时会被替换，不参与哈希）
+// 归档后数据库中只保留内容哈希，归档文件中的日志按它核对
+func computeOperationLogContentHash(entry *OperationLog) string {
+	parts := []string{
+		strconv.FormatUint(uint64(entry.UserID), 10),
+		entry.Action,
+		entry.Resource,
+		entry.ResourceID,
+		entry.Method,
+		entry.Path,
+		chainPersonalField("ip_", entry.IP),
+		chainPersonalField("ua_", entry.UserAgent),
+		strconv.Itoa(entry.Status),
+		chainDetails(entry.Details),
+		strconv.FormatInt(entry.CreatedAt.UnixNano(), 10),
+		entry.Description,
+		entry.RequestID,
+	}
+	return logChainMAC(parts...)
+}
+
+// 计算日志哈希：前一条日志的哈希 + 本条的内容哈希
+func computeOperationLogHash(entry *OperationLog) string {
+	return logChainMAC(entry.PrevHash, entry.ContentHash)
+}
+
+// 计算检查点签名
+func computeCheckpointSignature(cp *LogCheckpoint) string {
+	return logChainMAC(
+		cp.PrevSignature,
+		strconv.FormatUint(uint64(cp.UpToLogID), 10),
+		cp.LastHash,
+		strconv.FormatInt(cp.RemovedCount, 10),
+		strconv.FormatUint(uint64(cp.OperatorID), 10),
+		strconv.FormatInt(cp.CreatedAt.UnixNano(), 10),
+	)
+}
+
+// 获取链尾哈希：最后一条日志的哈希，没有日志时使用最近检查点的哈希
+func chainTailHash(tx *gorm.DB) (string, error) {
+	var last OperationLog
+	err := tx.Select("id", "hash").Order("id DESC").Limit(1).Find(&last).Error
+	if err != nil {
+		return "", err
+	}
+	if last.ID != 0 {
+		return last.Hash, nil
+	}
+
+	var cp LogCheckpoint
+	if err := tx.Order("id DESC").Limit(1).Find(&cp).Error; err != nil {
+		return "", err
+	}
+	return cp.LastHash, nil
+}
+
+// 追加操作日志：在同一事务中计算哈希链并批量写入
+func appendOperationLogs(entries []*OperationLog, batchSize int) error {
+	logChainMu.Lock()
+	defer logChainMu.Unlock()
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		prev, err := chainTailHash(tx)
+		if err != nil {
+			return err
+		}
+		for _, entry := range entries {
+			entry.ID = 0
+			entry.PrevHash = prev
+			entry.ContentHash = computeOperationLogContentHash(entry)
+			entry.Hash = computeOperationLogHash(entry)
+			prev = entry.Hash
+		}
+		return tx.Omit("User").CreateInBatches(entries, batchSize).Error
+	})
+	if err != nil {
+		return err
+	}
+
+	// 推送给实时日志流的订阅者
+	logBroker.Publish(entries)
+	return nil
+}
+
+// 删除指定日志ID及之前的所有日志，并记录检查点
+func truncateOperationLogs(upToID uint, operatorID uint, operatorName string) (int64, error) {
+	logChainMu.Lock()
+	defer logChainMu.Unlock()
+
+	var removed int64
+	err := db.Transaction(func(tx *gorm.DB) error {
+		var last OperationLog
+		if err := tx.Select("id", "hash").First(&last, upToID).Error; err != nil {
+			return err
+		}
+
+		result := tx.Where("id <= ?", upToID).Delete(&OperationLog{})
+		if result.Error != nil {
+			return result.Error
+		}
+		removed = result.RowsAffected
+
+		var prevCheckpoint LogCheckpoint
+		if err := tx.Order("id DESC").Limit(1).Find(&prevCheckpoint).Error; err != nil {
+			return err
+		}
+		cp := LogCheckpoint{
+			UpToLogID:     upToID,
+			LastHash:      last.Hash,
+			RemovedCount:  removed,
+			OperatorID:    operatorID,
+			OperatorName:  operatorName,
+			PrevSignature: prevCheckpoint.Signature,
+			CreatedAt:     time.Now(),
+		}
+		cp.Signature = computeCheckpointSignature(&cp)
+		return tx.Create(&cp).Error
+	})
+	return removed, err
+}
+
+// 验证哈希链，返回第一处断链
+func verifyLogChain() (*LogChainReport, error) {
+	report := &LogChainReport{Valid: true}
+
+	// 检查点本身也构成一条签名链
+	var checkpoints []LogCheckpoint
+	if err := db.Order("id").Find(&checkpoints).Error; err != nil {
+		return nil, err
+	}
+	report.Checkpoints = len(checkpoints)
+	prevSignature := ""
+	var startAfter uint
+	for _, cp := range checkpoints {
+		if cp.PrevSignature != prevSignature {
+			report.Valid = false
+			report.FirstBroken = &LogChainBreak{CheckpointID: cp.ID, Reason: "检查点链断开", Expected: prevSignature, Actual: cp.PrevSignature}
+			return report, nil
+		}
+		if expected := computeCheckpointSignature(&cp); expected != cp.Signature {
+			report.Valid = false
+			report.FirstBroken = &LogChainBreak{CheckpointID: cp.ID, Reason: "检查点签名无效", Expected: expected, Actual: cp.Signature}
+			return report, nil
+		}
+		prevSignature = cp.Signature
+		report.StartHash = cp.LastHash
+		startAfter = cp.UpToLogID
+	}
+
+	// 从最近的检查点开始逐条验证
+	expectedPrev := report.StartHash
+	chained := report.StartHash != ""
+	archives := newLogArchiveIndex(getLogArchiveDir())
+	var batch []OperationLog
+	err := db.Where("id > ?", startAfter).Order("id").FindInBatches(&batch, 1000, func(tx *gorm.DB, _ int) error {
+		for i := range batch {
+			entry := &batch[i]
+			if entry.Hash == "" {
+				// 启用哈希链之前的日志
+				if !chained {
+					report.LegacyEntries++
+					continue
+				}
+				report.FirstBroken = &LogChainBreak{LogID: entry.ID, Reason: "日志缺少哈希", Expected: expectedPrev}
+				return errLogChainBroken
+			}
+			chained = true
+			report.Checked++
+
+			if entry.PrevHash != expectedPrev {
+				report.FirstBroken = &LogChainBreak{LogID: entry.ID, Reason: "前序日志被删除或篡改", Expected: expectedPrev, Actual: entry.PrevHash}
+				return errLogChainBroken
+			}
+			if hash := computeOperationLogHash(entry); hash != entry.Hash {
+				report.FirstBroken = &LogChainBreak{LogID: entry.ID, Reason: "日志哈希无效", Expected: hash, Actual: entry.Hash}
+				return errLogChainBroken
+			}
+
+			// 已归档的日志内容在归档文件中，按保留的内容哈希核对归档文件
+			content := entry
+			if entry.Archived {
+				archived, err := archives.lookup(entry)
+				if err != nil {
+					return err
+				}
+				if archived == nil {
+					report.FirstBroken = &LogChainBreak{LogID: entry.ID, Reason: "归档文件中缺少日志", Expected: entry.ContentHash}
+					return errLogChainBroken
+				}
+				content = archived
+			}
+			if hash := computeOperationLogContentHash(content); hash != entry.ContentHash {
+				report.FirstBroken = &LogChainBreak{LogID: entry.ID, Reason: "日志内容被修改", Expected: entry.ContentHash, Actual: hash}
+				return errLogChainBroken
+			}
+			expectedPrev = entry.Hash
+		}
+		return nil
+	}).Error
+	if err != nil && !errors.Is(err, errLogChainBroken) {
+		return nil, err
+	}
+
+	report.Valid = report.FirstBroken == nil
+	report.LastHash = expectedPrev
+	return report, nil
+}
+
+var errLogChainBroken = errors.New("log chain broken")
+
+// 归档文件索引：验证时按日期读取归档文件，找出已归档日志的原始内容
+type logArchiveIndex struct {
+	dir     string
+	entries map[string]map[uint]*OperationLog // 日期 -> 日志ID -> 日志
+}
+
+func newLogArchiveIndex(dir string) *logArchiveIndex {
+	return &logArchiveIndex{dir: dir, entries: make(map[string]map[uint]*OperationLog)}
+}
+
+// 查找已归档日志的原始内容，归档文件中不存在时返回 nil
+func (idx *logArchiveIndex) lookup(stub *OperationLog) (*OperationLog, error) {
+	date := stub.CreatedAt.Format("2006-01-02")
+	entries, ok := idx.entries[date]
+	if !ok {
+		var err error
+		if entries, err = readLogArchive(logArchivePath(idx.dir, date)); err != nil {
+			return nil, err
+		}
+		idx.entries[date] = entries
+	}
+	return entries[stub.ID], nil
+}
+
+// 命令行验证哈希链，链完整时返回0
+func runVerifyLogsCommand() int {
+	report, err := verifyLogChain()
+	if err != nil {
+		appLogger.Error("failed to verify operation logs", "error", err)
+		return 2
+	}
+
+	data, _ := json.MarshalIndent(report, "", "  ")
+	fmt.Println(string(data))
+	if !report.Valid {
+		return 1
+	}
+	return 0
+}
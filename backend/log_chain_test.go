@@ -0,0 +1,219 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	gormlogger "gorm.io/gorm/logger"
+)
+
+// 使用临时数据库和固定密钥初始化日志相关的全局状态
+func setupTestDB(t *testing.T, configs map[string]string) {
+	t.Helper()
+
+	dir := t.TempDir()
+	testDB, err := gorm.Open(sqlite.Open(filepath.Join(dir, "test.db")), &gorm.Config{Logger: gormlogger.Default.LogMode(gormlogger.Silent)})
+	if err != nil {
+		t.Fatalf("open database: %v", err)
+	}
+	db = testDB
+	if err := db.AutoMigrate(&User{}, &OperationLog{}, &LogCheckpoint{}, &SystemConfig{}); err != nil {
+		t.Fatalf("migrate database: %v", err)
+	}
+	logChainKey = []byte(strings.Repeat("k", 32))
+	pseudonymKey = []byte(strings.Repeat("p", 32))
+
+	if _, ok := configs["log_archive_dir"]; !ok {
+		configs["log_archive_dir"] = filepath.Join(dir, "archive")
+	}
+	for key, value := range configs {
+		if err := db.Create(&SystemConfig{Key: key, Value: value, Type: "string", Category: "system"}).Error; err != nil {
+			t.Fatalf("create config %s: %v", key, err)
+		}
+	}
+	configCache = &ConfigCache{}
+	if _, err := configCache.Reload(); err != nil {
+		t.Fatalf("load configs: %v", err)
+	}
+}
+
+// 写入 n 条 days 天前的日志，details 中包含用户资料的变更记录
+func appendTestLogs(t *testing.T, n int, days int) {
+	t.Helper()
+
+	entries := make([]*OperationLog, 0, n)
+	for i := 0; i < n; i++ {
+		details, _ := json.Marshal(map[string]interface{}{
+			"changes": []map[string]interface{}{
+				{"table": "users", "record_id": "7", "op": "update", "field": "email", "old": "old@example.com", "new": fmt.Sprintf("user%d@example.com", i)},
+			},
+		})
+		entries = append(entries, &OperationLog{
+			UserID:    7,
+			Username:  "alice",
+			Action:    "update",
+			Resource:  "user",
+			Method:    "PUT",
+			Path:      "/api/users/7",
+			IP:        "192.168.1.7",
+			UserAgent: "test-agent",
+			Status:    200,
+			Details:   string(details),
+			CreatedAt: time.Now().AddDate(0, 0, -days).Add(time.Duration(i) * time.Second),
+		})
+	}
+	if err := appendOperationLogs(entries, 100); err != nil {
+		t.Fatalf("append logs: %v", err)
+	}
+}
+
+func assertLogChainValid(t *testing.T) *LogChainReport {
+	t.Helper()
+
+	report, err := verifyLogChain()
+	if err != nil {
+		t.Fatalf("verify log chain: %v", err)
+	}
+	if !report.Valid {
+		t.Fatalf("log chain broken: %+v", report.FirstBroken)
+	}
+	return report
+}
+
+func TestVerifyLogChainDetectsTampering(t *testing.T) {
+	setupTestDB(t, map[string]string{})
+	appendTestLogs(t, 5, 0)
+	assertLogChainValid(t)
+
+	db.Model(&OperationLog{}).Where("id = ?", 3).Update("status", 500)
+	report, err := verifyLogChain()
+	if err != nil {
+		t.Fatalf("verify log chain: %v", err)
+	}
+	if report.Valid || report.FirstBroken == nil || report.FirstBroken.LogID != 3 {
+		t.Fatalf("expected break at log 3, got %+v", report.FirstBroken)
+	}
+}
+
+func TestVerifyLogChainAfterRetention(t *testing.T) {
+	for _, appendOnly := range []string{"true", "false"} {
+		t.Run("append_only="+appendOnly, func(t *testing.T) {
+			setupTestDB(t, map[string]string{
+				"log_append_only":        appendOnly,
+				"log_retention_rules":    `[{"days":30}]`,
+				"log_retention_min_days": "30",
+			})
+			appendTestLogs(t, 3, 60)
+			appendTestLogs(t, 2, 0)
+
+			result, err := applyLogRetention(context.Background())
+			if err != nil {
+				t.Fatalf("apply retention: %v", err)
+			}
+			if result.Archived != 3 {
+				t.Fatalf("archived = %d, want 3", result.Archived)
+			}
+
+			var remaining int64
+			db.Model(&OperationLog{}).Count(&remaining)
+			if remaining != 2 {
+				t.Fatalf("remaining logs = %d, want 2", remaining)
+			}
+			// 非只追加模式删除日志不生成检查点，剩余日志的链起点缺失
+			if appendOnly == "false" {
+				return
+			}
+			report := assertLogChainValid(t)
+			if report.Checkpoints != 1 || report.Checked != 2 {
+				t.Fatalf("checkpoints = %d, checked = %d, want 1 and 2", report.Checkpoints, report.Checked)
+			}
+
+			// 后续写入的日志继续接在检查点之后
+			appendTestLogs(t, 1, 0)
+			assertLogChainValid(t)
+		})
+	}
+}
+
+// 日志已归档但尚未截断时，验证要按内容哈希核对归档文件
+func TestVerifyLogChainWithArchivedStubs(t *testing.T) {
+	setupTestDB(t, map[string]string{
+		"log_retention_rules":    `[{"resource":"user","days":30}]`,
+		"log_retention_min_days": "30",
+	})
+	appendTestLogs(t, 2, 0)
+	appendTestLogs(t, 2, 60)
+
+	if _, err := applyLogRetention(context.Background()); err != nil {
+		t.Fatalf("apply retention: %v", err)
+	}
+	var stubs int64
+	db.Model(&OperationLog{}).Where("archived = ?", true).Count(&stubs)
+	if stubs != 2 {
+		t.Fatalf("archived stubs = %d, want 2", stubs)
+	}
+	report := assertLogChainValid(t)
+	if report.Checked != 4 {
+		t.Fatalf("checked = %d, want 4", report.Checked)
+	}
+
+	// 归档文件中的个人信息已替换为假名
+	archived, err := readLogArchive(logArchivePath(getLogArchiveDir(), time.Now().AddDate(0, 0, -60).Format("2006-01-02")))
+	if err != nil {
+		t.Fatalf("read archive: %v", err)
+	}
+	for _, entry := range archived {
+		if entry.Username != "" || entry.IP == "192.168.1.7" || strings.Contains(entry.Details, "@example.com") {
+			t.Fatalf("archived log %d contains personal data: %+v", entry.ID, entry)
+		}
+	}
+}
+
+func TestVerifyLogChainAfterErasure(t *testing.T) {
+	setupTestDB(t, map[string]string{
+		"log_retention_rules":    `[{"days":30}]`,
+		"log_retention_min_days": "30",
+	})
+	appendTestLogs(t, 2, 60)
+	if _, err := applyLogRetention(context.Background()); err != nil {
+		t.Fatalf("apply retention: %v", err)
+	}
+	appendTestLogs(t, 3, 0)
+
+	// 与 erasePersonalData 相同的日志处理
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := pseudonymizeUserClientInfo(tx, &OperationLog{}, 7); err != nil {
+			return err
+		}
+		if err := tx.Model(&OperationLog{}).Where("user_id = ?", 7).Update("username", "erased_user").Error; err != nil {
+			return err
+		}
+		scrubbed, err := scrubPersonalDataInLogs(tx, 7)
+		if err != nil {
+			return err
+		}
+		if scrubbed != 3 {
+			return fmt.Errorf("scrubbed = %d, want 3", scrubbed)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("erase personal data: %v", err)
+	}
+
+	var logs []OperationLog
+	db.Find(&logs)
+	for _, entry := range logs {
+		if entry.IP == "192.168.1.7" || strings.Contains(entry.Details, "@example.com") {
+			t.Fatalf("log %d still contains personal data: %+v", entry.ID, entry)
+		}
+	}
+	assertLogChainValid(t)
+}
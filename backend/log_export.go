@@ -0,0 +1,280 @@
+package main
+
+import (
+	"archive/zip"
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"encoding/xml"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// XLSX 单个工作表的最大行数（含表头）
+const xlsxMaxRows = 1048576
+
+// 操作日志导出列
+var operationLogExportHeaders = []string{"ID", "用户名", "操作", "资源", "资源ID", "描述", "方法", "路径", "IP", "用户代理", "状态码", "详情", "请求ID", "时间"}
+
+// 操作日志导出为一行
+func operationLogExportRow(entry *OperationLog) []string {
+	return []string{
+		strconv.FormatUint(uint64(entry.ID), 10),
+		entry.Username,
+		entry.Action,
+		entry.Resource,
+		entry.ResourceID,
+		entry.Description,
+		entry.Method,
+		entry.Path,
+		entry.IP,
+		entry.UserAgent,
+		strconv.Itoa(entry.Status),
+		entry.Details,
+		entry.RequestID,
+		entry.CreatedAt.Format("2006-01-02 15:04:05"),
+	}
+}
+
+// 逐行写入导出文件
+type logExportWriter interface {
+	WriteRow(entry *OperationLog) error
+	Close() error
+}
+
+// 导出操作日志（format=csv/jsonl/xlsx，筛选参数与日志列表相同，逐行流式输出）
+func exportOperationLogs(c *gin.Context) {
+	format := c.DefaultQuery("format", "csv")
+	if format != "csv" && format != "jsonl" && format != "xlsx" {
+		errorResponse(c, 400, "不支持的导出格式: "+format)
+		return
+	}
+
+	// 行数上限：系统配置为硬上限，limit 参数可进一步缩小
+	maxRows := configCache.GetInt("log_export_max_rows", 100000)
+	if maxRows <= 0 {
+		maxRows = 100000
+	}
+	if limit, err := strconv.Atoi(c.Query("limit")); err == nil && limit > 0 && limit < maxRows {
+		maxRows = limit
+	}
+	if format == "xlsx" && maxRows > xlsxMaxRows-1 {
+		maxRows = xlsxMaxRows - 1
+	}
+
+	query := buildOperationLogQuery(c)
+
+	var total int64
+	if err := query.Session(&gorm.Session{}).Count(&total).Error; err != nil {
+		errorResponse(c, 500, "导出操作日志失败")
+		return
+	}
+
+	rows, err := query.Order("id").Limit(maxRows).Rows()
+	if err != nil {
+		errorResponse(c, 500, "导出操作日志失败")
+		return
+	}
+	defer rows.Close()
+
+	filename := "operation_logs_" + time.Now().Format("20060102_150405") + "." + format
+	c.Header("Content-Disposition", "attachment; filename="+filename)
+	c.Header("X-Export-Total", strconv.FormatInt(total, 10))
+	c.Header("X-Export-Limit", strconv.Itoa(maxRows))
+	c.Header("X-Export-Truncated", strconv.FormatBool(total > int64(maxRows)))
+
+	var writer logExportWriter
+	switch format {
+	case "csv":
+		c.Header("Content-Type", "text/csv; charset=utf-8")
+		writer = newCSVLogExportWriter(c.Writer)
+	case "jsonl":
+		c.Header("Content-Type", "application/x-ndjson; charset=utf-8")
+		writer = newJSONLLogExportWriter(c.Writer)
+	case "xlsx":
+		c.Header("Content-Type", "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet")
+		writer, err = newXLSXLogExportWriter(c.Writer)
+		if err != nil {
+			return
+		}
+	}
+	c.Status(200)
+
+	for rows.Next() {
+		var entry OperationLog
+		if err := db.ScanRows(rows, &entry); err != nil {
+			break
+		}
+		if err := writer.WriteRow(&entry); err != nil {
+			// 客户端断开连接
+			break
+		}
+	}
+	writer.Close()
+}
+
+// CSV 导出
+type csvLogExportWriter struct {
+	w     *csv.Writer
+	count int
+}
+
+func newCSVLogExportWriter(out io.Writer) *csvLogExportWriter {
+	w := csv.NewWriter(out)
+	w.Write(operationLogExportHeaders)
+	return &csvLogExportWriter{w: w}
+}
+
+func (e *csvLogExportWriter) WriteRow(entry *OperationLog) error {
+	if err := e.w.Write(operationLogExportRow(entry)); err != nil {
+		return err
+	}
+	// 定期刷新，避免在内存中积压
+	e.count++
+	if e.count%1000 == 0 {
+		e.w.Flush()
+		return e.w.Error()
+	}
+	return nil
+}
+
+func (e *csvLogExportWriter) Close() error {
+	e.w.Flush()
+	return e.w.Error()
+}
+
+// JSON Lines 导出
+type jsonlLogExportWriter struct {
+	buf     *bufio.Writer
+	encoder *json.Encoder
+}
+
+func newJSONLLogExportWriter(out io.Writer) *jsonlLogExportWriter {
+	buf := bufio.NewWriter(out)
+	return &jsonlLogExportWriter{buf: buf, encoder: json.NewEncoder(buf)}
+}
+
+func (e *jsonlLogExportWriter) WriteRow(entry *OperationLog) error {
+	return e.encoder.Encode(struct {
+		ID          uint      `json:"id"`
+		UserID      uint      `json:"user_id"`
+		Username    string    `json:"username"`
+		Action      string    `json:"action"`
+		Resource    string    `json:"resource"`
+		ResourceID  string    `json:"resource_id"`
+		Description string    `json:"description"`
+		Method      string    `json:"method"`
+		Path        string    `json:"path"`
+		IP          string    `json:"ip"`
+		UserAgent   string    `json:"user_agent"`
+		Status      int       `json:"status"`
+		Details     string    `json:"details"`
+		RequestID   string    `json:"request_id"`
+		Hash        string    `json:"hash"`
+		CreatedAt   time.Time `json:"created_at"`
+	}{
+		entry.ID, entry.UserID, entry.Username, entry.Action, entry.Resource, entry.ResourceID, entry.Description,
+		entry.Method, entry.Path, entry.IP, entry.UserAgent, entry.Status, entry.Details, entry.RequestID, entry.Hash, entry.CreatedAt,
+	})
+}
+
+func (e *jsonlLogExportWriter) Close() error {
+	return e.buf.Flush()
+}
+
+// XLSX 导出：直接生成最小的 OpenXML 包，工作表使用内联字符串逐行写入
+type xlsxLogExportWriter struct {
+	zw    *zip.Writer
+	sheet *bufio.Writer
+	row   int
+}
+
+// XLSX 包中的固定部件
+var xlsxStaticParts = []struct{ name, content string }{
+	{"[Content_Types].xml", `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types"><Default Extension="rels" ContentType="application/vnd.openxmlformats-package.relationships+xml"/><Default Extension="xml" ContentType="application/xml"/><Override PartName="/xl/workbook.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.sheet.main+xml"/><Override PartName="/xl/worksheets/sheet1.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.worksheet+xml"/></Types>`},
+	{"_rels/.rels", `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships"><Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/officeDocument" Target="xl/workbook.xml"/></Relationships>`},
+	{"xl/workbook.xml", `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships"><sheets><sheet name="操作日志" sheetId="1" r:id="rId1"/></sheets></workbook>`},
+	{"xl/_rels/workbook.xml.rels", `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships"><Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet" Target="worksheets/sheet1.xml"/></Relationships>`},
+}
+
+func newXLSXLogExportWriter(out io.Writer) (*xlsxLogExportWriter, error) {
+	zw := zip.NewWriter(out)
+	for _, part := range xlsxStaticParts {
+		w, err := zw.Create(part.name)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := io.WriteString(w, part.content); err != nil {
+			return nil, err
+		}
+	}
+
+	w, err := zw.Create("xl/worksheets/sheet1.xml")
+	if err != nil {
+		return nil, err
+	}
+	e := &xlsxLogExportWriter{zw: zw, sheet: bufio.NewWriter(w)}
+	e.sheet.WriteString(`<?xml version="1.0" encoding="UTF-8" standalone="yes"?>` + "\n" +
+		`<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main"><sheetData>`)
+	if err := e.writeCells(operationLogExportHeaders, -1); err != nil {
+		return nil, err
+	}
+	return e, nil
+}
+
+// 写入一行单元格，numericCol 列按数字写入（-1 表示全部为文本）
+func (e *xlsxLogExportWriter) writeCells(values []string, numericCol int) error {
+	e.row++
+	rowNum := strconv.Itoa(e.row)
+	e.sheet.WriteString(`<row r="` + rowNum + `">`)
+	for i, value := range values {
+		ref := xlsxColumnName(i) + rowNum
+		if i == numericCol {
+			e.sheet.WriteString(`<c r="` + ref + `"><v>` + value + `</v></c>`)
+			continue
+		}
+		e.sheet.WriteString(`<c r="` + ref + `" t="inlineStr"><is><t xml:space="preserve">`)
+		xml.EscapeText(e.sheet, []byte(value))
+		e.sheet.WriteString(`</t></is></c>`)
+	}
+	_, err := e.sheet.WriteString(`</row>`)
+	return err
+}
+
+func (e *xlsxLogExportWriter) WriteRow(entry *OperationLog) error {
+	// 单元格最多 32767 个字符
+	row := operationLogExportRow(entry)
+	for i, value := range row {
+		if len(value) > 32767 {
+			row[i] = strings.ToValidUTF8(value[:32767], "")
+		}
+	}
+	return e.writeCells(row, 0)
+}
+
+func (e *xlsxLogExportWriter) Close() error {
+	e.sheet.WriteString(`</sheetData></worksheet>`)
+	if err := e.sheet.Flush(); err != nil {
+		return err
+	}
+	return e.zw.Close()
+}
+
+// 列序号转换为列名（0 -> A, 26 -> AA）
+func xlsxColumnName(index int) string {
+	name := ""
+	for index >= 0 {
+		name = string(rune('A'+index%26)) + name
+		index = index/26 - 1
+	}
+	return name
+}
@@ -8,20 +8,27 @@ import (
 
 // 操作日志模型
 type OperationLog struct {
-	ID         uint      `json:"id" gorm:"primaryKey"`
-	UserID     uint      `json:"user_id" gorm:"not null"`
-	Username   string    `json:"username" gorm:"not null"`
-	Action     string    `json:"action" gorm:"not null"`      // 操作类型：create, update, delete, login, logout
-	Resource   string    `json:"resource" gorm:"not null"`    // 操作资源：user, role, permission, system
-	ResourceID string    `json:"resource_id"`                 // 资源ID
-	Method     string    `json:"method" gorm:"not null"`      // HTTP方法：GET, POST, PUT, DELETE
-	Path       string    `json:"path" gorm:"not null"`        // 请求路径
-	IP         string    `json:"ip" gorm:"not null"`          // 用户IP
-	UserAgent  string    `json:"user_agent"`                  // 用户代理
-	Status     int       `json:"status" gorm:"not null"`      // 响应状态码
-	Details    string    `json:"details" gorm:"type:text"`    // 详细信息
-	CreatedAt  time.Time `json:"created_at"`
-	User       User      `json:"user" gorm:"foreignKey:UserID"`
+	ID          uint      `json:"id" gorm:"primaryKey"`
+	UserID      uint      `json:"user_id" gorm:"not null"`
+	Username    string    `json:"username" gorm:"not null"`
+	Action      string    `json:"action" gorm:"not null"`   // 操作类型：create, update, delete, login, logout
+	Resource    string    `json:"resource" gorm:"not null"` // 操作资源：user, role, permission, system
+	ResourceID  string    `json:"resource_id"`              // 资源ID
+	Description string    `json:"description"`              // 操作描述（来自路由审计信息）
+	RequestID   string    `json:"request_id" gorm:"index"`  // 请求ID，与访问日志和响应中的 request_id 相同
+	Method      string    `json:"method" gorm:"not null"`   // HTTP方法：GET, POST, PUT, DELETE
+	Path        string    `json:"path" gorm:"not null"`     // 请求路径
+	IP          string    `json:"ip" gorm:"not null"`       // 用户IP
+	UserAgent   string    `json:"user_agent"`               // 用户代理
+	Status      int       `json:"status" gorm:"not null"`   // 响应状态码
+	Details     string    `json:"details" gorm:"type:text"` // 详细信息（JSON，changes 为字段变更列表）
+	PrevHash    string    `json:"prev_hash"`                // 前一条日志的哈希
+	ContentHash string    `json:"content_hash"`             // 日志内容的哈希（归档后保留，用于核对归档文件）
+	Hash        string    `json:"hash" gorm:"index"`        // 本条日志的哈希（哈希链）
+	Archived    bool      `json:"archived" gorm:"index"`    // 已归档：内容已移入归档文件，仅保留哈希
+	CreatedAt   time.Time `json:"created_at"`
+	User        User      `json:"user" gorm:"foreignKey:UserID"`
+	Snippet     string    `json:"snippet,omitempty" gorm:"-"` // 全文检索的高亮片段
 }
 
 // 初始化日志系统
@@ -31,38 +38,80 @@ func initLogSystem() error {
 	if err != nil {
 		return err
 	}
-	return nil
+	if err := initLogChain(); err != nil {
+		return err
+	}
+	return initLogSearch()
 }
 
 // 记录操作日志
-func logOperation(userID uint, username, action, resource, resourceID, method, path, ip, userAgent string, status int, details string) {
+func logOperation(userID uint, username, action, resource, resourceID, description, requestID, method, path, ip, userAgent string, status int, details string) {
 	log := OperationLog{
-		UserID:     userID,
-		Username:   username,
-		Action:     action,
-		Resource:   resource,
-		ResourceID: resourceID,
-		Method:     method,
-		Path:       path,
-		IP:         ip,
-		UserAgent:  userAgent,
-		Status:     status,
-		Details:    details,
-		CreatedAt:  time.Now(),
+		UserID:      userID,
+		Username:    username,
+		Action:      action,
+		Resource:    resource,
+		ResourceID:  resourceID,
+		Description: description,
+		RequestID:   requestID,
+		Method:      method,
+		Path:        path,
+		IP:          ip,
+		UserAgent:   userAgent,
+		Status:      status,
+		Details:     details,
+		CreatedAt:   time.Now(),
 	}
 	
-	// 异步写入日志，避免影响主要业务
-	go func() {
-		db.Create(&log)
-	}()
+	// 通过异步写入器批量写入，避免影响主要业务
+	if operationLogWriter != nil {
+		operationLogWriter.Write(&log)
+		return
+	}
+	appendOperationLogs([]*OperationLog{&log}, 1)
+}
+
+// 使用请求上下文记录操作日志（处理函数自行记录后，日志中间件不再重复记录）
+func logOperationFromContext(c *gin.Context, action, resource, resourceID, details string) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		return
+	}
+	username, _ := c.Get("username")
+	audit, _ := routeAuditFromContext(c)
+
+	logOperation(
+		userID.(uint),
+		username.(string),
+		action,
+		resource,
+		resourceID,
+		audit.Description,
+		requestIDFromContext(c),
+		c.Request.Method,
+		c.Request.URL.Path,
+		c.ClientIP(),
+		c.Request.UserAgent(),
+		c.Writer.Status(),
+		buildLogDetails(c, details),
+	)
+	c.Set("operation_logged", true)
 }
 
 // 日志中间件
 func logMiddleware() gin.HandlerFunc {
 	return func(c *gin.Context) {
+		// 收集请求中通过 auditDB 产生的字段变更
+		attachAuditCollector(c)
+
 		// 处理请求
 		c.Next()
 		
+		// 处理函数已自行记录日志
+		if c.GetBool("operation_logged") {
+			return
+		}
+
 		// 获取用户信息
 		userID, exists := c.Get("user_id")
 		if !exists {
@@ -71,9 +120,8 @@ func logMiddleware() gin.HandlerFunc {
 		
 		username, _ := c.Get("username")
 		
-		// 确定操作类型和资源
-		action := getActionFromMethod(c.Request.Method)
-		resource := getResourceFromPath(c.Request.URL.Path)
+		// 根据路由模板确定操作类型、资源和描述
+		audit, _ := routeAuditFromContext(c)
 		resourceID := c.Param("id")
 		
 		// 获取IP地址
@@ -87,52 +135,22 @@ func logMiddleware() gin.HandlerFunc {
 			logOperation(
 				uid,
 				uname,
-				action,
-				resource,
+				audit.Action,
+				audit.Resource,
 				resourceID,
+				audit.Description,
+				requestIDFromContext(c),
 				c.Request.Method,
 				c.Request.URL.Path,
 				ip,
 				c.Request.UserAgent(),
 				c.Writer.Status(),
-				"",
+				buildLogDetails(c, ""),
 			)
 		}
 	}
 }
 
-// 从HTTP方法确定操作类型
-func getActionFromMethod(method string) string {
-	switch method {
-	case "GET":
-		return "read"
-	case "POST":
-		return "create"
-	case "PUT":
-		return "update"
-	case "DELETE":
-		return "delete"
-	default:
-		return "unknown"
-	}
-}
-
-// 从请求路径确定资源类型
-func getResourceFromPath(path string) string {
-	if contains(path, "/users") {
-		return "user"
-	} else if contains(path, "/roles") {
-		return "role"
-	} else if contains(path, "/permissions") {
-		return "permission"
-	} else if contains(path, "/system") {
-		return "system"
-	} else if contains(path, "/auth") {
-		return "auth"
-	}
-	return "other"
-}
-
 // 判断是否需要记录日志
 func shouldLogOperation(path string) bool {
 	// 不记录的路径
@@ -151,17 +169,3 @@ func shouldLogOperation(path string) bool {
 	
 	return true
 }
-
-// 辅助函数：检查字符串是否包含子字符串
-func contains(s, substr string) bool {
-	return len(s) >= len(substr) && (s == substr || (len(s) > len(substr) && (s[:len(substr)] == substr || s[len(s)-len(substr):] == substr || findSubstring(s, substr))))
-}
-
-func findSubstring(s, substr string) bool {
-	for i := 0; i <= len(s)-len(substr); i++ {
-		if s[i:i+len(substr)] == substr {
-			return true
-		}
-	}
-	return false
-} 
\ No newline at end of file
@@ -0,0 +1,334 @@
+package main
+
+import (
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// 日志保留规则（按顺序匹配，第一条匹配的规则生效）
+type LogRetentionRule struct {
+	Resource string `json:"resource"` // 资源类型，为空表示任意
+	Action   string `json:"action"`   // 操作类型，为空表示任意
+	Days     int    `json:"days"`     // 保留天数，小于等于0表示永久保留
+}
+
+// 保留策略执行结果
+type LogRetentionResult struct {
+	Archived   int64  `json:"archived"`    // 归档的日志数
+	Deleted    int64  `json:"deleted"`     // 删除的日志数（只追加模式下为截断到检查点的数量）
+	Stubbed    int64  `json:"stubbed"`     // 只追加模式下清空内容、保留哈希的日志数
+	ArchiveDir string `json:"archive_dir"` // 归档目录
+}
+
+// 默认保留规则：所有日志保留30天
+const defaultLogRetentionRules = `[{"days":30}]`
+
+// 每批处理的日志数
+const logRetentionBatchSize = 500
+
+// 获取日志保留规则
+func getLogRetentionRules() ([]LogRetentionRule, error) {
+	var rules []LogRetentionRule
+	if err := configCache.GetJSON("log_retention_rules", defaultLogRetentionRules, &rules); err != nil {
+		return nil, fmt.Errorf("invalid log_retention_rules: %w", err)
+	}
+	return rules, nil
+}
+
+// 获取归档目录（不可在线修改），避免管理员把归档写到别处后清除日志
+func getLogArchiveDir() string {
+	return getConfigValue("log_archive_dir", filepath.Join("logs", "archive"))
+}
+
+// 规则匹配条件
+func retentionRuleCondition(tx *gorm.DB, rule LogRetentionRule) *gorm.DB {
+	cond := tx.Session(&gorm.Session{NewDB: true}).Where("1 = 1")
+	if rule.Resource != "" {
+		cond = cond.Where("resource = ?", rule.Resource)
+	}
+	if rule.Action != "" {
+		cond = cond.Where("action = ?", rule.Action)
+	}
+	return cond
+}
+
+// 日志最短保留天数（不可在线修改），保留规则中更短的天数按它计算，避免管理员通过修改规则清除自己的操作痕迹
+func getLogRetentionMinDays() int {
+	return configCache.GetInt("log_retention_min_days", 30)
+}
+
+// 执行日志保留策略：归档过期日志后删除，ctx 取消时在当前批次完成后停止
+func applyLogRetention(ctx context.Context) (*LogRetentionResult, error) {
+	rules, err := getLogRetentionRules()
+	if err != nil {
+		return nil, err
+	}
+	minDays := getLogRetentionMinDays()
+
+	result := &LogRetentionResult{ArchiveDir: getLogArchiveDir()}
+	appendOnly := isLogAppendOnly()
+	now := time.Now()
+
+	for i, rule := range rules {
+		if rule.Days <= 0 {
+			continue
+		}
+		days := rule.Days
+		if days < minDays {
+			days = minDays
+		}
+
+		// 只处理匹配当前规则、且未被前面的规则匹配的日志
+		query := db.Model(&OperationLog{}).
+			Where("archived = ?", false).
+			Where("created_at < ?", now.AddDate(0, 0, -days)).
+			Where(retentionRuleCondition(db, rule))
+		for _, prev := range rules[:i] {
+			query = query.Not(retentionRuleCondition(db, prev))
+		}
+
+		for {
+			if err := ctx.Err(); err != nil {
+				return result, err
+			}
+			var batch []OperationLog
+			if err := query.Session(&gorm.Session{}).Order("id").Limit(logRetentionBatchSize).Find(&batch).Error; err != nil {
+				return result, err
+			}
+			if len(batch) == 0 {
+				break
+			}
+
+			if err := archiveOperationLogs(result.ArchiveDir, batch); err != nil {
+				return result, err
+			}
+			result.Archived += int64(len(batch))
+
+			ids := make([]uint, len(batch))
+			for j, entry := range batch {
+				ids[j] = entry.ID
+			}
+			if appendOnly {
+				stubbed, err := stubOperationLogs(ids)
+				if err != nil {
+					return result, err
+				}
+				result.Stubbed += stubbed
+			} else {
+				deleted := db.Where("id IN ?", ids).Delete(&OperationLog{})
+				if deleted.Error != nil {
+					return result, deleted.Error
+				}
+				result.Deleted += deleted.RowsAffected
+			}
+
+			if len(batch) < logRetentionBatchSize {
+				break
+			}
+		}
+	}
+
+	// 只追加模式下，开头连续的已归档日志截断为检查点
+	if appendOnly {
+		var upToID uint
+		db.Model(&OperationLog{}).
+			Where("archived = ? AND id < COALESCE((SELECT MIN(id) FROM operation_logs WHERE archived = ?), (SELECT MAX(id) FROM operation_logs) + 1)", true, false).
+			Select("COALESCE(MAX(id), 0)").
+			Scan(&upToID)
+		if upToID > 0 {
+			deleted, err := truncateOperationLogs(upToID, 0, "system")
+			if err != nil {
+				return result, err
+			}
+			result.Deleted += deleted
+		}
+	}
+
+	return result, nil
+}
+
+// 清空日志内容，只保留哈希链所需的字段
+func stubOperationLogs(ids []uint) (int64, error) {
+	logChainMu.Lock()
+	defer logChainMu.Unlock()
+
+	result := db.Model(&OperationLog{}).Where("id IN ?", ids).Updates(map[string]interface{}{
+		"archived":    true,
+		"user_id":     0,
+		"username":    "",
+		"action":      "",
+		"resource":    "",
+		"resource_id": "",
+		"description": "",
+		"request_id":  "",
+		"method":      "",
+		"path":        "",
+		"ip":          "",
+		"user_agent":  "",
+		"status":      0,
+		"details":     "",
+	})
+	return result.RowsAffected, result.Error
+}
+
+// 按日期将日志追加到压缩的 JSON Lines 文件（每次追加一个新的 gzip 成员）
+func archiveOperationLogs(dir string, entries []OperationLog) error {
+	partitions := make(map[string][]OperationLog)
+	for _, entry := range entries {
+		date := entry.CreatedAt.Format("2006-01-02")
+		partitions[date] = append(partitions[date], entry)
+	}
+
+	for date, items := range partitions {
+		path := logArchivePath(dir, date)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			return err
+		}
+		file, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+		if err != nil {
+			return err
+		}
+
+		gz := gzip.NewWriter(file)
+		encoder := json.NewEncoder(gz)
+		for i := range items {
+			if err = encoder.Encode(pseudonymizeArchivedLog(items[i])); err != nil {
+				break
+			}
+		}
+		if closeErr := gz.Close(); err == nil {
+			err = closeErr
+		}
+		if closeErr := file.Close(); err == nil {
+			err = closeErr
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// 归档文件中的日志不再随个人数据擦除更新，个人信息写入前按哈希链使用的假名替换，
+// 内容哈希不变，仍可按数据库中保留的内容哈希核对（用户名不参与哈希，直接清空）
+func pseudonymizeArchivedLog(entry OperationLog) *OperationLog {
+	entry.Username = ""
+	entry.IP = chainPersonalField("ip_", entry.IP)
+	entry.UserAgent = chainPersonalField("ua_", entry.UserAgent)
+	entry.Details = chainDetails(entry.Details)
+	entry.User = User{}
+	return &entry
+}
+
+// 归档文件路径（按年/月分目录）
+func logArchivePath(dir, date string) string {
+	return filepath.Join(dir, date[:4], date[5:7], "operation_logs-"+date+".jsonl.gz")
+}
+
+// 读取归档文件中的全部日志，文件不存在时返回空
+func readLogArchive(path string) (map[uint]*OperationLog, error) {
+	entries := make(map[uint]*OperationLog)
+	file, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return entries, nil
+		}
+		return nil, err
+	}
+	defer file.Close()
+
+	gz, err := gzip.NewReader(file)
+	if err != nil {
+		return nil, fmt.Errorf("read archive %s: %w", path, err)
+	}
+	defer gz.Close()
+	decoder := json.NewDecoder(gz)
+	for {
+		var entry OperationLog
+		if err := decoder.Decode(&entry); err != nil {
+			if errors.Is(err, io.EOF) {
+				return entries, nil
+			}
+			return nil, fmt.Errorf("read archive %s: %w", path, err)
+		}
+		entries[entry.ID] = &entry
+	}
+}
+
+// 归档文件信息
+type LogArchiveFile struct {
+	Name       string    `json:"name"` // 相对于归档目录的路径
+	Date       string    `json:"date"`
+	Size       int64     `json:"size"`
+	ModifiedAt time.Time `json:"modified_at"`
+}
+
+// 列出归档文件（按日期倒序）
+func listLogArchives(dir string) ([]LogArchiveFile, error) {
+	files := []LogArchiveFile{}
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		}
+		if info.IsDir() || !strings.HasSuffix(info.Name(), ".jsonl.gz") {
+			return nil
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		files = append(files, LogArchiveFile{
+			Name:       filepath.ToSlash(rel),
+			Date:       strings.TrimSuffix(strings.TrimPrefix(info.Name(), "operation_logs-"), ".jsonl.gz"),
+			Size:       info.Size(),
+			ModifiedAt: info.ModTime(),
+		})
+		return nil
+	})
+	sort.Slice(files, func(i, j int) bool { return files[i].Name > files[j].Name })
+	return files, err
+}
+
+// 解析归档文件路径，禁止访问归档目录以外的文件
+func resolveLogArchivePath(dir, name string) (string, error) {
+	if name == "" || !strings.HasSuffix(name, ".jsonl.gz") {
+		return "", errors.New("invalid archive name")
+	}
+	path := filepath.Join(dir, filepath.FromSlash(name))
+	rel, err := filepath.Rel(dir, path)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", errors.New("invalid archive name")
+	}
+	return path, nil
+}
+
+// 执行一次保留策略并记录日志
+func runLogRetention(ctx context.Context) {
+	result, err := applyLogRetention(ctx)
+	if err != nil && !errors.Is(err, context.Canceled) {
+		appLogger.Error("failed to apply log retention", "error", err)
+		return
+	}
+	if result.Archived > 0 || result.Deleted > 0 {
+		appLogger.Info("log retention applied", "archived", result.Archived, "stubbed", result.Stubbed, "deleted", result.Deleted)
+	}
+}
+
+// 启动日志保留定时任务
+func startLogRetentionJob(interval time.Duration) {
+	jobRunner.Every("log_retention", interval, runLogRetention)
+}
@@ -0,0 +1,289 @@
+package main
+
+import (
+	"errors"
+	"html"
+	"strings"
+	"unicode/utf8"
+
+	"gorm.io/gorm"
+)
+
+// 是否启用了 FTS5（需要使用 -tags sqlite_fts5 编译 go-sqlite3）
+var logSearchFTS bool
+
+// 全文检索索引的字段
+var logSearchColumns = []string{"path", "details", "user_agent", "username"}
+
+// 片段高亮的临时标记，转义HTML后再替换为 <mark>
+const (
+	snippetMarkStart = "\x01"
+	snippetMarkEnd   = "\x02"
+)
+
+// 检索词
+type logSearchTerm struct {
+	Text   string
+	Prefix bool // 前缀匹配（以 * 结尾）
+}
+
+// 初始化操作日志全文检索：创建 FTS5 外部内容表和同步触发器，不支持时回退为 LIKE 检索
+func initLogSearch() error {
+	// 同步触发器不存在时（首次创建或曾以不支持FTS5的构建运行）需要重建索引
+	var synced int64
+	db.Raw("SELECT COUNT(*) FROM sqlite_master WHERE type = 'trigger' AND name = 'operation_logs_fts_insert'").Scan(&synced)
+
+	// 索引表已存在时 CREATE VIRTUAL TABLE IF NOT EXISTS 不会加载模块，需单独检测是否支持FTS5
+	var enabled int
+	db.Raw("SELECT sqlite_compileoption_used('ENABLE_FTS5')").Scan(&enabled)
+
+	// 使用 trigram 分词：默认的 unicode61 不切分中文，整段中文会成为一个词而无法检索其中的词语
+	// trigram 按子串匹配（不区分大小写），少于3个字符的检索词无法使用索引，改用 LIKE
+	err := errors.New("sqlite3 built without ENABLE_FTS5")
+	if enabled == 1 {
+		var recreated bool
+		if recreated, err = dropOutdatedLogSearchTable(); recreated {
+			synced = 0
+		}
+		if err == nil {
+			err = db.Exec(`CREATE VIRTUAL TABLE IF NOT EXISTS operation_logs_fts USING fts5(
+				path, details, user_agent, username,
+				content='operation_logs', content_rowid='id',
+				tokenize='trigram'
+			)`).Error
+		}
+	}
+	if err != nil {
+		appLogger.Warn("FTS5 unavailable, operation log search falls back to LIKE", "error", err)
+		logSearchFTS = false
+
+		// 数据库曾由支持FTS5的构建创建时，删除同步触发器，否则写入日志会失败
+		for _, trigger := range []string{"operation_logs_fts_insert", "operation_logs_fts_delete", "operation_logs_fts_update"} {
+			if err := db.Exec("DROP TRIGGER IF EXISTS " + trigger).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	triggers := []string{
+		`CREATE TRIGGER IF NOT EXISTS operation_logs_fts_insert AFTER INSERT ON operation_logs BEGIN
+			INSERT INTO operation_logs_fts(rowid, path, details, user_agent, username)
+			VALUES (new.id, new.path, new.details, new.user_agent, new.username);
+		END`,
+		`CREATE TRIGGER IF NOT EXISTS operation_logs_fts_delete AFTER DELETE ON operation_logs BEGIN
+			INSERT INTO operation_logs_fts(operation_logs_fts, rowid, path, details, user_agent, username)
+			VALUES ('delete', old.id, old.path, old.details, old.user_agent, old.username);
+		END`,
+		`CREATE TRIGGER IF NOT EXISTS operation_logs_fts_update AFTER UPDATE ON operation_logs BEGIN
+			INSERT INTO operation_logs_fts(operation_logs_fts, rowid, path, details, user_agent, username)
+			VALUES ('delete', old.id, old.path, old.details, old.user_agent, old.username);
+			INSERT INTO operation_logs_fts(rowid, path, details, user_agent, username)
+			VALUES (new.id, new.path, new.details, new.user_agent, new.username);
+		END`,
+	}
+	for _, trigger := range triggers {
+		if err := db.Exec(trigger).Error; err != nil {
+			return err
+		}
+	}
+	logSearchFTS = true
+
+	// 为已有日志建立索引
+	if synced == 0 {
+		if _, err := rebuildLogSearchIndex(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// 删除旧版本使用默认分词创建的索引表，返回是否已删除（需要重新创建并重建索引）
+func dropOutdatedLogSearchTable() (bool, error) {
+	var sql string
+	db.Raw("SELECT sql FROM sqlite_master WHERE type = 'table' AND name = 'operation_logs_fts'").Scan(&sql)
+	if sql == "" || strings.Contains(sql, "trigram") {
+		return false, nil
+	}
+	appLogger.Info("recreating operation log search index with trigram tokenizer")
+	return true, db.Exec("DROP TABLE operation_logs_fts").Error
+}
+
+// 重建全文索引，返回索引的日志数
+func rebuildLogSearchIndex() (int64, error) {
+	if err := db.Exec("INSERT INTO operation_logs_fts(operation_logs_fts) VALUES('rebuild')").Error; err != nil {
+		return 0, err
+	}
+	var count int64
+	err := db.Model(&OperationLog{}).Count(&count).Error
+	return count, err
+}
+
+// 解析检索语句：双引号内为短语，以 * 结尾为前缀匹配，多个词之间为 AND
+func parseLogSearchQuery(q string) []logSearchTerm {
+	var terms []logSearchTerm
+	for q = strings.TrimSpace(q); q != ""; q = strings.TrimSpace(q) {
+		var text string
+		if q[0] == '"' {
+			end := strings.IndexByte(q[1:], '"')
+			if end < 0 {
+				text, q = q[1:], ""
+			} else {
+				text, q = q[1:end+1], q[end+2:]
+			}
+		} else {
+			end := strings.IndexAny(q, " \t\"")
+			if end < 0 {
+				text, q = q, ""
+			} else {
+				text, q = q[:end], q[end:]
+			}
+		}
+
+		term := logSearchTerm{Text: text}
+		if strings.HasPrefix(q, "*") {
+			term.Prefix, q = true, q[1:]
+		} else if strings.HasSuffix(term.Text, "*") {
+			term.Prefix, term.Text = true, strings.TrimSuffix(term.Text, "*")
+		}
+		if term.Text = strings.TrimSpace(term.Text); term.Text != "" {
+			terms = append(terms, term)
+		}
+	}
+	return terms
+}
+
+// trigram 索引最少需要3个字符，更短的检索词只能用 LIKE 匹配
+const logSearchMinFTSLength = 3
+
+// 按是否能使用 trigram 索引拆分检索词
+func splitLogSearchTerms(terms []logSearchTerm) (ftsTerms, likeTerms []logSearchTerm) {
+	for _, term := range terms {
+		if utf8.RuneCountInString(term.Text) < logSearchMinFTSLength {
+			likeTerms = append(likeTerms, term)
+		} else {
+			ftsTerms = append(ftsTerms, term)
+		}
+	}
+	return ftsTerms, likeTerms
+}
+
+// 生成 FTS5 MATCH 表达式（每个词都作为带引号的字符串，避免语法错误）
+// trigram 按子串匹配，已包含前缀匹配，不再追加 *
+func buildFTSMatch(terms []logSearchTerm) string {
+	parts := make([]string, 0, len(terms))
+	for _, term := range terms {
+		parts = append(parts, `"`+strings.ReplaceAll(term.Text, `"`, `""`)+`"`)
+	}
+	return strings.Join(parts, " ")
+}
+
+// 为查询添加全文检索条件
+func applyLogSearch(query *gorm.DB, q string) *gorm.DB {
+	terms := parseLogSearchQuery(q)
+	if len(terms) == 0 {
+		return query
+	}
+
+	if logSearchFTS {
+		var ftsTerms []logSearchTerm
+		ftsTerms, terms = splitLogSearchTerms(terms)
+		if len(ftsTerms) > 0 {
+			query = query.Where("operation_logs.id IN (SELECT rowid FROM operation_logs_fts WHERE operation_logs_fts MATCH ?)", buildFTSMatch(ftsTerms))
+		}
+	}
+
+	// 回退（以及过短的检索词）：每个词需出现在任一字段中
+	for _, term := range terms {
+		pattern := "%" + escapeLike(term.Text) + "%"
+		conditions := make([]string, 0, len(logSearchColumns))
+		args := make([]interface{}, 0, len(logSearchColumns))
+		for _, column := range logSearchColumns {
+			conditions = append(conditions, column+` LIKE ? ESCAPE '\'`)
+			args = append(args, pattern)
+		}
+		query = query.Where("("+strings.Join(conditions, " OR ")+")", args...)
+	}
+	return query
+}
+
+// 转义 LIKE 通配符
+func escapeLike(value string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(value)
+}
+
+// 为检索结果生成高亮片段（HTML，匹配部分用 <mark> 包裹）
+func attachLogSnippets(logs []OperationLog, q string) {
+	terms := parseLogSearchQuery(q)
+	if len(terms) == 0 || len(logs) == 0 {
+		return
+	}
+
+	snippets := make(map[uint]string)
+	if ftsTerms, _ := splitLogSearchTerms(terms); logSearchFTS && len(ftsTerms) > 0 {
+		ids := make([]uint, len(logs))
+		for i := range logs {
+			ids[i] = logs[i].ID
+		}
+		var rows []struct {
+			ID      uint
+			Snippet string
+		}
+		db.Raw(`SELECT rowid AS id, snippet(operation_logs_fts, -1, ?, ?, '…', 16) AS snippet
+			FROM operation_logs_fts WHERE operation_logs_fts MATCH ? AND rowid IN ?`,
+			snippetMarkStart, snippetMarkEnd, buildFTSMatch(ftsTerms), ids).Scan(&rows)
+		for _, row := range rows {
+			snippets[row.ID] = row.Snippet
+		}
+	}
+
+	for i := range logs {
+		snippet := snippets[logs[i].ID]
+		if snippet == "" {
+			snippet = likeSnippet(&logs[i], terms)
+		}
+		if snippet != "" {
+			logs[i].Snippet = strings.NewReplacer(snippetMarkStart, "<mark>", snippetMarkEnd, "</mark>").Replace(html.EscapeString(snippet))
+		}
+	}
+}
+
+// LIKE 检索时在Go中截取包含第一个匹配词的片段
+func likeSnippet(entry *OperationLog, terms []logSearchTerm) string {
+	for _, value := range []string{entry.Path, entry.Details, entry.UserAgent, entry.Username} {
+		lower := strings.ToLower(value)
+		if len(lower) != len(value) {
+			lower = value
+		}
+		for _, term := range terms {
+			needle := strings.ToLower(term.Text)
+			index := strings.Index(lower, needle)
+			if index < 0 {
+				continue
+			}
+			end := index + len(needle)
+
+			// 前后各保留约40个字符
+			start := index
+			for n := 0; start > 0 && n < 40; n++ {
+				_, size := utf8.DecodeLastRuneInString(value[:start])
+				start -= size
+			}
+			stop := end
+			for n := 0; stop < len(value) && n < 40; n++ {
+				_, size := utf8.DecodeRuneInString(value[stop:])
+				stop += size
+			}
+
+			snippet := value[start:index] + snippetMarkStart + value[index:end] + snippetMarkEnd + value[end:stop]
+			if start > 0 {
+				snippet = "…" + snippet
+			}
+			if stop < len(value) {
+				snippet += "…"
+			}
+			return snippet
+		}
+	}
+	return ""
+}
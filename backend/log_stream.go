@@ -0,0 +1,322 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// 实时日志流参数
+const (
+	logStreamBuffer     = 256              // 每个订阅者的缓冲区大小
+	logStreamBacklog    = 1000             // 断线重连时最多补发的日志数
+	logStreamHeartbeat  = 15 * time.Second // 心跳间隔
+	logStreamTicketTTL  = time.Minute      // 订阅票据有效期
+	logStreamRetryDelay = 3000             // 客户端重连间隔（毫秒）
+)
+
+// 日志流订阅过滤条件
+type logStreamFilter struct {
+	UserID    uint
+	Username  string
+	Resource  string
+	Action    string
+	MinStatus int
+}
+
+// 解析过滤条件
+func parseLogStreamFilter(c *gin.Context) logStreamFilter {
+	filter := logStreamFilter{
+		Username: c.Query("username"),
+		Resource: c.Query("resource"),
+		Action:   c.Query("action"),
+	}
+	if userID, err := strconv.ParseUint(c.Query("user_id"), 10, 32); err == nil {
+		filter.UserID = uint(userID)
+	}
+	if minStatus, err := strconv.Atoi(c.Query("min_status")); err == nil {
+		filter.MinStatus = minStatus
+	}
+	if c.Query("errors_only") == "true" && filter.MinStatus < 400 {
+		filter.MinStatus = 400
+	}
+	return filter
+}
+
+// 判断日志是否匹配过滤条件
+func (f logStreamFilter) Match(entry *OperationLog) bool {
+	return (f.UserID == 0 || entry.UserID == f.UserID) &&
+		(f.Username == "" || entry.Username == f.Username) &&
+		(f.Resource == "" || entry.Resource == f.Resource) &&
+		(f.Action == "" || entry.Action == f.Action) &&
+		entry.Status >= f.MinStatus
+}
+
+// 将过滤条件应用到数据库查询
+func (f logStreamFilter) Apply(query *gorm.DB) *gorm.DB {
+	if f.UserID != 0 {
+		query = query.Where("user_id = ?", f.UserID)
+	}
+	if f.Username != "" {
+		query = query.Where("username = ?", f.Username)
+	}
+	if f.Resource != "" {
+		query = query.Where("resource = ?", f.Resource)
+	}
+	if f.Action != "" {
+		query = query.Where("action = ?", f.Action)
+	}
+	if f.MinStatus > 0 {
+		query = query.Where("status >= ?", f.MinStatus)
+	}
+	return query
+}
+
+// 日志流订阅者
+type logSubscriber struct {
+	filter logStreamFilter
+	ch     chan OperationLog
+	lagged chan struct{} // 缓冲区满时关闭，客户端需重连补发
+	once   sync.Once
+}
+
+// 日志广播器：日志写入数据库后推送给所有订阅者
+type LogBroker struct {
+	mu          sync.RWMutex
+	subscribers map[*logSubscriber]struct{}
+	closed      chan struct{}
+	closeOnce   sync.Once
+}
+
+var logBroker = &LogBroker{
+	subscribers: make(map[*logSubscriber]struct{}),
+	closed:      make(chan struct{}),
+}
+
+// 订阅
+func (b *LogBroker) Subscribe(filter logStreamFilter) *logSubscriber {
+	sub := &logSubscriber{
+		filter: filter,
+		ch:     make(chan OperationLog, logStreamBuffer),
+		lagged: make(chan struct{}),
+	}
+	b.mu.Lock()
+	b.subscribers[sub] = struct{}{}
+	b.mu.Unlock()
+	return sub
+}
+
+// 取消订阅
+func (b *LogBroker) Unsubscribe(sub *logSubscriber) {
+	b.mu.Lock()
+	delete(b.subscribers, sub)
+	b.mu.Unlock()
+}
+
+// 推送日志（不阻塞写入；订阅者跟不上时断开，由客户端凭 Last-Event-ID 补发）
+func (b *LogBroker) Publish(entries []*OperationLog) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	for sub := range b.subscribers {
+		for _, entry := range entries {
+			if !sub.filter.Match(entry) {
+				continue
+			}
+			select {
+			case sub.ch <- *entry:
+			default:
+				sub.once.Do(func() { close(sub.lagged) })
+			}
+		}
+	}
+}
+
+// 订阅者数量
+func (b *LogBroker) Count() int {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	return len(b.subscribers)
+}
+
+// 关闭所有日志流（服务器退出时调用）
+func (b *LogBroker) Close() {
+	b.closeOnce.Do(func() { close(b.closed) })
+}
+
+// 订阅票据（EventSource 无法设置请求头，先用令牌换取一次性票据）
+type logStreamTicket struct {
+	UserID    uint
+	Username  string
+	Role      string
+	ExpiresAt time.Time
+}
+
+var (
+	logStreamTicketsMu sync.Mutex
+	logStreamTickets   = make(map[string]logStreamTicket)
+)
+
+// 签发日志流订阅票据
+func createLogStreamTicket(c *gin.Context) {
+	buf := make([]byte, 24)
+	if _, err := rand.Read(buf); err != nil {
+		errorResponse(c, 500, "生成票据失败")
+		return
+	}
+	ticket := hex.EncodeToString(buf)
+	expiresAt := time.Now().Add(logStreamTicketTTL)
+
+	logStreamTicketsMu.Lock()
+	for key, t := range logStreamTickets {
+		if time.Now().After(t.ExpiresAt) {
+			delete(logStreamTickets, key)
+		}
+	}
+	logStreamTickets[ticket] = logStreamTicket{
+		UserID:    c.GetUint("user_id"),
+		Username:  c.GetString("username"),
+		Role:      c.GetString("role"),
+		ExpiresAt: expiresAt,
+	}
+	logStreamTicketsMu.Unlock()
+
+	successResponse(c, gin.H{
+		"ticket":     ticket,
+		"expires_at": expiresAt,
+	})
+}
+
+// 日志流认证：支持 Authorization 请求头或一次性票据（ticket 参数）
+func logStreamAuthMiddleware() gin.HandlerFunc {
+	tokenAuth := authMiddleware()
+	return func(c *gin.Context) {
+		ticket := c.Query("ticket")
+		if ticket == "" {
+			tokenAuth(c)
+			return
+		}
+
+		logStreamTicketsMu.Lock()
+		t, ok := logStreamTickets[ticket]
+		delete(logStreamTickets, ticket)
+		logStreamTicketsMu.Unlock()
+		if !ok || time.Now().After(t.ExpiresAt) {
+			errorResponse(c, 401, "订阅票据无效或已过期")
+			c.Abort()
+			return
+		}
+
+		c.Set("user_id", t.UserID)
+		c.Set("username", t.Username)
+		c.Set("role", t.Role)
+		c.Next()
+	}
+}
+
+// 写入一条 SSE 事件
+func writeSSEEvent(c *gin.Context, entry *OperationLog) error {
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(c.Writer, "id: %d\nevent: log\ndata: %s\n\n", entry.ID, data)
+	return err
+}
+
+// 实时推送操作日志（Server-Sent Events）
+func streamOperationLogs(c *gin.Context) {
+	filter := parseLogStreamFilter(c)
+
+	// 断线重连时从 Last-Event-ID 之后补发
+	var lastID uint64
+	if value := c.GetHeader("Last-Event-ID"); value != "" {
+		lastID, _ = strconv.ParseUint(value, 10, 64)
+	} else if value := c.Query("last_event_id"); value != "" {
+		lastID, _ = strconv.ParseUint(value, 10, 64)
+	}
+
+	// 先订阅再查询补发的日志，避免遗漏
+	sub := logBroker.Subscribe(filter)
+	defer logBroker.Unsubscribe(sub)
+
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Header("X-Accel-Buffering", "no")
+	c.Status(200)
+	fmt.Fprintf(c.Writer, "retry: %d\n\n", logStreamRetryDelay)
+
+	if lastID > 0 {
+		var backlog []OperationLog
+		filter.Apply(db.Model(&OperationLog{})).
+			Where("id > ? AND archived = ?", lastID, false).
+			Order("id").
+			Limit(logStreamBacklog).
+			Find(&backlog)
+		for i := range backlog {
+			if err := writeSSEEvent(c, &backlog[i]); err != nil {
+				return
+			}
+			lastID = uint64(backlog[i].ID)
+		}
+	}
+	c.Writer.Flush()
+
+	heartbeat := time.NewTicker(logStreamHeartbeat)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case entry := <-sub.ch:
+			if uint64(entry.ID) <= lastID {
+				continue
+			}
+			if err := writeSSEEvent(c, &entry); err != nil {
+				return
+			}
+			lastID = uint64(entry.ID)
+			c.Writer.Flush()
+		case <-heartbeat.C:
+			if _, err := fmt.Fprintf(c.Writer, ": ping %d\n\n", time.Now().Unix()); err != nil {
+				return
+			}
+			c.Writer.Flush()
+		case <-sub.lagged:
+			// 通知客户端重连，凭 Last-Event-ID 补发
+			fmt.Fprintf(c.Writer, "event: lagged\ndata: {\"last_event_id\":%d}\n\n", lastID)
+			c.Writer.Flush()
+			return
+		case <-logBroker.closed:
+			return
+		case <-c.Request.Context().Done():
+			return
+		}
+	}
+}
+
+// 检查用户是否有查看日志的权限（兼容旧的管理员角色）
+func logReaderMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c.GetString("role") == "admin" || hasUserPermission(c.GetUint("user_id"), "log.read") {
+			c.Next()
+			return
+		}
+		errorResponse(c, 403, "需要日志查看权限")
+		c.Abort()
+	}
+}
+
+// 获取当前的日志流订阅数
+func getLogStreamStatus(c *gin.Context) {
+	successResponse(c, gin.H{
+		"subscribers":       logBroker.Count(),
+		"heartbeat_seconds": int(logStreamHeartbeat.Seconds()),
+	})
+}
@@ -0,0 +1,348 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// 日志队列满时的处理策略
+const (
+	LogOverflowBlock      = "block"       // 阻塞等待队列有空位
+	LogOverflowDropOldest = "drop_oldest" // 丢弃队列中最早的日志
+	LogOverflowSpill      = "spill"       // 写入磁盘溢出文件，稍后补写
+)
+
+// 溢出文件路径（写入数据库失败的批次同样写入该文件）
+var logSpillFile = filepath.Join("logs", "operation_log_spill.jsonl")
+
+// 日志写入器配置
+type LogWriterOptions struct {
+	QueueSize      int
+	BatchSize      int
+	FlushInterval  time.Duration
+	OverflowPolicy string
+	SpillFile      string
+}
+
+// 日志写入器运行指标
+type LogWriterStats struct {
+	QueueDepth     int        `json:"queue_depth"`     // 当前队列长度
+	QueueCapacity  int        `json:"queue_capacity"`  // 队列容量
+	BatchSize      int        `json:"batch_size"`      // 批量写入大小
+	OverflowPolicy string     `json:"overflow_policy"` // 溢出策略
+	Enqueued       uint64     `json:"enqueued"`        // 已入队数
+	Written        uint64     `json:"written"`         // 已写入数据库数
+	Dropped        uint64     `json:"dropped"`         // 丢弃数
+	Spilled        uint64     `json:"spilled"`         // 写入溢出文件数
+	Replayed       uint64     `json:"replayed"`        // 从溢出文件补写数
+	Failed         uint64     `json:"failed"`          // 写入失败且无法保存的数量
+	Blocked        uint64     `json:"blocked"`         // 因队列满而阻塞的次数
+	Batches        uint64     `json:"batches"`         // 批量写入次数
+	LastError      string     `json:"last_error"`      // 最近一次错误
+	LastFlushAt    *time.Time `json:"last_flush_at"`   // 最近一次写入时间
+}
+
+// 操作日志异步写入器：有界队列 + 单个写入协程批量写入
+type LogWriter struct {
+	opts    LogWriterOptions
+	queue   chan *OperationLog
+	flushCh chan chan struct{}
+	done    chan struct{}
+
+	closeMu sync.RWMutex
+	closed  bool
+	spillMu sync.Mutex
+
+	enqueued, written, dropped, spilled, replayed, failed, blocked, batches atomic.Uint64
+
+	statsMu     sync.Mutex
+	lastError   string
+	lastFlushAt *time.Time
+}
+
+// 全局操作日志写入器，未启动时同步写入
+var operationLogWriter *LogWriter
+
+// 创建并启动日志写入器
+func NewLogWriter(opts LogWriterOptions) *LogWriter {
+	if opts.QueueSize <= 0 {
+		opts.QueueSize = 1000
+	}
+	if opts.BatchSize <= 0 {
+		opts.BatchSize = 100
+	}
+	if opts.FlushInterval <= 0 {
+		opts.FlushInterval = time.Second
+	}
+	switch opts.OverflowPolicy {
+	case LogOverflowBlock, LogOverflowDropOldest, LogOverflowSpill:
+	default:
+		opts.OverflowPolicy = LogOverflowBlock
+	}
+	if opts.SpillFile == "" {
+		opts.SpillFile = logSpillFile
+	}
+
+	w := &LogWriter{
+		opts:    opts,
+		queue:   make(chan *OperationLog, opts.QueueSize),
+		flushCh: make(chan chan struct{}),
+		done:    make(chan struct{}),
+	}
+	go w.run()
+	return w
+}
+
+// 根据系统配置启动全局日志写入器
+func startOperationLogWriter() {
+	operationLogWriter = NewLogWriter(LogWriterOptions{
+		QueueSize:      configCache.GetInt("log_queue_size", 1000),
+		BatchSize:      configCache.GetInt("log_batch_size", 100),
+		FlushInterval:  configCache.GetDuration("log_flush_interval_ms", time.Millisecond, time.Second),
+		OverflowPolicy: getConfigValue("log_overflow_policy", LogOverflowBlock),
+	})
+}
+
+// 写入一条日志（队列满时按溢出策略处理）
+func (w *LogWriter) Write(entry *OperationLog) {
+	w.closeMu.RLock()
+	defer w.closeMu.RUnlock()
+
+	// 已关闭时直接同步写入
+	if w.closed {
+		w.writeBatch([]*OperationLog{entry})
+		return
+	}
+
+	select {
+	case w.queue <- entry:
+		w.enqueued.Add(1)
+		return
+	default:
+	}
+
+	switch w.opts.OverflowPolicy {
+	case LogOverflowDropOldest:
+		for {
+			select {
+			case w.queue <- entry:
+				w.enqueued.Add(1)
+				return
+			default:
+			}
+			select {
+			case <-w.queue:
+				w.dropped.Add(1)
+			default:
+			}
+		}
+	case LogOverflowSpill:
+		w.spill([]*OperationLog{entry})
+	default:
+		w.blocked.Add(1)
+		w.queue <- entry
+		w.enqueued.Add(1)
+	}
+}
+
+// 关闭写入器，写完队列中剩余的日志（超时返回错误）
+func (w *LogWriter) Close(timeout time.Duration) error {
+	w.closeMu.Lock()
+	if w.closed {
+		w.closeMu.Unlock()
+		return nil
+	}
+	w.closed = true
+	close(w.queue)
+	w.closeMu.Unlock()
+
+	select {
+	case <-w.done:
+		return nil
+	case <-time.After(timeout):
+		return errors.New("flush operation logs timed out")
+	}
+}
+
+// 立即写入队列中已有的日志
+func (w *LogWriter) Flush() {
+	w.closeMu.RLock()
+	if w.closed {
+		w.closeMu.RUnlock()
+		<-w.done
+		return
+	}
+	ack := make(chan struct{})
+	w.flushCh <- ack
+	w.closeMu.RUnlock()
+	<-ack
+}
+
+// 获取运行指标
+func (w *LogWriter) Stats() LogWriterStats {
+	w.statsMu.Lock()
+	defer w.statsMu.Unlock()
+	return LogWriterStats{
+		QueueDepth:     len(w.queue),
+		QueueCapacity:  cap(w.queue),
+		BatchSize:      w.opts.BatchSize,
+		OverflowPolicy: w.opts.OverflowPolicy,
+		Enqueued:       w.enqueued.Load(),
+		Written:        w.written.Load(),
+		Dropped:        w.dropped.Load(),
+		Spilled:        w.spilled.Load(),
+		Replayed:       w.replayed.Load(),
+		Failed:         w.failed.Load(),
+		Blocked:        w.blocked.Load(),
+		Batches:        w.batches.Load(),
+		LastError:      w.lastError,
+		LastFlushAt:    w.lastFlushAt,
+	}
+}
+
+// 写入协程：攒够一批或到达刷新间隔时批量写入
+func (w *LogWriter) run() {
+	defer close(w.done)
+
+	ticker := time.NewTicker(w.opts.FlushInterval)
+	defer ticker.Stop()
+
+	batch := make([]*OperationLog, 0, w.opts.BatchSize)
+	flush := func() {
+		if len(batch) > 0 {
+			w.writeBatch(batch)
+			batch = make([]*OperationLog, 0, w.opts.BatchSize)
+		}
+	}
+
+	for {
+		select {
+		case entry, ok := <-w.queue:
+			if !ok {
+				flush()
+				w.replaySpill()
+				return
+			}
+			batch = append(batch, entry)
+			if len(batch) >= w.opts.BatchSize {
+				flush()
+			}
+		case ack := <-w.flushCh:
+			for len(w.queue) > 0 {
+				batch = append(batch, <-w.queue)
+				if len(batch) >= w.opts.BatchSize {
+					flush()
+				}
+			}
+			flush()
+			close(ack)
+		case <-ticker.C:
+			flush()
+			// 队列空闲时补写溢出文件
+			if len(w.queue) == 0 {
+				w.replaySpill()
+			}
+		}
+	}
+}
+
+// 批量写入数据库，失败时写入溢出文件
+func (w *LogWriter) writeBatch(batch []*OperationLog) {
+	err := appendOperationLogs(batch, w.opts.BatchSize)
+	now := time.Now()
+
+	w.statsMu.Lock()
+	w.lastFlushAt = &now
+	if err != nil {
+		w.lastError = err.Error()
+	}
+	w.statsMu.Unlock()
+
+	if err != nil {
+		appLogger.Error("failed to write operation logs", "count", len(batch), "error", err)
+		w.spill(batch)
+		return
+	}
+	w.batches.Add(1)
+	w.written.Add(uint64(len(batch)))
+}
+
+// 将日志追加到溢出文件
+func (w *LogWriter) spill(entries []*OperationLog) {
+	w.spillMu.Lock()
+	defer w.spillMu.Unlock()
+
+	err := os.MkdirAll(filepath.Dir(w.opts.SpillFile), 0755)
+	if err == nil {
+		var file *os.File
+		file, err = os.OpenFile(w.opts.SpillFile, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+		if err == nil {
+			encoder := json.NewEncoder(file)
+			for _, entry := range entries {
+				if err = encoder.Encode(entry); err != nil {
+					break
+				}
+			}
+			if closeErr := file.Close(); err == nil {
+				err = closeErr
+			}
+		}
+	}
+
+	if err != nil {
+		appLogger.Error("failed to spill operation logs", "count", len(entries), "error", err)
+		w.failed.Add(uint64(len(entries)))
+		w.statsMu.Lock()
+		w.lastError = err.Error()
+		w.statsMu.Unlock()
+		return
+	}
+	w.spilled.Add(uint64(len(entries)))
+}
+
+// 从溢出文件补写日志（写入成功后删除文件）
+func (w *LogWriter) replaySpill() {
+	w.spillMu.Lock()
+	defer w.spillMu.Unlock()
+
+	file, err := os.Open(w.opts.SpillFile)
+	if err != nil {
+		return
+	}
+
+	var entries []*OperationLog
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		var entry OperationLog
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			appLogger.Warn("skipping malformed spilled operation log", "error", err)
+			continue
+		}
+		entries = append(entries, &entry)
+	}
+	file.Close()
+	if err := scanner.Err(); err != nil {
+		appLogger.Error("failed to read spilled operation logs", "error", err)
+		return
+	}
+
+	if len(entries) > 0 {
+		if err := appendOperationLogs(entries, w.opts.BatchSize); err != nil {
+			// 保留文件，下次再试
+			w.statsMu.Lock()
+			w.lastError = err.Error()
+			w.statsMu.Unlock()
+			return
+		}
+		w.replayed.Add(uint64(len(entries)))
+		w.written.Add(uint64(len(entries)))
+	}
+	os.Remove(w.opts.SpillFile)
+}
@@ -0,0 +1,146 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// 持有哈希链锁使写入协程阻塞在第一批，队列随后被填满
+func newStalledLogWriter(t *testing.T, policy string) *LogWriter {
+	t.Helper()
+
+	setupTestDB(t, map[string]string{})
+	logChainMu.Lock()
+	return NewLogWriter(LogWriterOptions{
+		QueueSize:      1,
+		BatchSize:      1,
+		FlushInterval:  time.Hour,
+		OverflowPolicy: policy,
+		SpillFile:      filepath.Join(t.TempDir(), "spill.jsonl"),
+	})
+}
+
+func testLogEntry(action string) *OperationLog {
+	return &OperationLog{Action: action, Resource: "test", Method: "GET", Path: "/test", IP: "127.0.0.1", Status: 200, CreatedAt: time.Now()}
+}
+
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func countOperationLogs(t *testing.T) int64 {
+	t.Helper()
+	var count int64
+	if err := db.Model(&OperationLog{}).Count(&count).Error; err != nil {
+		t.Fatalf("count logs: %v", err)
+	}
+	return count
+}
+
+func TestLogWriterOverflowBlock(t *testing.T) {
+	w := newStalledLogWriter(t, LogOverflowBlock)
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 5; i++ {
+			w.Write(testLogEntry("block"))
+		}
+	}()
+	waitFor(t, "writer to block", func() bool { return w.Stats().Blocked > 0 })
+	select {
+	case <-done:
+		t.Fatal("Write returned while the queue was full")
+	default:
+	}
+
+	logChainMu.Unlock()
+	<-done
+	if err := w.Close(5 * time.Second); err != nil {
+		t.Fatalf("close: %v", err)
+	}
+
+	stats := w.Stats()
+	if stats.Written != 5 || stats.Dropped != 0 || stats.Spilled != 0 {
+		t.Fatalf("unexpected stats: %+v", stats)
+	}
+	if count := countOperationLogs(t); count != 5 {
+		t.Fatalf("logs in database = %d, want 5", count)
+	}
+	assertLogChainValid(t)
+}
+
+func TestLogWriterOverflowDropOldest(t *testing.T) {
+	w := newStalledLogWriter(t, LogOverflowDropOldest)
+
+	for i := 0; i < 9; i++ {
+		w.Write(testLogEntry("old"))
+	}
+	w.Write(testLogEntry("newest"))
+	stats := w.Stats()
+	if stats.Dropped < 8 || stats.Blocked != 0 {
+		t.Fatalf("unexpected stats while stalled: %+v", stats)
+	}
+
+	logChainMu.Unlock()
+	if err := w.Close(5 * time.Second); err != nil {
+		t.Fatalf("close: %v", err)
+	}
+
+	stats = w.Stats()
+	if stats.Written+stats.Dropped != 10 {
+		t.Fatalf("written %d + dropped %d, want 10", stats.Written, stats.Dropped)
+	}
+	if count := countOperationLogs(t); uint64(count) != stats.Written {
+		t.Fatalf("logs in database = %d, want %d", count, stats.Written)
+	}
+	// 丢弃的是最早的日志，最新的一条一定会写入
+	var last OperationLog
+	db.Order("id DESC").First(&last)
+	if last.Action != "newest" {
+		t.Fatalf("last written log = %q, want newest", last.Action)
+	}
+	assertLogChainValid(t)
+}
+
+func TestLogWriterOverflowSpill(t *testing.T) {
+	w := newStalledLogWriter(t, LogOverflowSpill)
+
+	for i := 0; i < 10; i++ {
+		w.Write(testLogEntry("spill"))
+	}
+	stats := w.Stats()
+	if stats.Spilled < 8 || stats.Dropped != 0 || stats.Blocked != 0 {
+		t.Fatalf("unexpected stats while stalled: %+v", stats)
+	}
+	if _, err := os.Stat(w.opts.SpillFile); err != nil {
+		t.Fatalf("spill file not written: %v", err)
+	}
+
+	// 关闭时写完队列并补写溢出文件
+	logChainMu.Unlock()
+	if err := w.Close(5 * time.Second); err != nil {
+		t.Fatalf("close: %v", err)
+	}
+
+	stats = w.Stats()
+	if stats.Written != 10 || stats.Replayed != stats.Spilled || stats.Failed != 0 {
+		t.Fatalf("unexpected stats after close: %+v", stats)
+	}
+	if count := countOperationLogs(t); count != 10 {
+		t.Fatalf("logs in database = %d, want 10", count)
+	}
+	if _, err := os.Stat(w.opts.SpillFile); !os.IsNotExist(err) {
+		t.Fatalf("spill file should be removed after replay, stat error: %v", err)
+	}
+	assertLogChainValid(t)
+}
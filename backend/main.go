@@ -1,10 +1,14 @@
 package main
 
 import (
-	"fmt"
-	"log"
+	"context"
+	"log/slog"
 	"net/http"
 	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"syscall"
 	"time"
 
 	"github.com/gin-gonic/gin"
@@ -20,39 +24,102 @@ var db *gorm.DB
 // 初始化数据库
 func initDatabase() {
 	var err error
-	db, err = gorm.Open(sqlite.Open("jing_admin.db"), &gorm.Config{})
+	db, err = gorm.Open(sqlite.Open("jing_admin.db"), &gorm.Config{Logger: newGormLogger()})
 	if err != nil {
-		log.Fatal("Failed to connect database:", err)
+		fatal("failed to connect database", err)
+	}
+
+	// 注册审计回调，自动记录字段变更
+	err = registerAuditCallbacks(db)
+	if err != nil {
+		fatal("failed to register audit callbacks", err)
 	}
 
 	// 自动迁移数据库
 	err = db.AutoMigrate(&User{})
 	if err != nil {
-		log.Fatal("Failed to migrate database:", err)
+		fatal("failed to migrate database", err)
 	}
 
 	// 初始化权限系统
 	err = initPermissionSystem()
 	if err != nil {
-		log.Fatal("Failed to initialize permission system:", err)
+		fatal("failed to initialize permission system", err)
+	}
+
+	// 初始化用户组系统
+	err = initUserGroupSystem()
+	if err != nil {
+		fatal("failed to initialize user group system", err)
+	}
+
+	// 初始化自定义资料字段
+	err = initCustomFieldSystem()
+	if err != nil {
+		fatal("failed to initialize custom field system", err)
+	}
+
+	// 初始化用户生命周期
+	err = initUserLifecycle()
+	if err != nil {
+		fatal("failed to initialize user lifecycle", err)
 	}
 
 	// 初始化日志系统
 	err = initLogSystem()
 	if err != nil {
-		log.Fatal("Failed to initialize log system:", err)
+		fatal("failed to initialize log system", err)
+	}
+
+	// 初始化认证事件日志
+	err = initAuthEventSystem()
+	if err != nil {
+		fatal("failed to initialize auth event system", err)
+	}
+
+	// 初始化安全告警
+	err = initAlertSystem()
+	if err != nil {
+		fatal("failed to initialize alert system", err)
+	}
+
+	// 初始化统计数据汇总
+	err = initStatsSystem()
+	if err != nil {
+		fatal("failed to initialize stats system", err)
+	}
+
+	// 初始化隐私数据系统
+	err = initPrivacySystem()
+	if err != nil {
+		fatal("failed to initialize privacy system", err)
 	}
 
 	// 初始化系统配置
 	err = initSystemConfig()
 	if err != nil {
-		log.Fatal("Failed to initialize system config:", err)
+		fatal("failed to initialize system config", err)
+	}
+
+	// 初始化配置修订记录
+	err = initConfigRevisionSystem()
+	if err != nil {
+		fatal("failed to initialize config revision system", err)
+	}
+
+	// 按系统配置设置应用日志（输出、格式、级别），配置修改后自动生效
+	if err := configureAppLogger(); err != nil {
+		appLogger.Error("failed to configure application logger, using stdout", "error", err)
 	}
+	watchAppLogConfig()
+
+	// 检查数据库中的配置与配置定义是否一致
+	checkConfigDrift()
 
 	// 初始化文件上传系统
 	err = initUploadSystem()
 	if err != nil {
-		log.Fatal("Failed to initialize upload system:", err)
+		fatal("failed to initialize upload system", err)
 	}
 
 	// 创建默认管理员账户
@@ -62,7 +129,7 @@ func initDatabase() {
 		// 加密密码
 		hashedPassword, err := hashPassword("admin123")
 		if err != nil {
-			log.Fatal("Failed to hash admin password:", err)
+			fatal("failed to hash admin password", err)
 		}
 		
 		// 创建默认管理员
@@ -72,20 +139,22 @@ func initDatabase() {
 			Password: hashedPassword,
 			Role:     "admin",
 			Status:   true,
+			State:    UserStateActive,
 		}
 		db.Create(&admin)
-		log.Println("Default admin user created: username=admin, password=admin123")
+		appLogger.Info("default admin user created", "username", "admin", "password", "admin123")
 	}
 
-	log.Println("Database initialized successfully")
+	appLogger.Info("database initialized")
 }
 
 // CORS中间件
 func corsMiddleware() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		c.Header("Access-Control-Allow-Origin", "*")
-		c.Header("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
-		c.Header("Access-Control-Allow-Headers", "Content-Type, Authorization")
+		c.Header("Access-Control-Allow-Methods", "GET, POST, PUT, PATCH, DELETE, OPTIONS")
+		c.Header("Access-Control-Allow-Headers", "Content-Type, Authorization, If-Match, X-Request-ID")
+		c.Header("Access-Control-Expose-Headers", "ETag, X-Request-ID")
 
 		if c.Request.Method == "OPTIONS" {
 			c.AbortWithStatus(204)
@@ -96,45 +165,62 @@ func corsMiddleware() gin.HandlerFunc {
 	}
 }
 
-// 日志中间件
+// 访问日志中间件：每个请求输出一条结构化日志，4xx 为 warn，5xx 为 error
 func loggerMiddleware() gin.HandlerFunc {
-	return gin.LoggerWithFormatter(func(param gin.LogFormatterParams) string {
-		return fmt.Sprintf("%s - [%s] \"%s %s %s %d %s \"%s\" %s\"\n",
-			param.ClientIP,
-			param.TimeStamp.Format("2006/01/02 - 15:04:05"),
-			param.Method,
-			param.Path,
-			param.Request.Proto,
-			param.StatusCode,
-			param.Latency,
-			param.Request.UserAgent(),
-			param.ErrorMessage,
-		)
-	})
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+
+		status := c.Writer.Status()
+		level := slog.LevelInfo
+		if status >= 500 {
+			level = slog.LevelError
+		} else if status >= 400 {
+			level = slog.LevelWarn
+		}
+
+		attrs := []any{
+			"method", c.Request.Method,
+			"path", c.Request.URL.Path,
+			"query", c.Request.URL.RawQuery,
+			"status", status,
+			"latency_ms", float64(time.Since(start).Microseconds()) / 1000,
+			"bytes", c.Writer.Size(),
+			"client_ip", c.ClientIP(),
+			"user_agent", c.Request.UserAgent(),
+		}
+		if errs := c.Errors.ByType(gin.ErrorTypePrivate).String(); errs != "" {
+			attrs = append(attrs, "errors", errs)
+		}
+		requestLogger(c).Log(c.Request.Context(), level, "http request", attrs...)
+	}
 }
 
 // API响应格式
 type ApiResponse struct {
-	Code    int         `json:"code"`
-	Message string      `json:"message"`
-	Data    interface{} `json:"data"`
+	Code      int         `json:"code"`
+	Message   string      `json:"message"`
+	Data      interface{} `json:"data"`
+	RequestID string      `json:"request_id,omitempty"` // 请求ID，与响应头 X-Request-ID 相同
 }
 
 // 成功响应
 func successResponse(c *gin.Context, data interface{}) {
 	c.JSON(http.StatusOK, ApiResponse{
-		Code:    200,
-		Message: "success",
-		Data:    data,
+		Code:      200,
+		Message:   "success",
+		Data:      data,
+		RequestID: requestIDFromContext(c),
 	})
 }
 
 // 错误响应
 func errorResponse(c *gin.Context, code int, message string) {
 	c.JSON(code, ApiResponse{
-		Code:    code,
-		Message: message,
-		Data:    nil,
+		Code:      code,
+		Message:   message,
+		Data:      nil,
+		RequestID: requestIDFromContext(c),
 	})
 }
 
@@ -142,13 +228,43 @@ func main() {
 	// 初始化数据库
 	initDatabase()
 
-	// 创建默认的gin路由器
-	r := gin.Default()
+	// 命令行验证操作日志哈希链：./backend verify-logs
+	if len(os.Args) > 1 && os.Args[1] == "verify-logs" {
+		os.Exit(runVerifyLogsCommand())
+	}
+
+	// 启动操作日志异步写入器
+	startOperationLogWriter()
+
+	// 启动认证事件异步写入器
+	startAuthEventWriter()
+
+	// 启动回收站定时清理
+	startRecycleBinCleaner(time.Hour)
+
+	// 启动用户状态定时任务（自动解锁、停用到期、账户过期）
+	startUserLifecycleJob(time.Minute)
+
+	// 启动认证事件定时清理
+	startAuthEventCleaner(time.Hour)
+
+	// 启动操作日志保留策略（过期日志归档后删除）
+	startLogRetentionJob(time.Hour)
+
+	// 启动安全异常检测
+	startAnomalyDetector(time.Minute)
+
+	// 启动统计数据增量汇总
+	startStatRollupJob(time.Minute)
+
+	// 创建gin路由器（访问日志和恢复使用自定义中间件）
+	r := gin.New()
 
 	// 添加中间件
+	r.Use(requestIDMiddleware()) // 请求ID（需在其他中间件之前）
 	r.Use(corsMiddleware())
 	r.Use(loggerMiddleware())
-	r.Use(gin.Recovery()) // 恢复中间件
+	r.Use(recoveryMiddleware()) // 恢复中间件
 
 	// 健康检查接口
 	r.GET("/health", func(c *gin.Context) {
@@ -182,6 +298,9 @@ func main() {
 		// 公开配置接口（无需认证）
 		api.GET("/config/public", getPublicSystemConfigs)
 
+		// 实时日志流（EventSource 无法设置请求头，支持使用一次性票据认证）
+		api.GET("/logs/stream", logStreamAuthMiddleware(), logReaderMiddleware(), streamOperationLogs)
+
 		// 需要认证的接口
 		protected := api.Group("/")
 		protected.Use(authMiddleware())
@@ -192,6 +311,10 @@ func main() {
 			protected.PUT("/me", updateProfile)
 			protected.POST("/change-password", changePassword)
 			protected.GET("/my-permissions", getUserPermissionsAPI)
+			protected.GET("/profile-fields", getProfileCustomFields)
+			protected.GET("/me/personal-data", exportMyPersonalData)
+			protected.GET("/me/auth-events", getMyAuthEvents)
+			protected.POST("/logs/stream/ticket", logReaderMiddleware(), createLogStreamTicket)
 
 			// 用户相关接口（需要管理员权限）
 			users := protected.Group("/users")
@@ -201,8 +324,21 @@ func main() {
 				users.GET("/:id", getUserById)
 				users.POST("", createUser)
 				users.PUT("/:id", updateUser)
+				users.PATCH("/:id", updateUser)
 				users.DELETE("/:id", deleteUser)
 				users.POST("/:id/roles", assignUserRoles)
+				users.POST("/bulk", bulkUserOperation)
+				users.GET("/:id/groups", getUserGroupsOfUser)
+				users.POST("/:id/state", changeUserStateAPI)
+				users.GET("/:id/state-history", getUserStateHistory)
+				users.GET("/:id/personal-data", exportPersonalData)
+				users.POST("/:id/erase", erasePersonalData)
+				users.GET("/erasures", getErasureRecords)
+
+				// 回收站
+				users.GET("/recycle-bin", getRecycleBin("user"))
+				users.POST("/recycle-bin/:id/restore", restoreFromRecycleBin("user"))
+				users.DELETE("/recycle-bin/:id", purgeFromRecycleBin("user"))
 			}
 
 			// 角色管理接口（需要管理员权限）
@@ -213,7 +349,13 @@ func main() {
 				roles.GET("/:id", getRoleById)
 				roles.POST("", createRole)
 				roles.PUT("/:id", updateRole)
+				roles.PATCH("/:id", updateRole)
 				roles.DELETE("/:id", deleteRole)
+
+				// 回收站
+				roles.GET("/recycle-bin", getRecycleBin("role"))
+				roles.POST("/recycle-bin/:id/restore", restoreFromRecycleBin("role"))
+				roles.DELETE("/recycle-bin/:id", purgeFromRecycleBin("role"))
 			}
 
 			// 权限管理接口（需要管理员权限）
@@ -222,6 +364,36 @@ func main() {
 			{
 				permissions.GET("", getPermissionList)
 				permissions.POST("/assign", assignRolePermissions)
+
+				// 回收站
+				permissions.GET("/recycle-bin", getRecycleBin("permission"))
+				permissions.POST("/recycle-bin/:id/restore", restoreFromRecycleBin("permission"))
+				permissions.DELETE("/recycle-bin/:id", purgeFromRecycleBin("permission"))
+			}
+
+			// 用户组管理接口（需要管理员权限）
+			groups := protected.Group("/groups")
+			groups.Use(adminMiddleware())
+			{
+				groups.GET("", getUserGroupList)
+				groups.GET("/:id", getUserGroupById)
+				groups.POST("", createUserGroup)
+				groups.PUT("/:id", updateUserGroup)
+				groups.DELETE("/:id", deleteUserGroup)
+				groups.GET("/:id/members", getUserGroupMembers)
+				groups.POST("/:id/members", addUserGroupMembers)
+				groups.DELETE("/:id/members/:user_id", removeUserGroupMember)
+				groups.POST("/:id/roles", assignUserGroupRoles)
+			}
+
+			// 自定义资料字段接口（需要管理员权限）
+			customFields := protected.Group("/custom-fields")
+			customFields.Use(adminMiddleware())
+			{
+				customFields.GET("", getCustomFieldList)
+				customFields.POST("", createCustomField)
+				customFields.PUT("/:id", updateCustomField)
+				customFields.DELETE("/:id", deleteCustomField)
 			}
 
 			// 操作日志接口（需要管理员权限）
@@ -234,12 +406,53 @@ func main() {
 				logs.POST("/batch-delete", batchDeleteOperationLogs)
 				logs.DELETE("/clear-old", clearOldOperationLogs)
 				logs.GET("/stats", getOperationLogStats)
+				logs.GET("/writer-stats", getLogWriterStats)
+				logs.GET("/verify", verifyOperationLogs)
+				logs.GET("/checkpoints", getLogCheckpoints)
+				logs.GET("/archives", getLogArchives)
+				logs.GET("/archives/download", downloadLogArchive)
+				logs.POST("/search/rebuild", rebuildOperationLogSearch)
+				logs.GET("/stream/status", getLogStreamStatus)
+				logs.GET("/routes", getRouteAudits)
+			}
+
+			// 认证事件接口（需要管理员权限）
+			authEvents := protected.Group("/auth-events")
+			authEvents.Use(adminMiddleware())
+			{
+				authEvents.GET("", getAuthEvents)
+				authEvents.GET("/stats", getAuthEventStats)
+				authEvents.DELETE("/clear-old", clearOldAuthEvents)
+			}
+
+			// 安全告警接口（需要管理员权限）
+			alerts := protected.Group("/alerts")
+			alerts.Use(adminMiddleware())
+			{
+				alerts.GET("", getAlerts)
+				alerts.GET("/stats", getAlertStats)
+				alerts.GET("/rules", getAnomalyRulesAPI)
+				alerts.POST("/scan", runAnomalyDetectionAPI)
+				alerts.GET("/:id", getAlertById)
+				alerts.POST("/:id/ack", handleAlert(AlertStatusAcknowledged))
+				alerts.POST("/:id/resolve", handleAlert(AlertStatusResolved))
+			}
+
+			// 统计趋势接口（需要管理员权限）
+			stats := protected.Group("/stats")
+			stats.Use(adminMiddleware())
+			{
+				stats.GET("/series", getStatSeries)
+				stats.GET("/rollups", getStatRollupStatus)
+				stats.POST("/rollups/rebuild", rebuildStatRollupsAPI)
 			}
 
 			// 系统信息接口
 			system := protected.Group("/system")
 			{
 				system.GET("/info", getSystemInfo)
+				system.GET("/log-level", adminMiddleware(), getAppLogLevel)
+				system.PUT("/log-level", adminMiddleware(), updateAppLogLevel)
 			}
 
 			// 系统配置接口（需要管理员权限）
@@ -250,9 +463,21 @@ func main() {
 				config.GET("/:key", getSystemConfigByKey)
 				config.PUT("/:key", updateSystemConfig)
 				config.POST("/batch", batchUpdateSystemConfigs)
+				config.POST("/reload", reloadSystemConfigs)
+				config.GET("/revisions", getConfigRevisions)
+				config.GET("/snapshot", getConfigSnapshot)
+				config.GET("/diff", getConfigDiff)
+				config.GET("/:key/history", getConfigHistory)
+				config.POST("/:key/rollback", rollbackConfigKey)
+				config.POST("/batches/:batch_id/rollback", rollbackConfigBatch)
 				config.POST("", createSystemConfig)
 				config.DELETE("/:key", deleteSystemConfig)
 				config.POST("/:key/reset", resetSystemConfigToDefault)
+				config.POST("/categories/:category/reset", resetConfigCategory)
+				config.POST("/reset", resetAllConfigs)
+				config.GET("/definitions", getConfigDefinitions)
+				config.POST("/definitions/sync", syncConfigDefinitions)
+				config.GET("/drift", getConfigDrift)
 			}
 
 			// 文件上传接口
@@ -271,11 +496,14 @@ func main() {
 			protected.GET("/export/users", adminMiddleware(), exportUsersCSV)
 			protected.GET("/export/roles", adminMiddleware(), exportRolesCSV)
 			protected.GET("/export/permissions", adminMiddleware(), exportPermissionsCSV)
+			protected.GET("/export/groups", adminMiddleware(), exportUserGroupsCSV)
+			protected.GET("/export/logs", adminMiddleware(), exportOperationLogs)
 
 			// 数据导入接口（仅管理员）
 			protected.POST("/import/users", adminMiddleware(), importUsersCSV)
 			protected.POST("/import/roles", adminMiddleware(), importRolesCSV)
 			protected.POST("/import/permissions", adminMiddleware(), importPermissionsCSV)
+			protected.POST("/import/groups", adminMiddleware(), importUserGroupsCSV)
 		}
 
 		// 系统监控API
@@ -285,31 +513,175 @@ func main() {
 		}
 	}
 
-	log.Println("启动服务器在端口 :8081")
-	log.Println("数据库文件: jing_admin.db")
-	log.Println("健康检查: http://localhost:8081/health")
-	log.Println("API测试: http://localhost:8081/api/test")
-	
-	r.Run(":8081")
+	// 检查路由是否都登记了审计信息
+	checkRouteAudits(r.Routes())
+
+	appLogger.Info("启动服务器",
+		"addr", ":8081",
+		"database", "jing_admin.db",
+		"health", "http://localhost:8081/health",
+		"api_test", "http://localhost:8081/api/test",
+	)
+
+	srv := &http.Server{
+		Addr:    ":8081",
+		Handler: r,
+	}
+	// 关闭时结束实时日志流，否则长连接会阻塞退出
+	srv.RegisterOnShutdown(logBroker.Close)
+	// 关闭时停止后台定时任务
+	srv.RegisterOnShutdown(jobRunner.Stop)
+	go func() {
+		if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			fatal("failed to start server", err)
+		}
+	}()
+
+	// 收到退出信号后停止接收请求，并写完队列中的操作日志
+	quit := make(chan os.Signal, 1)
+	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
+	<-quit
+	appLogger.Info("正在关闭服务器...")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := srv.Shutdown(ctx); err != nil {
+		appLogger.Error("server shutdown error", "error", err)
+	}
+	if err := jobRunner.Wait(ctx); err != nil {
+		appLogger.Error("background jobs did not stop in time", "error", err)
+	}
+	if err := operationLogWriter.Close(10 * time.Second); err != nil {
+		appLogger.Error("operation log flush error", "error", err)
+	}
+	if err := authEventWriter.Close(10 * time.Second); err != nil {
+		appLogger.Error("auth event flush error", "error", err)
+	}
+	appLogger.Info("服务器已关闭")
+	closeAppLogger()
 }
 
 // 用户管理API处理函数
 
+// 用户列表允许排序的字段
+var userSortFields = map[string]string{
+	"id":         "users.id",
+	"username":   "users.username",
+	"email":      "users.email",
+	"real_name":  "users.real_name",
+	"department": "users.department",
+	"status":     "users.status",
+	"last_login": "users.last_login",
+	"created_at": "users.created_at",
+	"updated_at": "users.updated_at",
+}
+
 // 获取用户列表
 func getUserList(c *gin.Context) {
+	// 分页参数（默认使用系统配置中的分页大小）
+	defaultPageSize := getConfigValue("pagination_size", "20")
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	pageSize, err := strconv.Atoi(c.DefaultQuery("page_size", defaultPageSize))
+	if err != nil || pageSize < 1 {
+		pageSize = 20
+	}
+	if page < 1 {
+		page = 1
+	}
+	if pageSize > 100 {
+		pageSize = 100
+	}
+
+	// 构建查询
+	query := buildUserListQuery(c.Query)
+
+	// 获取总数
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		errorResponse(c, 500, "获取用户列表失败")
+		return
+	}
+
+	// 排序参数（仅允许白名单字段）
+	sortField, ok := userSortFields[c.DefaultQuery("sort_by", "id")]
+	if !ok {
+		errorResponse(c, 400, "不支持的排序字段")
+		return
+	}
+	sortOrder := "ASC"
+	if strings.ToLower(c.Query("sort_order")) == "desc" {
+		sortOrder = "DESC"
+	}
+
+	// 获取分页数据
 	var users []User
-	result := db.Find(&users)
+	offset := (page - 1) * pageSize
+	result := query.Preload("Roles").
+		Order(sortField + " " + sortOrder).
+		Limit(pageSize).
+		Offset(offset).
+		Find(&users)
 	if result.Error != nil {
 		errorResponse(c, 500, "获取用户列表失败")
 		return
 	}
+	attachUserCustomFields(users)
 
 	successResponse(c, gin.H{
-		"users": users,
-		"total": len(users),
+		"users":     users,
+		"total":     total,
+		"page":      page,
+		"page_size": pageSize,
+		"pages":     (total + int64(pageSize) - 1) / int64(pageSize),
 	})
 }
 
+// 根据查询参数构建用户列表查询（关键字、状态、部门、角色、最后登录时间）
+func buildUserListQuery(param func(string) string) *gorm.DB {
+	query := db.Model(&User{})
+
+	if keyword := strings.TrimSpace(param("keyword")); keyword != "" {
+		like := "%" + keyword + "%"
+		query = query.Where("users.username LIKE ? OR users.email LIKE ? OR users.real_name LIKE ? OR users.phone LIKE ?", like, like, like, like)
+	}
+	if status := param("status"); status != "" {
+		query = query.Where("users.status = ?", status == "true" || status == "1")
+	}
+	if state := param("state"); state != "" {
+		query = query.Where("users.state = ?", state)
+	}
+	if department := param("department"); department != "" {
+		query = query.Where("users.department = ?", department)
+	}
+	if roleID := param("role_id"); roleID != "" {
+		query = query.Where("users.id IN (?)", db.Table("user_roles").Select("user_id").Where("role_id = ?", roleID))
+	}
+	if role := param("role"); role != "" {
+		roleUsers := db.Table("user_roles").
+			Select("user_roles.user_id").
+			Joins("JOIN roles ON roles.id = user_roles.role_id").
+			Where("roles.name = ?", role)
+		query = query.Where("users.role = ? OR users.id IN (?)", role, roleUsers)
+	}
+	if start := param("last_login_start"); start != "" {
+		query = query.Where("users.last_login >= ?", start)
+	}
+	if end := param("last_login_end"); end != "" {
+		query = query.Where("users.last_login <= ?", end)
+	}
+
+	// 自定义字段筛选：cf_<字段键名>=值
+	for _, field := range getCustomFields() {
+		if value := param("cf_" + field.Key); value != "" {
+			query = query.Where("users.id IN (?)", db.Table("user_custom_field_values").
+				Select("user_id").
+				Where("field_id = ? AND value = ?", field.ID, value))
+		}
+	}
+
+	return query
+}
+
 // 根据ID获取用户
 func getUserById(c *gin.Context) {
 	id := c.Param("id")
@@ -320,7 +692,10 @@ func getUserById(c *gin.Context) {
 		return
 	}
 
-	successResponse(c, user)
+	users := []User{user}
+	attachUserCustomFields(users)
+	setVersionETag(c, user.Version)
+	successResponse(c, users[0])
 }
 
 // 创建用户
@@ -338,6 +713,29 @@ func createUser(c *gin.Context) {
 		return
 	}
 
+	operatorID := c.GetUint("user_id")
+	operatorName := c.GetString("username")
+
+	// 初始状态，默认为正常
+	if newUser.State == "" {
+		newUser.State = UserStateActive
+	}
+	if !containsSlice(manualUserStates, newUser.State) {
+		errorResponse(c, 400, "不支持的用户状态: "+newUser.State)
+		return
+	}
+	newUser.Status = newUser.State == UserStateActive
+
+	// 校验自定义字段
+	customFields, err := checkUserCustomFields(newUser.CustomFields, true)
+	if err == nil {
+		err = checkRequiredCustomFields(newUser.CustomFields)
+	}
+	if err != nil {
+		errorResponse(c, 400, err.Error())
+		return
+	}
+
 	// 加密密码
 	if newUser.Password != "" {
 		hashedPassword, err := hashPassword(newUser.Password)
@@ -348,8 +746,22 @@ func createUser(c *gin.Context) {
 		newUser.Password = hashedPassword
 	}
 
-	result := db.Create(&newUser)
-	if result.Error != nil {
+	err = auditDB(c).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&newUser).Error; err != nil {
+			return err
+		}
+		// status 字段有默认值，false 不会在创建时写入
+		if !newUser.Status {
+			if err := tx.Model(&newUser).Update("status", false).Error; err != nil {
+				return err
+			}
+		}
+		if err := recordUserStateHistory(tx, newUser.ID, "", newUser.State, "管理员创建用户", nil, operatorID, operatorName); err != nil {
+			return err
+		}
+		return saveUserCustomFields(tx, newUser.ID, customFields, newUser.CustomFields)
+	})
+	if err != nil {
 		errorResponse(c, 500, "创建用户失败")
 		return
 	}
@@ -359,6 +771,24 @@ func createUser(c *gin.Context) {
 	successResponse(c, newUser)
 }
 
+// 更新用户请求（仅更新请求中出现的字段）
+type UserUpdateRequest struct {
+	Email      *string `json:"email" binding:"omitempty,email,max=100"`
+	Role       *string `json:"role" binding:"omitempty,min=1,max=50"`
+	Status     *bool   `json:"status"`
+	RealName   *string `json:"real_name" binding:"omitempty,max=50"`
+	Phone      *string `json:"phone" binding:"omitempty,max=20"`
+	Avatar     *string `json:"avatar" binding:"omitempty,max=255"`
+	Department *string `json:"department" binding:"omitempty,max=100"`
+	Position   *string `json:"position" binding:"omitempty,max=100"`
+	Bio        *string `json:"bio" binding:"omitempty,max=500"`
+	Password   *string `json:"password" binding:"omitempty,min=6"`
+	ExpiresAt  *string `json:"expires_at"` // 账户到期时间（YYYY-MM-DD 或 RFC3339），空字符串表示永不过期
+	Version    *uint   `json:"version"`    // 客户端持有的版本号，也可通过If-Match请求头传递
+
+	CustomFields map[string]string `json:"custom_fields"` // 自定义资料字段
+}
+
 // 更新用户
 func updateUser(c *gin.Context) {
 	id := c.Param("id")
@@ -371,58 +801,142 @@ func updateUser(c *gin.Context) {
 		return
 	}
 
-	// 保存原密码
-	oldPassword := user.Password
-
 	// 绑定更新数据
-	var updateData User
-	if err := c.ShouldBindJSON(&updateData); err != nil {
-		errorResponse(c, 400, "请求参数错误")
+	var req UserUpdateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		errorResponse(c, 400, validationErrorMessage(err))
 		return
 	}
 
-	// 更新基本字段
-	user.Email = updateData.Email
-	user.Role = updateData.Role
-	user.Status = updateData.Status
-	
-	// 更新个人资料字段
-	user.RealName = updateData.RealName
-	user.Phone = updateData.Phone
-	user.Avatar = updateData.Avatar
-	user.Department = updateData.Department
-	user.Position = updateData.Position
-	user.Bio = updateData.Bio
-
-	// 处理密码更新
-	if updateData.Password != "" {
-		hashedPassword, err := hashPassword(updateData.Password)
+	// 乐观锁：检查客户端持有的版本
+	version, err := expectedVersion(c, req.Version)
+	if err != nil {
+		errorResponse(c, 400, err.Error())
+		return
+	}
+	if version != 0 && version != user.Version {
+		errorResponse(c, 409, "用户已被他人修改，请刷新后重试")
+		return
+	}
+
+	// 只收集请求中出现的字段
+	updates := make(map[string]interface{})
+	if req.Email != nil && *req.Email != user.Email {
+		var count int64
+		db.Model(&User{}).Where("email = ? AND id <> ?", *req.Email, user.ID).Count(&count)
+		if count > 0 {
+			errorResponse(c, 400, "邮箱已被其他用户使用")
+			return
+		}
+		updates["email"] = *req.Email
+	}
+	if req.Role != nil {
+		updates["role"] = *req.Role
+	}
+	// 启用/禁用映射为生命周期状态变更
+	stateChange := ""
+	if req.Status != nil && *req.Status != user.Status {
+		stateChange = UserStateSuspended
+		if *req.Status {
+			stateChange = UserStateActive
+		}
+		for key, value := range userStateUpdates(stateChange, "管理员禁用", nil) {
+			updates[key] = value
+		}
+	}
+	if req.ExpiresAt != nil {
+		if *req.ExpiresAt == "" {
+			updates["expires_at"] = nil
+		} else {
+			expiresAt, err := parseDateTime(*req.ExpiresAt)
+			if err != nil {
+				errorResponse(c, 400, "账户到期时间格式错误")
+				return
+			}
+			updates["expires_at"] = expiresAt
+		}
+	}
+	if req.RealName != nil {
+		updates["real_name"] = *req.RealName
+	}
+	if req.Phone != nil {
+		updates["phone"] = *req.Phone
+	}
+	if req.Avatar != nil {
+		updates["avatar"] = *req.Avatar
+	}
+	if req.Department != nil {
+		updates["department"] = *req.Department
+	}
+	if req.Position != nil {
+		updates["position"] = *req.Position
+	}
+	if req.Bio != nil {
+		updates["bio"] = *req.Bio
+	}
+
+	// 处理密码更新（为空时保持原密码）
+	if req.Password != nil && *req.Password != "" {
+		hashedPassword, err := hashPassword(*req.Password)
 		if err != nil {
 			errorResponse(c, 500, "密码加密失败")
 			return
 		}
-		user.Password = hashedPassword
-	} else {
-		// 如果没有提供新密码，保持原密码
-		user.Password = oldPassword
+		updates["password"] = hashedPassword
 	}
 
-	// 保存更新
-	result = db.Save(&user)
-	if result.Error != nil {
-		errorResponse(c, 500, "更新用户失败")
+	// 校验自定义字段
+	customFields, err := checkUserCustomFields(req.CustomFields, true)
+	if err != nil {
+		errorResponse(c, 400, err.Error())
 		return
 	}
+
+	if len(updates) > 0 || len(customFields) > 0 {
+		// 仅当版本未变化时更新，并递增版本号
+		updates["version"] = gorm.Expr("version + 1")
+		err = auditDB(c).Transaction(func(tx *gorm.DB) error {
+			result := tx.Model(&User{}).Where("id = ? AND version = ?", user.ID, user.Version).Updates(updates)
+			if result.Error != nil {
+				return result.Error
+			}
+			if result.RowsAffected == 0 {
+				return errVersionConflict
+			}
+			if stateChange != "" {
+				operatorID, _ := c.Get("user_id")
+				operatorName, _ := c.Get("username")
+				if err := recordUserStateHistory(tx, user.ID, user.State, stateChange, "管理员修改启用状态", nil, operatorID.(uint), operatorName.(string)); err != nil {
+					return err
+				}
+			}
+			return saveUserCustomFields(tx, user.ID, customFields, req.CustomFields)
+		})
+		if err == errVersionConflict {
+			errorResponse(c, 409, "用户已被他人修改，请刷新后重试")
+			return
+		}
+		// 状态和密码修改需要立即生效
+		authUsers.invalidate(user.ID)
+		if err != nil {
+			errorResponse(c, 500, "更新用户失败")
+			return
+		}
+		db.First(&user, user.ID)
+	}
 	
 	// 不返回密码
 	user.Password = ""
-	successResponse(c, user)
+	users := []User{user}
+	attachUserCustomFields(users)
+	setVersionETag(c, user.Version)
+	successResponse(c, users[0])
 }
 
 // 删除用户
 func deleteUser(c *gin.Context) {
 	id := c.Param("id")
-	result := db.Delete(&User{}, id)
+	result := auditDB(c).Delete(&User{}, id)
 	if result.Error != nil {
 		errorResponse(c, 500, "删除用户失败")
 		return
@@ -432,6 +946,9 @@ func deleteUser(c *gin.Context) {
 		errorResponse(c, 404, "用户不存在")
 		return
 	}
+	if userID, err := strconv.ParseUint(id, 10, 64); err == nil {
+		authUsers.invalidate(uint(userID))
+	}
 
 	successResponse(c, gin.H{"message": "用户删除成功"})
 }
@@ -469,6 +986,8 @@ func updateProfile(c *gin.Context) {
 		Department string `json:"department"`
 		Position   string `json:"position"`
 		Bio        string `json:"bio"`
+
+		CustomFields map[string]string `json:"custom_fields"` // 仅允许修改本人可编辑的自定义字段
 	}
 	
 	if err := c.ShouldBindJSON(&updateData); err != nil {
@@ -476,25 +995,44 @@ func updateProfile(c *gin.Context) {
 		return
 	}
 
-	// 更新个人资料字段（不允许修改用户名、角色等敏感信息）
-	user.Email = updateData.Email
-	user.RealName = updateData.RealName
-	user.Phone = updateData.Phone
-	user.Avatar = updateData.Avatar
-	user.Department = updateData.Department
-	user.Position = updateData.Position
-	user.Bio = updateData.Bio
+	// 校验自定义字段
+	customFields, err := checkUserCustomFields(updateData.CustomFields, false)
+	if err != nil {
+		errorResponse(c, 400, err.Error())
+		return
+	}
+
+	// 更新个人资料字段（不允许修改用户名、角色等敏感信息），只写入这些字段并递增版本号，
+	// 不会用读取时的旧值覆盖管理员同时修改的状态等字段
+	updates := map[string]interface{}{
+		"email":      updateData.Email,
+		"real_name":  updateData.RealName,
+		"phone":      updateData.Phone,
+		"avatar":     updateData.Avatar,
+		"department": updateData.Department,
+		"position":   updateData.Position,
+		"bio":        updateData.Bio,
+		"version":    gorm.Expr("version + 1"),
+	}
 
 	// 保存更新
-	result = db.Save(&user)
-	if result.Error != nil {
+	err = auditDB(c).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&User{}).Where("id = ?", user.ID).Updates(updates).Error; err != nil {
+			return err
+		}
+		return saveUserCustomFields(tx, user.ID, customFields, updateData.CustomFields)
+	})
+	if err != nil {
 		errorResponse(c, 500, "更新个人资料失败")
 		return
 	}
+	db.First(&user, user.ID)
 	
 	// 不返回密码
 	user.Password = ""
-	successResponse(c, user)
+	users := []User{user}
+	attachUserCustomFields(users, FieldVisibilitySelfEditable, FieldVisibilityAdminOnly)
+	successResponse(c, users[0])
 }
 
 // 系统监控API
@@ -509,9 +1047,9 @@ func getSystemMonitor(c *gin.Context) {
 	// 今日登录用户
 	db.Raw("SELECT COUNT(*) FROM users WHERE last_login >= date('now', 'start of day')").Scan(&todayLoginCount)
 	// 操作日志总数
-	db.Model(&OperationLog{}).Count(&logCount)
+	db.Model(&OperationLog{}).Where("archived = ?", false).Count(&logCount)
 	// 今日操作日志
-	db.Raw("SELECT COUNT(*) FROM operation_logs WHERE created_at >= date('now', 'start of day')").Scan(&todayLogCount)
+	db.Raw("SELECT COUNT(*) FROM operation_logs WHERE archived = 0 AND created_at >= date('now', 'start of day')").Scan(&todayLogCount)
 	// 数据库文件大小
 	if info, err := os.Stat("jing_admin.db"); err == nil {
 		dbSize = info.Size()
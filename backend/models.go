@@ -1,6 +1,7 @@
 package main
 
 import (
+	"fmt"
 	"gorm.io/gorm"
 	"time"
 )
@@ -8,10 +9,11 @@ import (
 // 角色模型
 type Role struct {
 	ID          uint         `json:"id" gorm:"primaryKey"`
-	Name        string       `json:"name" gorm:"unique;not null"`
+	Name        string       `json:"name" gorm:"not null;uniqueIndex:idx_roles_name_active,where:deleted_at IS NULL"`
 	DisplayName string       `json:"display_name" gorm:"not null"`
 	Description string       `json:"description"`
 	Status      bool         `json:"status" gorm:"default:true"`
+	Version     uint         `json:"version" gorm:"not null;default:1"` // 乐观锁版本号
 	Permissions []Permission `json:"permissions" gorm:"many2many:role_permissions;"`
 	gorm.Model
 }
@@ -19,7 +21,7 @@ type Role struct {
 // 权限模型
 type Permission struct {
 	ID          uint   `json:"id" gorm:"primaryKey"`
-	Name        string `json:"name" gorm:"unique;not null"`
+	Name        string `json:"name" gorm:"not null;uniqueIndex:idx_permissions_name_active,where:deleted_at IS NULL"`
 	DisplayName string `json:"display_name" gorm:"not null"`
 	Resource    string `json:"resource" gorm:"not null"` // 资源名称，如 user, role, system
 	Action      string `json:"action" gorm:"not null"`   // 操作名称，如 read, write, delete
@@ -41,8 +43,8 @@ type UserRole struct {
 // 更新用户模型，支持多角色
 type User struct {
 	ID         uint       `json:"id" gorm:"primaryKey"`
-	Username   string     `json:"username" gorm:"unique;not null"`
-	Email      string     `json:"email" gorm:"unique;not null"`
+	Username   string     `json:"username" gorm:"not null;uniqueIndex:idx_users_username_active,where:deleted_at IS NULL"` // 唯一性忽略已软删除的记录
+	Email      string     `json:"email" gorm:"not null;uniqueIndex:idx_users_email_active,where:deleted_at IS NULL"`
 	Password   string     `json:"-" gorm:"not null"` // 密码不返回到前端
 	Role       string     `json:"role" gorm:"default:user"` // 保留兼容性
 	Status     bool       `json:"status" gorm:"default:true"`
@@ -55,6 +57,18 @@ type User struct {
 	Position   string     `json:"position"`    // 职位
 	Bio        string     `json:"bio"`         // 个人简介
 	LastLogin  *time.Time `json:"last_login"`  // 最后登录时间
+	MustChangePassword bool `json:"must_change_password" gorm:"default:false"` // 下次登录需修改密码
+
+	// 生命周期状态（status 仅在 active 时为 true，保留兼容性）
+	State            string     `json:"state" gorm:"not null;default:active;index"` // pending, active, locked, suspended, expired, archived
+	FailedLoginCount int        `json:"failed_login_count" gorm:"default:0"`        // 连续登录失败次数
+	LockedUntil      *time.Time `json:"locked_until"`                               // 自动锁定截止时间
+	SuspendedReason  string     `json:"suspended_reason"`                           // 停用原因
+	SuspendedUntil   *time.Time `json:"suspended_until"`                            // 停用截止时间，为空表示无限期
+	ExpiresAt        *time.Time `json:"expires_at"`                                 // 账户到期时间
+	Version    uint       `json:"version" gorm:"not null;default:1"` // 乐观锁版本号
+
+	CustomFields map[string]string `json:"custom_fields,omitempty" gorm:"-"` // 自定义资料字段（键名 -> 值）
 	
 	Roles      []Role     `json:"roles" gorm:"many2many:user_roles;"`
 	gorm.Model
@@ -80,12 +94,16 @@ func initPermissionSystem() error {
 		{Name: "permission.write", DisplayName: "配置权限", Resource: "permission", Action: "write", Description: "配置角色权限"},
 		{Name: "system.read", DisplayName: "查看系统", Resource: "system", Action: "read", Description: "查看系统信息"},
 		{Name: "system.write", DisplayName: "管理系统", Resource: "system", Action: "write", Description: "系统配置管理"},
+		{Name: "log.read", DisplayName: "查看日志", Resource: "log", Action: "read", Description: "查看操作日志和实时日志流"},
 	}
 
+	// 记录本次新增的权限，已存在的默认角色也需要补充
+	createdPermissions := make(map[string]bool)
 	for _, perm := range defaultPermissions {
 		var existingPerm Permission
 		if err := db.Where("name = ?", perm.Name).First(&existingPerm).Error; err != nil {
 			db.Create(&perm)
+			createdPermissions[perm.Name] = true
 		}
 	}
 
@@ -101,7 +119,7 @@ func initPermissionSystem() error {
 				Description: "拥有系统所有权限",
 				Status:      true,
 			},
-			Permissions: []string{"user.read", "user.write", "user.delete", "role.read", "role.write", "role.delete", "permission.read", "permission.write", "system.read", "system.write"},
+			Permissions: []string{"user.read", "user.write", "user.delete", "role.read", "role.write", "role.delete", "permission.read", "permission.write", "system.read", "system.write", "log.read"},
 		},
 		{
 			Role: Role{
@@ -133,36 +151,56 @@ func initPermissionSystem() error {
 			var permissions []Permission
 			db.Where("name IN ?", roleData.Permissions).Find(&permissions)
 			db.Model(&roleData.Role).Association("Permissions").Append(&permissions)
+		} else {
+			// 为已有角色补充新增的默认权限
+			var added []string
+			for _, name := range roleData.Permissions {
+				if createdPermissions[name] {
+					added = append(added, name)
+				}
+			}
+			if len(added) > 0 {
+				var permissions []Permission
+				db.Where("name IN ?", added).Find(&permissions)
+				db.Model(&existingRole).Association("Permissions").Append(&permissions)
+			}
 		}
 	}
 
 	return nil
 }
 
-// 检查用户权限
+// 检查用户权限（包含通过用户组继承的角色，回收站中的角色和权限不生效）
 func hasUserPermission(userID uint, permissionName string) bool {
+	roleIDs := getUserRoleIDs(userID)
+	if len(roleIDs) == 0 {
+		return false
+	}
+
 	var count int64
-	db.Table("users").
+	db.Table("permissions").
 		Select("1").
-		Joins("JOIN user_roles ON users.id = user_roles.user_id").
-		Joins("JOIN roles ON user_roles.role_id = roles.id").
-		Joins("JOIN role_permissions ON roles.id = role_permissions.role_id").
-		Joins("JOIN permissions ON role_permissions.permission_id = permissions.id").
-		Where("users.id = ? AND permissions.name = ? AND roles.status = true", userID, permissionName).
+		Joins("JOIN role_permissions ON permissions.id = role_permissions.permission_id").
+		Joins("JOIN roles ON role_permissions.role_id = roles.id").
+		Where("roles.id IN ? AND permissions.name = ? AND roles.status = true AND roles.deleted_at IS NULL AND permissions.deleted_at IS NULL", roleIDs, permissionName).
 		Count(&count)
 	
 	return count > 0
 }
 
-// 获取用户所有权限
+// 获取用户所有权限（包含通过用户组继承的角色）
 func getUserPermissions(userID uint) []Permission {
 	var permissions []Permission
+	roleIDs := getUserRoleIDs(userID)
+	if len(roleIDs) == 0 {
+		return permissions
+	}
+
 	db.Table("permissions").
 		Select("permissions.*").
 		Joins("JOIN role_permissions ON permissions.id = role_permissions.permission_id").
 		Joins("JOIN roles ON role_permissions.role_id = roles.id").
-		Joins("JOIN user_roles ON roles.id = user_roles.role_id").
-		Where("user_roles.user_id = ? AND roles.status = true", userID).
+		Where("roles.id IN ? AND roles.status = true AND roles.deleted_at IS NULL AND permissions.deleted_at IS NULL", roleIDs).
 		Group("permissions.id").
 		Find(&permissions)
 	
@@ -180,6 +218,7 @@ type SystemConfig struct {
 	Description string `json:"description"`                    // 配置描述
 	IsPublic    bool   `json:"is_public" gorm:"default:false"` // 是否为公开配置（前端可访问）
 	IsEditable  bool   `json:"is_editable" gorm:"default:true"` // 是否可编辑
+	Constraints *ConfigConstraints `json:"constraints,omitempty" gorm:"serializer:json"` // 值约束（仅自定义配置保存，有定义的配置以定义为准）
 	gorm.Model
 }
 
@@ -191,41 +230,42 @@ func initSystemConfig() error {
 		return err
 	}
 
-	// 创建默认系统配置
-	defaultConfigs := []SystemConfig{
-		// 基础配置
-		{Key: "site_name", Value: "Jing Admin", Type: "string", Category: "basic", DisplayName: "网站名称", Description: "系统显示的名称", IsPublic: true, IsEditable: true},
-		{Key: "site_description", Value: "现代化管理后台系统", Type: "string", Category: "basic", DisplayName: "网站描述", Description: "系统描述信息", IsPublic: true, IsEditable: true},
-		{Key: "site_keywords", Value: "管理系统,后台,Vue3,Go", Type: "string", Category: "basic", DisplayName: "网站关键词", Description: "SEO关键词", IsPublic: true, IsEditable: true},
-		{Key: "site_logo", Value: "/logo.png", Type: "string", Category: "basic", DisplayName: "网站Logo", Description: "网站Logo图片地址", IsPublic: true, IsEditable: true},
-		{Key: "copyright", Value: "© 2024 Jing Admin. All Rights Reserved.", Type: "string", Category: "basic", DisplayName: "版权信息", Description: "网站版权信息", IsPublic: true, IsEditable: true},
-		
-		// 邮件配置
-		{Key: "mail_host", Value: "", Type: "string", Category: "mail", DisplayName: "邮件服务器", Description: "SMTP服务器地址", IsPublic: false, IsEditable: true},
-		{Key: "mail_port", Value: "587", Type: "number", Category: "mail", DisplayName: "邮件端口", Description: "SMTP服务器端口", IsPublic: false, IsEditable: true},
-		{Key: "mail_username", Value: "", Type: "string", Category: "mail", DisplayName: "邮箱用户名", Description: "发送邮件的用户名", IsPublic: false, IsEditable: true},
-		{Key: "mail_password", Value: "", Type: "string", Category: "mail", DisplayName: "邮箱密码", Description: "发送邮件的密码", IsPublic: false, IsEditable: true},
-		{Key: "mail_from", Value: "", Type: "string", Category: "mail", DisplayName: "发件人", Description: "邮件发送者地址", IsPublic: false, IsEditable: true},
-		
-		// 安全配置
-		{Key: "session_timeout", Value: "3600", Type: "number", Category: "security", DisplayName: "会话超时", Description: "用户会话超时时间（秒）", IsPublic: false, IsEditable: true},
-		{Key: "password_min_length", Value: "6", Type: "number", Category: "security", DisplayName: "密码最小长度", Description: "用户密码最小长度要求", IsPublic: true, IsEditable: true},
-		{Key: "enable_captcha", Value: "false", Type: "boolean", Category: "security", DisplayName: "启用验证码", Description: "登录时是否启用验证码", IsPublic: true, IsEditable: true},
-		{Key: "max_login_attempts", Value: "5", Type: "number", Category: "security", DisplayName: "最大登录尝试", Description: "账户锁定前的最大登录尝试次数", IsPublic: false, IsEditable: true},
-		
-		// 系统配置
-		{Key: "system_version", Value: "1.0.0", Type: "string", Category: "system", DisplayName: "系统版本", Description: "当前系统版本号", IsPublic: true, IsEditable: false},
-		{Key: "upload_max_size", Value: "10485760", Type: "number", Category: "system", DisplayName: "上传文件大小限制", Description: "文件上传大小限制（字节）", IsPublic: false, IsEditable: true},
-		{Key: "upload_allowed_types", Value: "jpg,jpeg,png,gif,pdf,doc,docx,xls,xlsx", Type: "string", Category: "system", DisplayName: "允许上传类型", Description: "允许上传的文件类型", IsPublic: false, IsEditable: true},
-		{Key: "pagination_size", Value: "20", Type: "number", Category: "system", DisplayName: "分页大小", Description: "默认分页大小", IsPublic: true, IsEditable: true},
-	}
-
-	for _, config := range defaultConfigs {
+	// 按配置定义创建缺少的配置
+	for _, definition := range configDefinitions {
+		if err := definition.Constraints.validate(definition.Type); err != nil {
+			return fmt.Errorf("invalid constraints for config %s: %w", definition.Key, err)
+		}
+		// 包含已删除的配置：管理员删除的配置不自动恢复（唯一索引仍被占用）
 		var existingConfig SystemConfig
-		if err := db.Where("key = ?", config.Key).First(&existingConfig).Error; err != nil {
-			db.Create(&config)
+		if err := db.Unscoped().Where("key = ?", definition.Key).First(&existingConfig).Error; err != nil {
+			config := SystemConfig{
+				Key:         definition.Key,
+				Value:       definition.Default,
+				Type:        definition.Type,
+				Category:    definition.Category,
+				DisplayName: definition.DisplayName,
+				Description: definition.Description,
+				IsPublic:    definition.IsPublic,
+				IsEditable:  definition.IsEditable,
+			}
+			// is_editable 字段有默认值，false 不会在创建时写入，且创建后 config.IsEditable 会被回填为 true，需按定义判断
+			if err := db.Create(&config).Error; err != nil {
+				return fmt.Errorf("create config %s: %w", definition.Key, err)
+			}
+			if !definition.IsEditable {
+				if err := db.Model(&config).Update("is_editable", false).Error; err != nil {
+					return err
+				}
+			}
+		} else if !definition.IsEditable && existingConfig.IsEditable {
+			// 定义改为不可编辑，或旧版本创建时未正确写入
+			if err := db.Model(&existingConfig).Update("is_editable", false).Error; err != nil {
+				return err
+			}
 		}
 	}
 
-	return nil
+	// 加载配置缓存
+	_, err = configCache.Reload()
+	return err
 } 
\ No newline at end of file
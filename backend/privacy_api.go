@@ -0,0 +1,426 @@
+package main
+
+import (
+	"archive/zip"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// 个人数据擦除记录（只允许新增，不允许修改和删除）
+type DataErasureRecord struct {
+	ID           uint      `json:"id" gorm:"primaryKey"`
+	UserID       uint      `json:"user_id" gorm:"not null;index"`
+	Pseudonym    string    `json:"pseudonym" gorm:"not null"`     // 擦除后使用的假名
+	Reason       string    `json:"reason"`                        // 擦除原因，如隐私请求编号
+	OperatorID   uint      `json:"operator_id" gorm:"not null"`   // 操作人ID
+	OperatorName string    `json:"operator_name" gorm:"not null"` // 操作人用户名
+	LogsUpdated  int64     `json:"logs_updated"`                  // 假名化的操作日志数
+	FilesRemoved int64     `json:"files_removed"`                 // 删除的文件数
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+// 擦除记录不可修改
+func (r *DataErasureRecord) BeforeUpdate(tx *gorm.DB) error {
+	return errors.New("擦除记录不允许修改")
+}
+
+// 擦除记录不可删除
+func (r *DataErasureRecord) BeforeDelete(tx *gorm.DB) error {
+	return errors.New("擦除记录不允许删除")
+}
+
+// 假名密钥（部署密钥，与JWT密钥分开，泄露JWT密钥不会使假名可被还原）
+var pseudonymKey []byte
+
+// 初始化隐私数据系统
+func initPrivacySystem() error {
+	var err error
+	if pseudonymKey, err = loadDeploymentSecret("JING_ADMIN_PSEUDONYM_KEY", "pseudonym.key"); err != nil {
+		return err
+	}
+
+	// 自动迁移数据库
+	if err := db.AutoMigrate(&DataErasureRecord{}); err != nil {
+		return err
+	}
+
+	// 数据库层面禁止修改和删除擦除记录
+	triggers := []string{
+		`CREATE TRIGGER IF NOT EXISTS data_erasure_records_no_update BEFORE UPDATE ON data_erasure_records
+		BEGIN SELECT RAISE(ABORT, 'data erasure records are immutable'); END`,
+		`CREATE TRIGGER IF NOT EXISTS data_erasure_records_no_delete BEFORE DELETE ON data_erasure_records
+		BEGIN SELECT RAISE(ABORT, 'data erasure records are immutable'); END`,
+	}
+	for _, trigger := range triggers {
+		if err := db.Exec(trigger).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// 生成稳定的假名（同一输入得到相同结果，便于关联分析但无法还原）
+func pseudonymize(prefix, value string) string {
+	if value == "" {
+		return ""
+	}
+	mac := hmac.New(sha256.New, pseudonymKey)
+	mac.Write([]byte(value))
+	return prefix + hex.EncodeToString(mac.Sum(nil))[:16]
+}
+
+// 将用户相关记录中的IP和用户代理替换为假名（model 需有 user_id、ip、user_agent 字段）
+func pseudonymizeUserClientInfo(tx *gorm.DB, model interface{}, userID uint) error {
+	for column, prefix := range map[string]string{"ip": "ip_", "user_agent": "ua_"} {
+		var values []string
+		if err := tx.Model(model).Where("user_id = ?", userID).Distinct().Pluck(column, &values).Error; err != nil {
+			return err
+		}
+		for _, value := range values {
+			err := tx.Model(model).
+				Where("user_id = ? AND "+column+" = ?", userID, value).
+				Update(column, pseudonymize(prefix, value)).Error
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// 用户表中的个人信息字段（擦除时清除，日志变更记录中替换为假名）
+var personalDataFields = map[string]bool{
+	"username":   true,
+	"email":      true,
+	"real_name":  true,
+	"phone":      true,
+	"avatar":     true,
+	"department": true,
+	"position":   true,
+	"bio":        true,
+}
+
+// 将日志详情中用户个人信息字段的变更值替换为假名，match 决定处理哪些用户
+// 详情不是变更记录或没有需要替换的值时原样返回
+func pseudonymizeLogDetails(details string, match func(recordID string) bool) string {
+	if !strings.Contains(details, `"changes"`) {
+		return details
+	}
+	decoder := json.NewDecoder(strings.NewReader(details))
+	decoder.UseNumber()
+	var payload map[string]interface{}
+	if err := decoder.Decode(&payload); err != nil {
+		return details
+	}
+	changes, _ := payload["changes"].([]interface{})
+
+	replaced := false
+	for _, item := range changes {
+		change, ok := item.(map[string]interface{})
+		if !ok || change["table"] != "users" || !personalDataFields[fmt.Sprint(change["field"])] || !match(fmt.Sprint(change["record_id"])) {
+			continue
+		}
+		for _, side := range []string{"old", "new"} {
+			value, ok := change[side].(string)
+			if !ok || value == "" || pseudonymPattern.MatchString(value) {
+				continue
+			}
+			change[side] = pseudonymize("pii_", value)
+			replaced = true
+		}
+	}
+	if !replaced {
+		return details
+	}
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return details
+	}
+	return string(data)
+}
+
+// 假名化操作日志变更记录中该用户的个人信息，返回修改的日志数
+func scrubPersonalDataInLogs(tx *gorm.DB, userID uint) (int64, error) {
+	recordID := strconv.FormatUint(uint64(userID), 10)
+	var logs []OperationLog
+	err := tx.Select("id", "details").
+		Where("details LIKE ? AND details LIKE ?", `%"table":"users"%`, `%"record_id":"`+recordID+`"%`).
+		Find(&logs).Error
+	if err != nil {
+		return 0, err
+	}
+
+	var updated int64
+	for _, entry := range logs {
+		scrubbed := pseudonymizeLogDetails(entry.Details, func(id string) bool { return id == recordID })
+		if scrubbed == entry.Details {
+			continue
+		}
+		if err := tx.Model(&OperationLog{}).Where("id = ?", entry.ID).Update("details", scrubbed).Error; err != nil {
+			return 0, err
+		}
+		updated++
+	}
+	return updated, nil
+}
+
+// 收集用户的个人数据
+func collectPersonalData(userID uint) (gin.H, error) {
+	var user User
+	if err := db.Preload("Roles").First(&user, userID).Error; err != nil {
+		return nil, err
+	}
+	user.Password = ""
+	users := []User{user}
+	attachUserCustomFields(users)
+
+	var groups []UserGroup
+	if groupIDs := getUserGroupIDs(userID); len(groupIDs) > 0 {
+		db.Where("id IN ?", groupIDs).Find(&groups)
+	}
+
+	var files []UploadedFile
+	db.Where("user_id = ?", userID).Order("created_at").Find(&files)
+
+	var logs []OperationLog
+	db.Where("user_id = ?", userID).Order("created_at").Find(&logs)
+
+	var history []UserStatusHistory
+	db.Where("user_id = ?", userID).Order("created_at").Find(&history)
+
+	var authEvents []AuthEvent
+	db.Where("user_id = ?", userID).Order("created_at").Find(&authEvents)
+
+	return gin.H{
+		"exported_at":    time.Now(),
+		"profile":        users[0],
+		"roles":          users[0].Roles,
+		"groups":         groups,
+		"files":          files,
+		"operation_logs": logs,
+		"status_history": history,
+		"auth_events":    authEvents,
+	}, nil
+}
+
+// 导出用户个人数据（format=json 或 zip）
+func exportPersonalData(c *gin.Context) {
+	userID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		errorResponse(c, 400, "用户ID格式错误")
+		return
+	}
+	writePersonalData(c, uint(userID))
+}
+
+// 导出当前用户的个人数据
+func exportMyPersonalData(c *gin.Context) {
+	writePersonalData(c, c.GetUint("user_id"))
+}
+
+// 输出个人数据文件
+func writePersonalData(c *gin.Context, userID uint) {
+	data, err := collectPersonalData(userID)
+	if err != nil {
+		errorResponse(c, 404, "用户不存在")
+		return
+	}
+
+	filename := fmt.Sprintf("personal_data_%d_%s", userID, time.Now().Format("20060102_150405"))
+
+	if c.DefaultQuery("format", "json") != "zip" {
+		c.Header("Content-Disposition", "attachment; filename="+filename+".json")
+		c.Header("Content-Type", "application/json; charset=utf-8")
+		encoder := json.NewEncoder(c.Writer)
+		encoder.SetIndent("", "  ")
+		encoder.Encode(data)
+		return
+	}
+
+	c.Header("Content-Disposition", "attachment; filename="+filename+".zip")
+	c.Header("Content-Type", "application/zip")
+
+	// 每个部分单独存为一个JSON文件
+	zw := zip.NewWriter(c.Writer)
+	defer zw.Close()
+	for _, section := range []string{"profile", "roles", "groups", "files", "operation_logs", "status_history", "auth_events"} {
+		w, err := zw.Create(section + ".json")
+		if err != nil {
+			return
+		}
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		encoder.Encode(data[section])
+	}
+}
+
+// 擦除用户个人数据
+func erasePersonalData(c *gin.Context) {
+	id := c.Param("id")
+	var user User
+	if err := db.Unscoped().First(&user, id).Error; err != nil {
+		errorResponse(c, 404, "用户不存在")
+		return
+	}
+
+	var req struct {
+		Confirm string `json:"confirm" binding:"required"` // 需填写用户名以确认操作
+		Reason  string `json:"reason" binding:"max=200"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		errorResponse(c, 400, validationErrorMessage(err))
+		return
+	}
+	if req.Confirm != user.Username {
+		errorResponse(c, 400, "确认信息与用户名不一致")
+		return
+	}
+
+	operatorID := c.GetUint("user_id")
+	operatorName := c.GetString("username")
+	if operatorID == user.ID {
+		errorResponse(c, 400, "不能擦除当前登录用户的数据")
+		return
+	}
+
+	pseudonym := fmt.Sprintf("erased_%d", user.ID)
+	record := DataErasureRecord{
+		UserID:       user.ID,
+		Pseudonym:    pseudonym,
+		Reason:       req.Reason,
+		OperatorID:   operatorID,
+		OperatorName: operatorName,
+		CreatedAt:    time.Now(),
+	}
+
+	// 删除头像和私有文件（公开文件保留，但去除上传者用户名）
+	var files []UploadedFile
+	db.Where("user_id = ? AND (category = ? OR is_public = ?)", user.ID, "avatar", false).Find(&files)
+
+	// 不使用 auditDB：字段变更记录会把擦除前的个人信息重新写入操作日志
+	err := db.Transaction(func(tx *gorm.DB) error {
+		// 匿名化用户资料，并使用随机密码使账户无法登录
+		randomPassword := make([]byte, 32)
+		rand.Read(randomPassword)
+		hashedPassword, err := hashPassword(hex.EncodeToString(randomPassword))
+		if err != nil {
+			return err
+		}
+		updates := userStateUpdates(UserStateArchived, "", nil)
+		for key, value := range map[string]interface{}{
+			"username":   pseudonym,
+			"email":      pseudonym + "@erased.invalid",
+			"password":   hashedPassword,
+			"real_name":  "",
+			"phone":      "",
+			"avatar":     "",
+			"department": "",
+			"position":   "",
+			"bio":        "",
+			"last_login": nil,
+			"expires_at": nil,
+			"version":    gorm.Expr("version + 1"),
+		} {
+			updates[key] = value
+		}
+		if err := tx.Unscoped().Model(&User{}).Where("id = ?", user.ID).Updates(updates).Error; err != nil {
+			return err
+		}
+		if err := recordUserStateHistory(tx, user.ID, user.State, UserStateArchived, "个人数据擦除", nil, operatorID, operatorName); err != nil {
+			return err
+		}
+
+		// 删除自定义字段值、角色和用户组关系
+		if err := tx.Where("user_id = ?", user.ID).Delete(&UserCustomFieldValue{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Exec("DELETE FROM user_roles WHERE user_id = ?", user.ID).Error; err != nil {
+			return err
+		}
+		if err := tx.Exec("DELETE FROM user_group_members WHERE user_id = ?", user.ID).Error; err != nil {
+			return err
+		}
+
+		// 假名化操作日志（IP和用户代理替换为稳定的假名，仍可用于关联分析）
+		if err := pseudonymizeUserClientInfo(tx, &OperationLog{}, user.ID); err != nil {
+			return err
+		}
+		result := tx.Model(&OperationLog{}).Where("user_id = ?", user.ID).Update("username", pseudonym)
+		if result.Error != nil {
+			return result.Error
+		}
+		record.LogsUpdated = result.RowsAffected
+
+		// 其他人的操作日志中记录的该用户资料变更同样假名化
+		scrubbed, err := scrubPersonalDataInLogs(tx, user.ID)
+		if err != nil {
+			return err
+		}
+		record.LogsUpdated += scrubbed
+
+		// 认证事件同样假名化
+		if err := pseudonymizeUserClientInfo(tx, &AuthEvent{}, user.ID); err != nil {
+			return err
+		}
+		if err := tx.Model(&AuthEvent{}).Where("user_id = ?", user.ID).Update("username", pseudonym).Error; err != nil {
+			return err
+		}
+
+		// 删除文件记录，保留的公开文件去除用户名
+		for _, file := range files {
+			if err := tx.Delete(&file).Error; err != nil {
+				return err
+			}
+		}
+		record.FilesRemoved = int64(len(files))
+		if err := tx.Model(&UploadedFile{}).Where("user_id = ?", user.ID).Update("username", pseudonym).Error; err != nil {
+			return err
+		}
+
+		return tx.Create(&record).Error
+	})
+	if err != nil {
+		errorResponse(c, 500, "擦除个人数据失败")
+		return
+	}
+
+	// 事务提交后再删除物理文件
+	for _, file := range files {
+		os.Remove(file.FilePath)
+	}
+
+	successResponse(c, gin.H{
+		"message": "个人数据已擦除",
+		"record":  record,
+	})
+
+	details, _ := json.Marshal(record)
+	logOperationFromContext(c, "erase", "user", strconv.FormatUint(uint64(user.ID), 10), string(details))
+}
+
+// 获取个人数据擦除记录
+func getErasureRecords(c *gin.Context) {
+	var records []DataErasureRecord
+	result := db.Order("created_at DESC").Find(&records)
+	if result.Error != nil {
+		errorResponse(c, 500, "获取擦除记录失败")
+		return
+	}
+
+	successResponse(c, gin.H{
+		"records": records,
+		"total":   len(records),
+	})
+}
@@ -0,0 +1,251 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// 回收站资源定义
+type recycleBinResource struct {
+	Name     string                              // 资源名称，用于提示信息
+	Model    func() interface{}                  // 返回模型指针
+	List     func() interface{}                  // 返回模型切片指针
+	Conflict func(tx *gorm.DB, id uint) string   // 恢复前检查唯一字段是否已被占用，返回冲突说明
+	Cleanup  func(tx *gorm.DB, ids []uint) error // 永久删除前清理关联数据
+}
+
+// 恢复时唯一字段已被占用
+var errRecycleBinConflict = errors.New("recycle bin conflict")
+
+// 回收站支持的资源
+var recycleBinResources = map[string]recycleBinResource{
+	"user": {
+		Name:  "用户",
+		Model: func() interface{} { return &User{} },
+		List:  func() interface{} { return &[]User{} },
+		Conflict: func(tx *gorm.DB, id uint) string {
+			var user User
+			if err := tx.Unscoped().First(&user, id).Error; err != nil {
+				return ""
+			}
+			var count int64
+			tx.Model(&User{}).Where("username = ? OR email = ?", user.Username, user.Email).Count(&count)
+			if count > 0 {
+				return "用户名或邮箱已被占用"
+			}
+			return ""
+		},
+		Cleanup: func(tx *gorm.DB, ids []uint) error {
+			if err := tx.Exec("DELETE FROM user_group_members WHERE user_id IN ?", ids).Error; err != nil {
+				return err
+			}
+			if err := tx.Exec("DELETE FROM user_custom_field_values WHERE user_id IN ?", ids).Error; err != nil {
+				return err
+			}
+			if err := tx.Exec("DELETE FROM user_status_histories WHERE user_id IN ?", ids).Error; err != nil {
+				return err
+			}
+			return tx.Exec("DELETE FROM user_roles WHERE user_id IN ?", ids).Error
+		},
+	},
+	"role": {
+		Name:  "角色",
+		Model: func() interface{} { return &Role{} },
+		List:  func() interface{} { return &[]Role{} },
+		Conflict: func(tx *gorm.DB, id uint) string {
+			var role Role
+			if err := tx.Unscoped().First(&role, id).Error; err != nil {
+				return ""
+			}
+			var count int64
+			tx.Model(&Role{}).Where("name = ?", role.Name).Count(&count)
+			if count > 0 {
+				return "角色名已被占用"
+			}
+			return ""
+		},
+		Cleanup: func(tx *gorm.DB, ids []uint) error {
+			if err := tx.Exec("DELETE FROM role_permissions WHERE role_id IN ?", ids).Error; err != nil {
+				return err
+			}
+			if err := tx.Exec("DELETE FROM user_group_roles WHERE role_id IN ?", ids).Error; err != nil {
+				return err
+			}
+			return tx.Exec("DELETE FROM user_roles WHERE role_id IN ?", ids).Error
+		},
+	},
+	"permission": {
+		Name:  "权限",
+		Model: func() interface{} { return &Permission{} },
+		List:  func() interface{} { return &[]Permission{} },
+		Conflict: func(tx *gorm.DB, id uint) string {
+			var perm Permission
+			if err := tx.Unscoped().First(&perm, id).Error; err != nil {
+				return ""
+			}
+			var count int64
+			tx.Model(&Permission{}).Where("name = ?", perm.Name).Count(&count)
+			if count > 0 {
+				return "权限名已被占用"
+			}
+			return ""
+		},
+		Cleanup: func(tx *gorm.DB, ids []uint) error {
+			return tx.Exec("DELETE FROM role_permissions WHERE permission_id IN ?", ids).Error
+		},
+	},
+}
+
+// 获取回收站列表
+func getRecycleBin(resource string) gin.HandlerFunc {
+	res := recycleBinResources[resource]
+	return func(c *gin.Context) {
+		page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+		pageSize, _ := strconv.Atoi(c.DefaultQuery("page_size", "20"))
+		if page < 1 {
+			page = 1
+		}
+		if pageSize < 1 || pageSize > 100 {
+			pageSize = 20
+		}
+
+		query := db.Unscoped().Model(res.Model()).Where("deleted_at IS NOT NULL")
+
+		var total int64
+		query.Count(&total)
+
+		items := res.List()
+		offset := (page - 1) * pageSize
+		result := query.Order("deleted_at DESC").Limit(pageSize).Offset(offset).Find(items)
+		if result.Error != nil {
+			errorResponse(c, 500, "获取回收站列表失败")
+			return
+		}
+
+		successResponse(c, gin.H{
+			"items":          items,
+			"total":          total,
+			"page":           page,
+			"page_size":      pageSize,
+			"pages":          (total + int64(pageSize) - 1) / int64(pageSize),
+			"retention_days": getRecycleBinRetentionDays(),
+		})
+	}
+}
+
+// 从回收站恢复
+func restoreFromRecycleBin(resource string) gin.HandlerFunc {
+	res := recycleBinResources[resource]
+	return func(c *gin.Context) {
+		id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+		if err != nil {
+			errorResponse(c, 400, "ID格式错误")
+			return
+		}
+
+		// 检查记录、检查唯一字段冲突和恢复在同一事务中完成，避免检查后被其他请求占用
+		var conflict string
+		err = auditDB(c).Transaction(func(tx *gorm.DB) error {
+			var count int64
+			if err := tx.Unscoped().Model(res.Model()).Where("id = ? AND deleted_at IS NOT NULL", id).Count(&count).Error; err != nil {
+				return err
+			}
+			if count == 0 {
+				return gorm.ErrRecordNotFound
+			}
+			if conflict = res.Conflict(tx, uint(id)); conflict != "" {
+				return errRecycleBinConflict
+			}
+			return tx.Unscoped().Model(res.Model()).Where("id = ?", id).Update("deleted_at", nil).Error
+		})
+		switch {
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			errorResponse(c, 404, "回收站中不存在该"+res.Name)
+			return
+		case errors.Is(err, errRecycleBinConflict):
+			errorResponse(c, 409, conflict+"，无法恢复")
+			return
+		case err != nil:
+			errorResponse(c, 500, "恢复"+res.Name+"失败")
+			return
+		}
+
+		successResponse(c, gin.H{"message": res.Name + "恢复成功"})
+	}
+}
+
+// 从回收站永久删除
+func purgeFromRecycleBin(resource string) gin.HandlerFunc {
+	res := recycleBinResources[resource]
+	return func(c *gin.Context) {
+		id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+		if err != nil {
+			errorResponse(c, 400, "ID格式错误")
+			return
+		}
+
+		var count int64
+		db.Unscoped().Model(res.Model()).Where("id = ? AND deleted_at IS NOT NULL", id).Count(&count)
+		if count == 0 {
+			errorResponse(c, 404, "回收站中不存在该"+res.Name)
+			return
+		}
+
+		if err := purgeRecycleBinItems(auditDB(c), res, []uint{uint(id)}); err != nil {
+			errorResponse(c, 500, "永久删除"+res.Name+"失败")
+			return
+		}
+
+		successResponse(c, gin.H{"message": res.Name + "已永久删除"})
+	}
+}
+
+// 永久删除记录及其关联数据
+func purgeRecycleBinItems(conn *gorm.DB, res recycleBinResource, ids []uint) error {
+	return conn.Transaction(func(tx *gorm.DB) error {
+		if err := res.Cleanup(tx, ids); err != nil {
+			return err
+		}
+		return tx.Unscoped().Where("id IN ? AND deleted_at IS NOT NULL", ids).Delete(res.Model()).Error
+	})
+}
+
+// 获取回收站保留天数
+func getRecycleBinRetentionDays() int {
+	return configCache.GetInt("recycle_bin_retention_days", 30)
+}
+
+// 清理超过保留期限的回收站记录（保留天数小于等于0时不自动清理）
+func cleanupRecycleBin(ctx context.Context) {
+	days := getRecycleBinRetentionDays()
+	if days <= 0 {
+		return
+	}
+	cutoff := time.Now().AddDate(0, 0, -days)
+
+	for resource, res := range recycleBinResources {
+		if ctx.Err() != nil {
+			return
+		}
+		var ids []uint
+		db.Unscoped().Model(res.Model()).Where("deleted_at IS NOT NULL AND deleted_at < ?", cutoff).Pluck("id", &ids)
+		if len(ids) == 0 {
+			continue
+		}
+		if err := purgeRecycleBinItems(db, res, ids); err != nil {
+			appLogger.Error("failed to purge recycle bin", "resource", resource, "error", err)
+			continue
+		}
+		appLogger.Info("purged expired recycle bin records", "resource", resource, "count", len(ids))
+	}
+}
+
+// 启动回收站定时清理任务
+func startRecycleBinCleaner(interval time.Duration) {
+	jobRunner.Every("recycle_bin_cleanup", interval, cleanupRecycleBin)
+}
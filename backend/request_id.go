@@ -0,0 +1,73 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"regexp"
+	"runtime/debug"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 请求ID请求头
+const requestIDHeader = "X-Request-ID"
+
+// 客户端传入的请求ID格式（过长或含特殊字符时重新生成，避免注入日志）
+var requestIDPattern = regexp.MustCompile(`^[A-Za-z0-9._:-]{1,128}$`)
+
+// 生成请求ID
+func newRequestID() string {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return ""
+	}
+	return hex.EncodeToString(buf)
+}
+
+// 请求ID中间件：沿用客户端传入的 X-Request-ID，否则生成新的，并在响应头中返回
+func requestIDMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id := c.GetHeader(requestIDHeader)
+		if !requestIDPattern.MatchString(id) {
+			id = newRequestID()
+		}
+		c.Set("request_id", id)
+		c.Header(requestIDHeader, id)
+		c.Next()
+	}
+}
+
+// 获取当前请求的ID
+func requestIDFromContext(c *gin.Context) string {
+	return c.GetString("request_id")
+}
+
+// 恢复中间件：记录带请求ID的panic日志，并返回统一格式的错误响应
+func recoveryMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		defer func() {
+			err := recover()
+			if err == nil {
+				return
+			}
+			if err == http.ErrAbortHandler {
+				panic(err)
+			}
+			requestLogger(c).Error("panic recovered",
+				"method", c.Request.Method,
+				"path", c.Request.URL.Path,
+				"panic", fmt.Sprint(err),
+				"stack", string(debug.Stack()),
+			)
+			if c.Writer.Written() {
+				c.Abort()
+				return
+			}
+			errorResponse(c, 500, "服务器内部错误")
+			c.Abort()
+		}()
+		c.Next()
+	}
+}
@@ -1,9 +1,11 @@
 package main
 
 import (
+	"sort"
 	"strconv"
 
 	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
 )
 
 // 获取角色列表
@@ -31,6 +33,7 @@ func getRoleById(c *gin.Context) {
 		return
 	}
 
+	setVersionETag(c, role.Version)
 	successResponse(c, role)
 }
 
@@ -49,7 +52,7 @@ func createRole(c *gin.Context) {
 		return
 	}
 
-	result := db.Create(&newRole)
+	result := auditDB(c).Create(&newRole)
 	if result.Error != nil {
 		errorResponse(c, 500, "创建角色失败")
 		return
@@ -58,6 +61,14 @@ func createRole(c *gin.Context) {
 	successResponse(c, newRole)
 }
 
+// 更新角色请求（仅更新请求中出现的字段）
+type RoleUpdateRequest struct {
+	DisplayName *string `json:"display_name" binding:"omitempty,min=1,max=50"`
+	Description *string `json:"description" binding:"omitempty,max=200"`
+	Status      *bool   `json:"status"`
+	Version     *uint   `json:"version"` // 客户端持有的版本号，也可通过If-Match请求头传递
+}
+
 // 更新角色
 func updateRole(c *gin.Context) {
 	id := c.Param("id")
@@ -71,20 +82,51 @@ func updateRole(c *gin.Context) {
 	}
 
 	// 绑定更新数据
-	var updateData Role
-	if err := c.ShouldBindJSON(&updateData); err != nil {
-		errorResponse(c, 400, "请求参数错误")
+	var req RoleUpdateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		errorResponse(c, 400, validationErrorMessage(err))
 		return
 	}
 
-	// 更新字段
-	role.DisplayName = updateData.DisplayName
-	role.Description = updateData.Description
-	role.Status = updateData.Status
+	// 乐观锁：检查客户端持有的版本
+	version, err := expectedVersion(c, req.Version)
+	if err != nil {
+		errorResponse(c, 400, err.Error())
+		return
+	}
+	if version != 0 && version != role.Version {
+		errorResponse(c, 409, "角色已被他人修改，请刷新后重试")
+		return
+	}
 
-	// 保存更新
-	db.Save(&role)
+	// 只收集请求中出现的字段
+	updates := make(map[string]interface{})
+	if req.DisplayName != nil {
+		updates["display_name"] = *req.DisplayName
+	}
+	if req.Description != nil {
+		updates["description"] = *req.Description
+	}
+	if req.Status != nil {
+		updates["status"] = *req.Status
+	}
+
+	if len(updates) > 0 {
+		// 仅当版本未变化时更新，并递增版本号
+		updates["version"] = gorm.Expr("version + 1")
+		result = auditDB(c).Model(&Role{}).Where("id = ? AND version = ?", role.ID, role.Version).Updates(updates)
+		if result.Error != nil {
+			errorResponse(c, 500, "更新角色失败")
+			return
+		}
+		if result.RowsAffected == 0 {
+			errorResponse(c, 409, "角色已被他人修改，请刷新后重试")
+			return
+		}
+		db.First(&role, role.ID)
+	}
 	
+	setVersionETag(c, role.Version)
 	successResponse(c, role)
 }
 
@@ -105,7 +147,7 @@ func deleteRole(c *gin.Context) {
 		return
 	}
 
-	result := db.Delete(&Role{}, id)
+	result := auditDB(c).Delete(&Role{}, id)
 	if result.Error != nil {
 		errorResponse(c, 500, "删除角色失败")
 		return
@@ -169,12 +211,29 @@ func assignRolePermissions(c *gin.Context) {
 		}
 	}
 
-	// 清除原有权限关联
-	db.Model(&role).Association("Permissions").Clear()
-	
-	// 分配新权限
-	if len(permissions) > 0 {
-		db.Model(&role).Association("Permissions").Append(&permissions)
+	// 记录权限变更
+	var oldPermissionNames, newPermissionNames []string
+	db.Table("permissions").
+		Joins("JOIN role_permissions ON role_permissions.permission_id = permissions.id").
+		Where("role_permissions.role_id = ?", role.ID).
+		Order("permissions.name").
+		Pluck("permissions.name", &oldPermissionNames)
+	for _, perm := range permissions {
+		newPermissionNames = append(newPermissionNames, perm.Name)
+	}
+	sort.Strings(newPermissionNames)
+	recordAuditChange(c, "roles", role.ID, "permissions", oldPermissionNames, newPermissionNames)
+
+	// 替换权限关联并递增角色版本号
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&role).Association("Permissions").Replace(&permissions); err != nil {
+			return err
+		}
+		return bumpVersion(tx, &Role{}, role.ID)
+	})
+	if err != nil {
+		errorResponse(c, 500, "分配权限失败")
+		return
 	}
 
 	// 返回更新后的角色信息
@@ -242,12 +301,29 @@ func assignUserRoles(c *gin.Context) {
 		}
 	}
 
-	// 清除原有角色关联
-	db.Model(&user).Association("Roles").Clear()
-	
-	// 分配新角色
-	if len(roles) > 0 {
-		db.Model(&user).Association("Roles").Append(&roles)
+	// 记录角色变更
+	var oldRoleNames, newRoleNames []string
+	db.Table("roles").
+		Joins("JOIN user_roles ON user_roles.role_id = roles.id").
+		Where("user_roles.user_id = ?", user.ID).
+		Order("roles.name").
+		Pluck("roles.name", &oldRoleNames)
+	for _, role := range roles {
+		newRoleNames = append(newRoleNames, role.Name)
+	}
+	sort.Strings(newRoleNames)
+	recordAuditChange(c, "users", user.ID, "roles", oldRoleNames, newRoleNames)
+
+	// 替换角色关联并递增用户版本号
+	err = db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&user).Association("Roles").Replace(&roles); err != nil {
+			return err
+		}
+		return bumpVersion(tx, &User{}, user.ID)
+	})
+	if err != nil {
+		errorResponse(c, 500, "分配角色失败")
+		return
 	}
 
 	successResponse(c, gin.H{
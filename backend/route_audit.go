@@ -0,0 +1,252 @@
+package main
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 路由的审计信息：操作日志中记录的资源、操作和描述
+type RouteAudit struct {
+	Resource    string `json:"resource"`
+	Action      string `json:"action"`
+	Description string `json:"description"`
+}
+
+// 未登记审计信息的路由使用的资源类型
+const unregisteredRouteResource = "unregistered"
+
+// 路由审计信息登记表，键为 "方法 路由模板"（与 c.FullPath() 一致）
+var routeAudits = map[string]RouteAudit{
+	// 健康检查与测试
+	"GET /health":   {"system", "read", "健康检查"},
+	"GET /api/test": {"system", "read", "接口测试"},
+
+	// 认证
+	"POST /api/auth/login":    {"auth", "login", "用户登录"},
+	"POST /api/auth/register": {"auth", "register", "用户注册"},
+	"POST /api/auth/logout":   {"auth", "logout", "用户登出"},
+
+	// 当前用户
+	"GET /api/me":                  {"profile", "read", "查看个人信息"},
+	"PUT /api/me":                  {"profile", "update", "修改个人资料"},
+	"POST /api/change-password":    {"profile", "change_password", "修改密码"},
+	"GET /api/my-permissions":      {"profile", "read", "查看我的权限"},
+	"GET /api/profile-fields":      {"profile", "read", "查看资料字段"},
+	"GET /api/me/personal-data":    {"profile", "export", "导出我的个人数据"},
+	"GET /api/me/auth-events":      {"profile", "read", "查看我的登录记录"},
+	"POST /api/logs/stream/ticket": {"log", "create", "获取日志流订阅票据"},
+
+	// 用户
+	"GET /api/users":                          {"user", "list", "查看用户列表"},
+	"GET /api/users/:id":                      {"user", "read", "查看用户详情"},
+	"POST /api/users":                         {"user", "create", "创建用户"},
+	"PUT /api/users/:id":                      {"user", "update", "更新用户"},
+	"PATCH /api/users/:id":                    {"user", "update", "更新用户"},
+	"DELETE /api/users/:id":                   {"user", "delete", "删除用户"},
+	"POST /api/users/:id/roles":               {"user", "assign_roles", "分配用户角色"},
+	"POST /api/users/bulk":                    {"user", "bulk", "批量操作用户"},
+	"GET /api/users/:id/groups":               {"user", "read", "查看用户所属用户组"},
+	"POST /api/users/:id/state":               {"user", "change_state", "变更用户状态"},
+	"GET /api/users/:id/state-history":        {"user", "read", "查看用户状态历史"},
+	"GET /api/users/:id/personal-data":        {"user", "export", "导出用户个人数据"},
+	"POST /api/users/:id/erase":               {"user", "erase", "擦除用户个人数据"},
+	"GET /api/users/erasures":                 {"user", "list", "查看个人数据擦除记录"},
+	"GET /api/users/recycle-bin":              {"user", "list", "查看用户回收站"},
+	"POST /api/users/recycle-bin/:id/restore": {"user", "restore", "恢复已删除用户"},
+	"DELETE /api/users/recycle-bin/:id":       {"user", "purge", "彻底删除用户"},
+
+	// 角色
+	"GET /api/roles":                          {"role", "list", "查看角色列表"},
+	"GET /api/roles/:id":                      {"role", "read", "查看角色详情"},
+	"POST /api/roles":                         {"role", "create", "创建角色"},
+	"PUT /api/roles/:id":                      {"role", "update", "更新角色"},
+	"PATCH /api/roles/:id":                    {"role", "update", "更新角色"},
+	"DELETE /api/roles/:id":                   {"role", "delete", "删除角色"},
+	"GET /api/roles/recycle-bin":              {"role", "list", "查看角色回收站"},
+	"POST /api/roles/recycle-bin/:id/restore": {"role", "restore", "恢复已删除角色"},
+	"DELETE /api/roles/recycle-bin/:id":       {"role", "purge", "彻底删除角色"},
+
+	// 权限
+	"GET /api/permissions":                          {"permission", "list", "查看权限列表"},
+	"POST /api/permissions/assign":                  {"role", "assign_permissions", "分配角色权限"},
+	"GET /api/permissions/recycle-bin":              {"permission", "list", "查看权限回收站"},
+	"POST /api/permissions/recycle-bin/:id/restore": {"permission", "restore", "恢复已删除权限"},
+	"DELETE /api/permissions/recycle-bin/:id":       {"permission", "purge", "彻底删除权限"},
+
+	// 用户组
+	"GET /api/groups":                         {"group", "list", "查看用户组列表"},
+	"GET /api/groups/:id":                     {"group", "read", "查看用户组详情"},
+	"POST /api/groups":                        {"group", "create", "创建用户组"},
+	"PUT /api/groups/:id":                     {"group", "update", "更新用户组"},
+	"DELETE /api/groups/:id":                  {"group", "delete", "删除用户组"},
+	"GET /api/groups/:id/members":             {"group", "read", "查看用户组成员"},
+	"POST /api/groups/:id/members":            {"group", "add_members", "添加用户组成员"},
+	"DELETE /api/groups/:id/members/:user_id": {"group", "remove_member", "移除用户组成员"},
+	"POST /api/groups/:id/roles":              {"group", "assign_roles", "分配用户组角色"},
+
+	// 自定义资料字段
+	"GET /api/custom-fields":        {"custom_field", "list", "查看自定义字段列表"},
+	"POST /api/custom-fields":       {"custom_field", "create", "创建自定义字段"},
+	"PUT /api/custom-fields/:id":    {"custom_field", "update", "更新自定义字段"},
+	"DELETE /api/custom-fields/:id": {"custom_field", "delete", "删除自定义字段"},
+
+	// 操作日志
+	"GET /api/logs":                   {"log", "list", "查看操作日志"},
+	"GET /api/logs/:id":               {"log", "read", "查看操作日志详情"},
+	"DELETE /api/logs/:id":            {"log", "delete", "删除操作日志"},
+	"POST /api/logs/batch-delete":     {"log", "batch_delete", "批量删除操作日志"},
+	"DELETE /api/logs/clear-old":      {"log", "clear", "清理过期操作日志"},
+	"GET /api/logs/stats":             {"log", "read", "查看操作日志统计"},
+	"GET /api/logs/writer-stats":      {"log", "read", "查看日志写入状态"},
+	"GET /api/logs/verify":            {"log", "verify", "校验操作日志哈希链"},
+	"GET /api/logs/checkpoints":       {"log", "list", "查看日志检查点"},
+	"GET /api/logs/archives":          {"log", "list", "查看日志归档"},
+	"GET /api/logs/archives/download": {"log", "download", "下载日志归档"},
+	"POST /api/logs/search/rebuild":   {"log", "rebuild_index", "重建日志检索索引"},
+	"GET /api/logs/stream":            {"log", "subscribe", "订阅实时日志"},
+	"GET /api/logs/stream/status":     {"log", "read", "查看实时日志订阅状态"},
+	"GET /api/logs/routes":            {"log", "list", "查看路由审计信息"},
+
+	// 认证事件
+	"GET /api/auth-events":              {"auth_event", "list", "查看认证事件"},
+	"GET /api/auth-events/stats":        {"auth_event", "read", "查看认证事件统计"},
+	"DELETE /api/auth-events/clear-old": {"auth_event", "clear", "清理过期认证事件"},
+
+	// 安全告警
+	"GET /api/alerts":              {"alert", "list", "查看安全告警"},
+	"GET /api/alerts/stats":        {"alert", "read", "查看安全告警统计"},
+	"GET /api/alerts/rules":        {"alert", "read", "查看异常检测规则"},
+	"POST /api/alerts/scan":        {"alert", "scan", "执行异常检测"},
+	"GET /api/alerts/:id":          {"alert", "read", "查看安全告警详情"},
+	"POST /api/alerts/:id/ack":     {"alert", "acknowledge", "确认安全告警"},
+	"POST /api/alerts/:id/resolve": {"alert", "resolve", "解决安全告警"},
+
+	// 统计趋势
+	"GET /api/stats/series":           {"stats", "read", "查看统计趋势"},
+	"GET /api/stats/rollups":          {"stats", "read", "查看统计汇总状态"},
+	"POST /api/stats/rollups/rebuild": {"stats", "rebuild", "重建统计汇总"},
+
+	// 系统
+	"GET /api/system/info":      {"system", "read", "查看系统信息"},
+	"GET /api/system/monitor":   {"system", "read", "查看系统监控"},
+	"GET /api/system/log-level": {"system", "read", "查看应用日志级别"},
+	"PUT /api/system/log-level": {"system", "update_log_level", "修改应用日志级别"},
+
+	// 系统配置
+	"GET /api/config/public":                      {"config", "read", "查看公开配置"},
+	"GET /api/config":                             {"config", "list", "查看系统配置"},
+	"GET /api/config/:key":                        {"config", "read", "查看配置项"},
+	"PUT /api/config/:key":                        {"config", "update", "更新配置项"},
+	"POST /api/config/batch":                      {"config", "batch_update", "批量更新配置"},
+	"POST /api/config/reload":                     {"config", "reload", "重新加载配置缓存"},
+	"GET /api/config/revisions":                   {"config", "read", "查看配置修订"},
+	"GET /api/config/snapshot":                    {"config", "read", "查看配置快照"},
+	"GET /api/config/diff":                        {"config", "read", "比较配置差异"},
+	"GET /api/config/:key/history":                {"config", "read", "查看配置修改历史"},
+	"POST /api/config/:key/rollback":              {"config", "rollback", "回滚配置项"},
+	"POST /api/config/batches/:batch_id/rollback": {"config", "rollback", "回滚配置修订批次"},
+	"POST /api/config":                            {"config", "create", "创建配置项"},
+	"DELETE /api/config/:key":                     {"config", "delete", "删除配置项"},
+	"POST /api/config/:key/reset":                 {"config", "reset", "重置配置项"},
+	"POST /api/config/categories/:category/reset": {"config", "reset", "重置分类配置"},
+	"POST /api/config/reset":                      {"config", "reset_all", "重置全部配置"},
+	"GET /api/config/definitions":                 {"config", "read", "查看配置定义"},
+	"POST /api/config/definitions/sync":           {"config", "sync_definitions", "按定义同步配置元数据"},
+	"GET /api/config/drift":                       {"config", "read", "查看配置差异检查结果"},
+
+	// 文件
+	"POST /api/files/upload":     {"file", "upload", "上传文件"},
+	"GET /api/files":             {"file", "list", "查看文件列表"},
+	"GET /api/files/stats":       {"file", "read", "查看文件统计"},
+	"DELETE /api/files/:id":      {"file", "delete", "删除文件"},
+	"GET /api/uploads/:filename": {"file", "download", "访问上传文件"},
+
+	// 导出
+	"GET /api/export/users":       {"user", "export", "导出用户"},
+	"GET /api/export/roles":       {"role", "export", "导出角色"},
+	"GET /api/export/permissions": {"permission", "export", "导出权限"},
+	"GET /api/export/groups":      {"group", "export", "导出用户组"},
+	"GET /api/export/logs":        {"log", "export", "导出操作日志"},
+
+	// 导入
+	"POST /api/import/users":       {"user", "import", "导入用户"},
+	"POST /api/import/roles":       {"role", "import", "导入角色"},
+	"POST /api/import/permissions": {"permission", "import", "导入权限"},
+	"POST /api/import/groups":      {"group", "import", "导入用户组"},
+}
+
+// 查找路由的审计信息（未登记的路由按请求方法推断操作，资源标记为 unregistered）
+func lookupRouteAudit(method, fullPath string) (RouteAudit, bool) {
+	if audit, ok := routeAudits[method+" "+fullPath]; ok {
+		return audit, true
+	}
+	return RouteAudit{
+		Resource: unregisteredRouteResource,
+		Action:   strings.ToLower(method),
+	}, false
+}
+
+// 当前请求路由的审计信息
+func routeAuditFromContext(c *gin.Context) (RouteAudit, bool) {
+	return lookupRouteAudit(c.Request.Method, c.FullPath())
+}
+
+// 路由及其审计信息
+type RouteAuditInfo struct {
+	Method     string `json:"method"`
+	Path       string `json:"path"`
+	Registered bool   `json:"registered"`
+	RouteAudit
+}
+
+// 已注册的路由列表（启动时设置）
+var registeredRoutes gin.RoutesInfo
+
+// 检查所有路由是否登记了审计信息，返回未登记的路由
+func checkRouteAudits(routes gin.RoutesInfo) []string {
+	registeredRoutes = routes
+
+	var missing []string
+	for _, route := range routes {
+		if _, ok := routeAudits[route.Method+" "+route.Path]; !ok {
+			missing = append(missing, route.Method+" "+route.Path)
+		}
+	}
+	sort.Strings(missing)
+	for _, route := range missing {
+		appLogger.Warn("route has no audit metadata", "route", route, "resource", unregisteredRouteResource)
+	}
+	return missing
+}
+
+// 获取所有路由的审计信息
+func getRouteAudits(c *gin.Context) {
+	routes := make([]RouteAuditInfo, 0, len(registeredRoutes))
+	unregistered := 0
+	for _, route := range registeredRoutes {
+		audit, ok := lookupRouteAudit(route.Method, route.Path)
+		if !ok {
+			unregistered++
+		}
+		routes = append(routes, RouteAuditInfo{
+			Method:     route.Method,
+			Path:       route.Path,
+			Registered: ok,
+			RouteAudit: audit,
+		})
+	}
+	sort.Slice(routes, func(i, j int) bool {
+		if routes[i].Path != routes[j].Path {
+			return routes[i].Path < routes[j].Path
+		}
+		return routes[i].Method < routes[j].Method
+	})
+
+	successResponse(c, gin.H{
+		"routes":       routes,
+		"total":        len(routes),
+		"unregistered": unregistered,
+	})
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// 部署密钥所在目录（可通过 JING_ADMIN_SECRET_DIR 修改）
+func secretDir() string {
+	if dir := os.Getenv("JING_ADMIN_SECRET_DIR"); dir != "" {
+		return dir
+	}
+	return "secrets"
+}
+
+// 加载部署密钥：优先使用环境变量，否则读取密钥文件，文件不存在时随机生成
+// 密钥文件需要和数据库一起备份，丢失后已生成的假名和签名无法再核对
+func loadDeploymentSecret(envName, filename string) ([]byte, error) {
+	if value := os.Getenv(envName); value != "" {
+		if len(value) < 32 {
+			return nil, fmt.Errorf("%s 长度不能少于32个字符", envName)
+		}
+		return []byte(value), nil
+	}
+
+	path := filepath.Join(secretDir(), filename)
+	data, err := os.ReadFile(path)
+	if err == nil {
+		key, err := hex.DecodeString(strings.TrimSpace(string(data)))
+		if err != nil || len(key) < 32 {
+			return nil, fmt.Errorf("密钥文件 %s 格式错误", path)
+		}
+		return key, nil
+	}
+	if !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(secretDir(), 0700); err != nil {
+		return nil, err
+	}
+	if err := os.WriteFile(path, []byte(hex.EncodeToString(key)+"\n"), 0600); err != nil {
+		return nil, err
+	}
+	appLogger.Warn("generated new deployment secret, back it up together with the database", "file", path)
+	return key, nil
+}
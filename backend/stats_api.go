@@ -0,0 +1,243 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 单次查询的最大时间桶数
+const statMaxBuckets = 2000
+
+// 统计区间
+type statInterval struct {
+	seconds int64 // 桶长度
+	shift   int64 // 对齐偏移（周从周一开始，Unix纪元是周四）
+	span    time.Duration
+}
+
+var statIntervals = map[string]statInterval{
+	"hour": {seconds: 3600, span: 48 * time.Hour},
+	"day":  {seconds: 86400, span: 30 * 24 * time.Hour},
+	"week": {seconds: 7 * 86400, shift: 3 * 86400, span: 26 * 7 * 24 * time.Hour},
+}
+
+// 对外的统计指标，operations 按 group_by 选择操作类型或资源类型维度
+var statSeriesMetrics = []string{"logins", "active_users", "operations", "errors", "uploads", "upload_bytes"}
+
+// 时间序列
+type StatSeries struct {
+	Metric    string  `json:"metric"`
+	Dimension string  `json:"dimension"`
+	Values    []int64 `json:"values"`
+	Total     int64   `json:"total"`
+}
+
+// 解析时间参数：支持日期或 RFC3339 时间，日期按请求的时区解释
+func parseStatTime(value string, loc *time.Location) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	for _, layout := range []string{"2006-01-02 15:04:05", "2006-01-02T15:04", "2006-01-02"} {
+		if t, err := time.ParseInLocation(layout, value, loc); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid time %q", value)
+}
+
+// 按时区偏移将时间片映射到时间桶的 SQL 表达式（夏令时切换前后使用各自的偏移）
+func statBucketExpr(column string, start, end time.Time, loc *time.Location, interval statInterval) (string, []interface{}) {
+	var cases []string
+	var args []interface{}
+	t := start.In(loc)
+	for {
+		_, offset := t.Zone()
+		_, zoneEnd := t.ZoneBounds()
+		if zoneEnd.IsZero() || !zoneEnd.Before(end) {
+			cases = append(cases, fmt.Sprintf("ELSE %d", offset))
+			break
+		}
+		cases = append(cases, "WHEN "+column+" < ? THEN "+fmt.Sprint(offset))
+		args = append(args, zoneEnd.Unix())
+		t = zoneEnd.In(loc)
+	}
+
+	offsetExpr := "CASE " + strings.Join(cases, " ") + " END"
+	if len(cases) == 1 {
+		offsetExpr = strings.TrimPrefix(cases[0], "ELSE ")
+	}
+	return fmt.Sprintf("((%s + %s + %d) / %d)", column, offsetExpr, interval.shift, interval.seconds), args
+}
+
+// 时间所在的时间桶（与 statBucketExpr 的计算一致）
+func statBucketKey(t time.Time, loc *time.Location, interval statInterval) int64 {
+	_, offset := t.In(loc).Zone()
+	return (t.Unix() + int64(offset) + interval.shift) / interval.seconds
+}
+
+// 时间桶的本地开始时间
+func statBucketTime(key int64, loc *time.Location, interval statInterval) time.Time {
+	wall := time.Unix(key*interval.seconds-interval.shift, 0).UTC()
+	return time.Date(wall.Year(), wall.Month(), wall.Day(), wall.Hour(), 0, 0, 0, loc)
+}
+
+// 获取统计时间序列
+// 参数：metrics（逗号分隔，默认全部）、interval（hour/day/week）、start、end、tz（如 Asia/Shanghai）、group_by（operations 的维度：action/resource）
+func getStatSeries(c *gin.Context) {
+	intervalName := c.DefaultQuery("interval", "day")
+	interval, ok := statIntervals[intervalName]
+	if !ok {
+		errorResponse(c, 400, "interval 只能是 hour、day 或 week")
+		return
+	}
+
+	loc, err := time.LoadLocation(c.DefaultQuery("tz", "Local"))
+	if err != nil {
+		errorResponse(c, 400, "无效的时区: "+c.Query("tz"))
+		return
+	}
+
+	end := time.Now()
+	if value := c.Query("end"); value != "" {
+		if end, err = parseStatTime(value, loc); err != nil {
+			errorResponse(c, 400, err.Error())
+			return
+		}
+	}
+	start := end.Add(-interval.span)
+	if value := c.Query("start"); value != "" {
+		if start, err = parseStatTime(value, loc); err != nil {
+			errorResponse(c, 400, err.Error())
+			return
+		}
+	}
+	if !start.Before(end) {
+		errorResponse(c, 400, "开始时间必须早于结束时间")
+		return
+	}
+
+	groupBy := c.DefaultQuery("group_by", "action")
+	if groupBy != "action" && groupBy != "resource" {
+		errorResponse(c, 400, "group_by 只能是 action 或 resource")
+		return
+	}
+
+	metrics := statSeriesMetrics
+	if value := c.Query("metrics"); value != "" {
+		metrics = strings.Split(value, ",")
+	}
+
+	// 时间桶：从 start 所在的桶到 end 所在的桶
+	first := statBucketKey(start, loc, interval)
+	last := statBucketKey(end.Add(-time.Second), loc, interval)
+	if last-first+1 > statMaxBuckets {
+		errorResponse(c, 400, fmt.Sprintf("时间桶数量超过上限 %d，请缩小范围或使用更大的间隔", statMaxBuckets))
+		return
+	}
+
+	// 先汇总最新的数据
+	if _, err := updateStatRollups(c.Request.Context()); err != nil {
+		errorResponse(c, 500, "更新统计汇总失败")
+		return
+	}
+
+	buckets := make([]time.Time, 0, last-first+1)
+	for key := first; key <= last; key++ {
+		buckets = append(buckets, statBucketTime(key, loc, interval))
+	}
+
+	series := []StatSeries{}
+	for _, metric := range metrics {
+		metric = strings.TrimSpace(metric)
+		var rows []struct {
+			BucketKey int64
+			Dimension string
+			Value     int64
+		}
+
+		switch metric {
+		case "active_users":
+			expr, args := statBucketExpr("bucket", start, end, loc, interval)
+			err = db.Model(&StatActiveUser{}).
+				Select(expr+" AS bucket_key, '' AS dimension, COUNT(DISTINCT user_id) AS value", args...).
+				Where("bucket >= ? AND bucket < ?", statSlot(start), end.Unix()).
+				Group("bucket_key").
+				Scan(&rows).Error
+		case "logins", "operations", "errors", "uploads", "upload_bytes":
+			stored := metric
+			if metric == "operations" {
+				stored = "operations_" + groupBy
+			}
+			expr, args := statBucketExpr("bucket", start, end, loc, interval)
+			err = db.Model(&StatRollup{}).
+				Select(expr+" AS bucket_key, dimension, SUM(value) AS value", args...).
+				Where("metric = ? AND bucket >= ? AND bucket < ?", stored, statSlot(start), end.Unix()).
+				Group("bucket_key, dimension").
+				Scan(&rows).Error
+		default:
+			errorResponse(c, 400, "不支持的统计指标: "+metric)
+			return
+		}
+		if err != nil {
+			errorResponse(c, 500, "获取统计数据失败")
+			return
+		}
+
+		index := make(map[string]int)
+		for _, row := range rows {
+			if row.BucketKey < first || row.BucketKey > last {
+				continue
+			}
+			i, ok := index[row.Dimension]
+			if !ok {
+				i = len(series)
+				index[row.Dimension] = i
+				series = append(series, StatSeries{Metric: metric, Dimension: row.Dimension, Values: make([]int64, len(buckets))})
+			}
+			series[i].Values[row.BucketKey-first] += row.Value
+			series[i].Total += row.Value
+		}
+	}
+
+	successResponse(c, gin.H{
+		"interval": intervalName,
+		"timezone": loc.String(),
+		"start":    start.In(loc),
+		"end":      end.In(loc),
+		"buckets":  buckets,
+		"series":   series,
+		"group_by": groupBy,
+	})
+}
+
+// 重建统计汇总
+func rebuildStatRollupsAPI(c *gin.Context) {
+	processed, err := rebuildStatRollups(c.Request.Context())
+	if err != nil {
+		errorResponse(c, 500, "重建统计汇总失败")
+		return
+	}
+	successResponse(c, gin.H{
+		"processed": processed,
+	})
+}
+
+// 汇总表占用情况
+func getStatRollupStatus(c *gin.Context) {
+	var rollups, activeUsers int64
+	db.Model(&StatRollup{}).Count(&rollups)
+	db.Model(&StatActiveUser{}).Count(&activeUsers)
+
+	var cursors []StatRollupCursor
+	db.Find(&cursors)
+
+	successResponse(c, gin.H{
+		"rollup_rows":      rollups,
+		"active_user_rows": activeUsers,
+		"slot_seconds":     statSlotSeconds,
+		"cursors":          cursors,
+	})
+}
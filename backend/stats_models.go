@@ -0,0 +1,264 @@
+package main
+
+import (
+	"context"
+	"strconv"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// 汇总粒度：15分钟（所有时区的偏移都是15分钟的整数倍，可按任意时区精确聚合）
+const statSlotSeconds = 15 * 60
+
+// 每次增量汇总处理的最大记录数
+const statRollupBatchSize = 5000
+
+// 统计指标
+const (
+	StatLogins             = "logins"              // 登录次数，维度：success/failure
+	StatOperationsAction   = "operations_action"   // 操作次数，维度：操作类型
+	StatOperationsResource = "operations_resource" // 操作次数，维度：资源类型
+	StatErrors             = "errors"              // 错误响应次数，维度：状态码
+	StatUploads            = "uploads"             // 上传文件数，维度：文件分类
+	StatUploadBytes        = "upload_bytes"        // 上传字节数，维度：文件分类
+)
+
+// 统计汇总（按15分钟时间片累加）
+type StatRollup struct {
+	ID        uint   `json:"id" gorm:"primaryKey"`
+	Bucket    int64  `json:"bucket" gorm:"not null;uniqueIndex:idx_stat_rollup"`    // 时间片开始（Unix秒，UTC）
+	Metric    string `json:"metric" gorm:"not null;uniqueIndex:idx_stat_rollup"`    // 指标
+	Dimension string `json:"dimension" gorm:"not null;uniqueIndex:idx_stat_rollup"` // 维度
+	Value     int64  `json:"value" gorm:"not null"`
+}
+
+// 时间片内的活跃用户（去重计数不能累加，按用户记录）
+type StatActiveUser struct {
+	Bucket int64 `json:"bucket" gorm:"primaryKey;autoIncrement:false"`
+	UserID uint  `json:"user_id" gorm:"primaryKey;autoIncrement:false"`
+}
+
+// 增量汇总进度
+type StatRollupCursor struct {
+	Source    string    `json:"source" gorm:"primaryKey"` // 数据来源表
+	LastID    uint      `json:"last_id"`                  // 已汇总的最大ID
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// 汇总任务互斥（定时任务和接口都会触发）
+var statRollupMu sync.Mutex
+
+// 初始化统计汇总
+func initStatsSystem() error {
+	// 自动迁移数据库
+	return db.AutoMigrate(&StatRollup{}, &StatActiveUser{}, &StatRollupCursor{})
+}
+
+// 时间所在的时间片
+func statSlot(t time.Time) int64 {
+	unix := t.Unix()
+	return unix - ((unix%statSlotSeconds)+statSlotSeconds)%statSlotSeconds
+}
+
+// 汇总累加器
+type statAccumulator struct {
+	rollups map[StatRollup]int64
+	active  map[StatActiveUser]struct{}
+}
+
+func newStatAccumulator() *statAccumulator {
+	return &statAccumulator{
+		rollups: make(map[StatRollup]int64),
+		active:  make(map[StatActiveUser]struct{}),
+	}
+}
+
+func (a *statAccumulator) add(t time.Time, metric, dimension string, value int64) {
+	a.rollups[StatRollup{Bucket: statSlot(t), Metric: metric, Dimension: dimension}] += value
+}
+
+func (a *statAccumulator) activeUser(t time.Time, userID uint) {
+	if userID != 0 {
+		a.active[StatActiveUser{Bucket: statSlot(t), UserID: userID}] = struct{}{}
+	}
+}
+
+// 写入汇总数据并推进进度（同一事务中完成，避免重复累加）
+func (a *statAccumulator) flush(tx *gorm.DB, source string, lastID uint) error {
+	rollups := make([]StatRollup, 0, len(a.rollups))
+	for key, value := range a.rollups {
+		key.Value = value
+		rollups = append(rollups, key)
+	}
+	if len(rollups) > 0 {
+		err := tx.Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "bucket"}, {Name: "metric"}, {Name: "dimension"}},
+			DoUpdates: clause.Assignments(map[string]interface{}{"value": gorm.Expr("stat_rollups.value + excluded.value")}),
+		}).CreateInBatches(rollups, 500).Error
+		if err != nil {
+			return err
+		}
+	}
+
+	active := make([]StatActiveUser, 0, len(a.active))
+	for key := range a.active {
+		active = append(active, key)
+	}
+	if len(active) > 0 {
+		if err := tx.Clauses(clause.OnConflict{DoNothing: true}).CreateInBatches(active, 500).Error; err != nil {
+			return err
+		}
+	}
+
+	return tx.Save(&StatRollupCursor{Source: source, LastID: lastID, UpdatedAt: time.Now()}).Error
+}
+
+// 汇总来源：读取ID大于进度的一批记录并累加，返回本批最大ID和记录数
+type statRollupSource struct {
+	name    string
+	collect func(afterID uint, acc *statAccumulator) (uint, int, error)
+}
+
+var statRollupSources = []statRollupSource{
+	{"operation_logs", collectOperationLogStats},
+	{"auth_events", collectAuthEventStats},
+	{"uploaded_files", collectUploadStats},
+}
+
+// 操作日志：按操作类型、资源类型、错误状态码汇总，并记录活跃用户
+func collectOperationLogStats(afterID uint, acc *statAccumulator) (uint, int, error) {
+	var logs []OperationLog
+	err := db.Select("id", "user_id", "action", "resource", "status", "created_at").
+		Where("id > ? AND archived = ?", afterID, false).
+		Order("id").
+		Limit(statRollupBatchSize).
+		Find(&logs).Error
+	if err != nil || len(logs) == 0 {
+		return afterID, 0, err
+	}
+
+	for _, entry := range logs {
+		acc.add(entry.CreatedAt, StatOperationsAction, entry.Action, 1)
+		acc.add(entry.CreatedAt, StatOperationsResource, entry.Resource, 1)
+		if entry.Status >= 400 {
+			acc.add(entry.CreatedAt, StatErrors, strconv.Itoa(entry.Status), 1)
+		}
+		acc.activeUser(entry.CreatedAt, entry.UserID)
+	}
+	return logs[len(logs)-1].ID, len(logs), nil
+}
+
+// 认证事件：按结果汇总登录次数，成功登录的用户计为活跃用户
+func collectAuthEventStats(afterID uint, acc *statAccumulator) (uint, int, error) {
+	var events []AuthEvent
+	err := db.Select("id", "event", "success", "user_id", "created_at").
+		Where("id > ?", afterID).
+		Order("id").
+		Limit(statRollupBatchSize).
+		Find(&events).Error
+	if err != nil || len(events) == 0 {
+		return afterID, 0, err
+	}
+
+	for _, event := range events {
+		if event.Event != AuthEventLogin {
+			continue
+		}
+		if event.Success {
+			acc.add(event.CreatedAt, StatLogins, "success", 1)
+			acc.activeUser(event.CreatedAt, event.UserID)
+		} else {
+			acc.add(event.CreatedAt, StatLogins, "failure", 1)
+		}
+	}
+	return events[len(events)-1].ID, len(events), nil
+}
+
+// 上传文件：按分类汇总文件数和字节数
+func collectUploadStats(afterID uint, acc *statAccumulator) (uint, int, error) {
+	var files []UploadedFile
+	err := db.Select("id", "file_size", "category", "created_at").
+		Where("id > ?", afterID).
+		Order("id").
+		Limit(statRollupBatchSize).
+		Find(&files).Error
+	if err != nil || len(files) == 0 {
+		return afterID, 0, err
+	}
+
+	for _, file := range files {
+		acc.add(file.CreatedAt, StatUploads, file.Category, 1)
+		acc.add(file.CreatedAt, StatUploadBytes, file.Category, file.FileSize)
+	}
+	return files[len(files)-1].ID, len(files), nil
+}
+
+// 增量更新汇总表，返回本次处理的记录数
+// 每批数据在独立事务中提交，ctx 取消后在批次之间停止
+func updateStatRollups(ctx context.Context) (int, error) {
+	statRollupMu.Lock()
+	defer statRollupMu.Unlock()
+
+	total := 0
+	for _, source := range statRollupSources {
+		for {
+			if err := ctx.Err(); err != nil {
+				return total, err
+			}
+			var cursor StatRollupCursor
+			if err := db.Where("source = ?", source.name).Limit(1).Find(&cursor).Error; err != nil {
+				return total, err
+			}
+
+			acc := newStatAccumulator()
+			lastID, count, err := source.collect(cursor.LastID, acc)
+			if err != nil {
+				return total, err
+			}
+			if count == 0 {
+				break
+			}
+			if err := db.Transaction(func(tx *gorm.DB) error {
+				return acc.flush(tx, source.name, lastID)
+			}); err != nil {
+				return total, err
+			}
+
+			total += count
+			if count < statRollupBatchSize {
+				break
+			}
+		}
+	}
+	return total, nil
+}
+
+// 清空汇总表并从头重新汇总
+func rebuildStatRollups(ctx context.Context) (int, error) {
+	statRollupMu.Lock()
+	err := db.Transaction(func(tx *gorm.DB) error {
+		for _, model := range []interface{}{&StatRollup{}, &StatActiveUser{}, &StatRollupCursor{}} {
+			if err := tx.Session(&gorm.Session{AllowGlobalUpdate: true}).Delete(model).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	statRollupMu.Unlock()
+	if err != nil {
+		return 0, err
+	}
+	return updateStatRollups(ctx)
+}
+
+// 启动统计汇总定时任务
+func startStatRollupJob(interval time.Duration) {
+	jobRunner.Every("stat_rollup", interval, func(ctx context.Context) {
+		if _, err := updateStatRollups(ctx); err != nil && ctx.Err() == nil {
+			appLogger.Error("failed to update stat rollups", "error", err)
+		}
+	})
+}
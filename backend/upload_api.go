@@ -13,6 +13,7 @@ import (
 	"github.com/disintegration/imaging"
 
 	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
 )
 
 // 文件上传模型
@@ -90,22 +91,20 @@ func uploadFile(c *gin.Context) {
 	}
 
 	// 检查文件大小限制
-	maxSize := getConfigValue("upload_max_size", "10485760") // 默认10MB
-	maxSizeInt, _ := strconv.ParseInt(maxSize, 10, 64)
+	maxSizeInt := configCache.GetInt64("upload_max_size", 10485760) // 默认10MB
 	if file.Size > maxSizeInt {
 		errorResponse(c, 400, fmt.Sprintf("文件大小超出限制(最大 %.1f MB)", float64(maxSizeInt)/(1024*1024)))
 		return
 	}
 
 	// 检查文件类型
-	allowedTypes := getConfigValue("upload_allowed_types", "jpg,jpeg,png,gif,pdf,doc,docx,xls,xlsx")
+	allowedTypesList := configCache.GetList("upload_allowed_types", "jpg,jpeg,png,gif,pdf,doc,docx,xls,xlsx")
 	fileExt := strings.ToLower(filepath.Ext(file.Filename)[1:])
 	if fileExt == "" {
 		errorResponse(c, 400, "文件必须有扩展名")
 		return
 	}
 
-	allowedTypesList := strings.Split(allowedTypes, ",")
 	if !containsSlice(allowedTypesList, fileExt) {
 		errorResponse(c, 400, fmt.Sprintf("不支持的文件类型: %s", fileExt))
 		return
@@ -194,7 +193,7 @@ func uploadFile(c *gin.Context) {
 			// 删除文件
 			os.Remove(oldAvatar.FilePath)
 			// 删除数据库记录
-			db.Delete(&oldAvatar)
+			auditDB(c).Delete(&oldAvatar)
 		}
 	}
 
@@ -213,7 +212,7 @@ func uploadFile(c *gin.Context) {
 		CreatedAt:    time.Now(),
 	}
 
-	result := db.Create(&uploadedFile)
+	result := auditDB(c).Create(&uploadedFile)
 	if result.Error != nil {
 		// 如果数据库保存失败，删除已上传的文件
 		os.Remove(fullPath)
@@ -224,7 +223,10 @@ func uploadFile(c *gin.Context) {
 	// 如果是头像上传，更新用户头像字段
 	if category == "avatar" {
 		avatarURL := fmt.Sprintf("/api/uploads/%s", newFileName)
-		db.Model(&User{}).Where("id = ?", uid).Update("avatar", avatarURL)
+		auditDB(c).Model(&User{}).Where("id = ?", uid).Updates(map[string]interface{}{
+			"avatar":  avatarURL,
+			"version": gorm.Expr("version + 1"),
+		})
 	}
 
 	successResponse(c, gin.H{
@@ -308,11 +310,11 @@ func deleteFile(c *gin.Context) {
 	// 删除物理文件
 	if err := os.Remove(file.FilePath); err != nil {
 		// 文件可能已经被删除，记录日志但继续删除数据库记录
-		fmt.Printf("Warning: Failed to delete file %s: %v\n", file.FilePath, err)
+		requestLogger(c).Warn("failed to delete file", "path", file.FilePath, "error", err)
 	}
 
 	// 删除数据库记录
-	result = db.Delete(&file)
+	result = auditDB(c).Delete(&file)
 	if result.Error != nil {
 		errorResponse(c, 500, "删除文件记录失败")
 		return
@@ -434,11 +436,7 @@ func getMimeTypeByExt(ext string) string {
 
 // 辅助函数：获取系统配置值
 func getConfigValue(key, defaultValue string) string {
-	var config SystemConfig
-	if err := db.Where("key = ?", key).First(&config).Error; err != nil {
-		return defaultValue
-	}
-	return config.Value
+	return configCache.Get(key, defaultValue)
 }
 
 // 辅助函数：检查字符串切片是否包含某个元素
@@ -0,0 +1,232 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// 单次批量操作允许的最大用户数
+const maxBulkUsers = 1000
+
+// 批量操作请求
+type BulkUserRequest struct {
+	IDs        []uint            `json:"ids"`                          // 用户ID列表
+	Filter     map[string]string `json:"filter"`                       // 筛选条件，与用户列表查询参数一致
+	Operation  string            `json:"operation" binding:"required"` // 操作类型
+	RoleIDs    []uint            `json:"role_ids"`                     // assign_roles 使用
+	RoleID     uint              `json:"role_id"`                      // add_role / remove_role 使用
+	Department string            `json:"department"`                   // move_department 使用
+}
+
+// 单个用户的操作结果
+type BulkUserResult struct {
+	UserID   uint   `json:"user_id"`
+	Username string `json:"username"`
+	Success  bool   `json:"success"`
+	Error    string `json:"error,omitempty"`
+}
+
+// 批量用户操作
+func bulkUserOperation(c *gin.Context) {
+	var req BulkUserRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		errorResponse(c, 400, "请求参数错误")
+		return
+	}
+
+	// 校验操作参数
+	var roles []Role
+	switch req.Operation {
+	case "enable", "disable", "delete", "force_password_reset":
+	case "move_department":
+		if req.Department == "" {
+			errorResponse(c, 400, "请指定目标部门")
+			return
+		}
+	case "assign_roles":
+		if len(req.RoleIDs) > 0 {
+			db.Where("id IN ?", req.RoleIDs).Find(&roles)
+			if len(roles) != len(req.RoleIDs) {
+				errorResponse(c, 400, "角色不存在")
+				return
+			}
+		}
+	case "add_role", "remove_role":
+		var role Role
+		if err := db.First(&role, req.RoleID).Error; err != nil {
+			errorResponse(c, 400, "角色不存在")
+			return
+		}
+		roles = []Role{role}
+	default:
+		errorResponse(c, 400, "不支持的批量操作: "+req.Operation)
+		return
+	}
+
+	// 确定目标用户：优先使用ID列表，否则使用筛选条件
+	var users []User
+	if len(req.IDs) > 0 {
+		db.Where("id IN ?", req.IDs).Find(&users)
+	} else if len(req.Filter) > 0 {
+		// 筛选条件必须至少包含一个有效条件，避免未知或空条件匹配全部用户
+		if err := validateBulkUserFilter(req.Filter); err != nil {
+			errorResponse(c, 400, err.Error())
+			return
+		}
+		query := buildUserListQuery(func(key string) string { return req.Filter[key] })
+		query.Limit(maxBulkUsers + 1).Find(&users)
+	} else {
+		errorResponse(c, 400, "请提供用户ID列表或筛选条件")
+		return
+	}
+
+	if len(users) == 0 {
+		errorResponse(c, 404, "没有匹配的用户")
+		return
+	}
+	if len(users) > maxBulkUsers {
+		errorResponse(c, 400, fmt.Sprintf("单次最多操作 %d 个用户", maxBulkUsers))
+		return
+	}
+
+	currentUserID := c.GetUint("user_id")
+	currentUsername := c.GetString("username")
+
+	// 在事务中逐个执行，每个用户使用独立的保存点，失败不影响其他用户
+	results := make([]BulkUserResult, 0, len(users))
+	found := make(map[uint]bool)
+	successCount := 0
+
+	err := auditDB(c).Transaction(func(tx *gorm.DB) error {
+		for i := range users {
+			user := users[i]
+			found[user.ID] = true
+			itemErr := tx.Transaction(func(itemTx *gorm.DB) error {
+				return applyBulkUserOperation(itemTx, &user, req, roles, currentUserID, currentUsername)
+			})
+
+			result := BulkUserResult{UserID: user.ID, Username: user.Username, Success: itemErr == nil}
+			if itemErr != nil {
+				result.Error = itemErr.Error()
+			} else {
+				successCount++
+			}
+			results = append(results, result)
+		}
+		return nil
+	})
+	if err != nil {
+		errorResponse(c, 500, "批量操作失败")
+		return
+	}
+
+	// 状态变更、删除和强制修改密码需要立即生效，提交后使认证缓存失效
+	for _, user := range users {
+		authUsers.invalidate(user.ID)
+	}
+
+	// 请求中不存在的用户ID
+	for _, id := range req.IDs {
+		if !found[id] {
+			results = append(results, BulkUserResult{UserID: id, Success: false, Error: "用户不存在"})
+		}
+	}
+
+	successResponse(c, gin.H{
+		"operation": req.Operation,
+		"total":     len(results),
+		"success":   successCount,
+		"failed":    len(results) - successCount,
+		"results":   results,
+	})
+
+	// 记录一条汇总的操作日志
+	details, _ := json.Marshal(gin.H{
+		"operation": req.Operation,
+		"filter":    req.Filter,
+		"role_ids":  req.RoleIDs,
+		"role_id":   req.RoleID,
+		"total":     len(results),
+		"success":   successCount,
+		"failed":    len(results) - successCount,
+		"results":   results,
+	})
+	logOperationFromContext(c, "bulk_"+req.Operation, "user", "", string(details))
+}
+
+// 批量操作支持的筛选条件（与用户列表查询参数一致，另支持 cf_<字段键名>）
+var bulkUserFilterKeys = map[string]bool{
+	"keyword": true, "status": true, "state": true, "department": true, "role_id": true, "role": true,
+	"last_login_start": true, "last_login_end": true,
+}
+
+// 检查筛选条件：不允许未知条件，且至少有一个非空条件
+func validateBulkUserFilter(filter map[string]string) error {
+	customFields := make(map[string]bool)
+	for _, field := range getCustomFields() {
+		customFields["cf_"+field.Key] = true
+	}
+
+	hasValue := false
+	for key, value := range filter {
+		if !bulkUserFilterKeys[key] && !customFields[key] {
+			return fmt.Errorf("不支持的筛选条件: %s", key)
+		}
+		if strings.TrimSpace(value) != "" {
+			hasValue = true
+		}
+	}
+	if !hasValue {
+		return errors.New("筛选条件不能为空")
+	}
+	return nil
+}
+
+// 对单个用户执行批量操作
+func applyBulkUserOperation(tx *gorm.DB, user *User, req BulkUserRequest, roles []Role, currentUserID uint, currentUsername string) error {
+	isSelf := currentUserID == user.ID
+
+	var err error
+	switch req.Operation {
+	case "enable":
+		if user.State == UserStateActive {
+			return nil
+		}
+		return changeUserState(tx, user, UserStateActive, "批量启用", nil, currentUserID, currentUsername)
+	case "disable":
+		if isSelf {
+			return errors.New("不能禁用当前登录用户")
+		}
+		if user.State == UserStateSuspended {
+			return nil
+		}
+		return changeUserState(tx, user, UserStateSuspended, "批量禁用", nil, currentUserID, currentUsername)
+	case "delete":
+		if isSelf {
+			return errors.New("不能删除当前登录用户")
+		}
+		return tx.Delete(user).Error
+	case "assign_roles":
+		err = tx.Model(user).Association("Roles").Replace(roles)
+	case "add_role":
+		err = tx.Model(user).Association("Roles").Append(roles)
+	case "remove_role":
+		err = tx.Model(user).Association("Roles").Delete(roles)
+	case "move_department":
+		err = tx.Model(user).Update("department", req.Department).Error
+	case "force_password_reset":
+		err = tx.Model(user).Update("must_change_password", true).Error
+	default:
+		return errors.New("不支持的操作")
+	}
+	if err != nil {
+		return err
+	}
+	// 修改关联和字段后递增版本号，客户端持有的旧版本不能再覆盖
+	return bumpVersion(tx, &User{}, user.ID)
+}
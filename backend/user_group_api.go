@@ -0,0 +1,352 @@
+package main
+
+import (
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 获取用户组列表
+func getUserGroupList(c *gin.Context) {
+	query := db.Model(&UserGroup{}).Preload("Roles")
+
+	if keyword := c.Query("keyword"); keyword != "" {
+		like := "%" + keyword + "%"
+		query = query.Where("name LIKE ? OR display_name LIKE ?", like, like)
+	}
+	if parentID := c.Query("parent_id"); parentID != "" {
+		if parentID == "0" {
+			query = query.Where("parent_id IS NULL")
+		} else {
+			query = query.Where("parent_id = ?", parentID)
+		}
+	}
+
+	var groups []UserGroup
+	result := query.Order("id").Find(&groups)
+	if result.Error != nil {
+		errorResponse(c, 500, "获取用户组列表失败")
+		return
+	}
+
+	// 统计直接成员数量
+	type memberCount struct {
+		UserGroupID uint
+		Count       int64
+	}
+	var counts []memberCount
+	db.Table("user_group_members").
+		Select("user_group_id, COUNT(*) as count").
+		Group("user_group_id").
+		Scan(&counts)
+	countMap := make(map[uint]int64)
+	for _, mc := range counts {
+		countMap[mc.UserGroupID] = mc.Count
+	}
+
+	items := make([]gin.H, 0, len(groups))
+	for _, group := range groups {
+		items = append(items, gin.H{
+			"group":        group,
+			"member_count": countMap[group.ID],
+		})
+	}
+
+	successResponse(c, gin.H{
+		"groups": items,
+		"total":  len(groups),
+	})
+}
+
+// 根据ID获取用户组
+func getUserGroupById(c *gin.Context) {
+	id := c.Param("id")
+	var group UserGroup
+	result := db.Preload("Roles").Preload("Children").First(&group, id)
+	if result.Error != nil {
+		errorResponse(c, 404, "用户组不存在")
+		return
+	}
+
+	successResponse(c, group)
+}
+
+// 创建用户组
+func createUserGroup(c *gin.Context) {
+	var req struct {
+		Name        string `json:"name" binding:"required,max=50"`
+		DisplayName string `json:"display_name" binding:"required,max=100"`
+		Description string `json:"description" binding:"max=200"`
+		ParentID    *uint  `json:"parent_id"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		errorResponse(c, 400, validationErrorMessage(err))
+		return
+	}
+
+	// 检查组名是否存在
+	var existing UserGroup
+	if err := db.Where("name = ?", req.Name).First(&existing).Error; err == nil {
+		errorResponse(c, 400, "用户组名已存在")
+		return
+	}
+
+	// 检查上级组
+	if req.ParentID != nil {
+		var parent UserGroup
+		if err := db.First(&parent, *req.ParentID).Error; err != nil {
+			errorResponse(c, 400, "上级用户组不存在")
+			return
+		}
+	}
+
+	group := UserGroup{
+		Name:        req.Name,
+		DisplayName: req.DisplayName,
+		Description: req.Description,
+		ParentID:    req.ParentID,
+	}
+	if err := auditDB(c).Create(&group).Error; err != nil {
+		errorResponse(c, 500, "创建用户组失败")
+		return
+	}
+
+	successResponse(c, group)
+}
+
+// 更新用户组
+func updateUserGroup(c *gin.Context) {
+	id := c.Param("id")
+	var group UserGroup
+	if err := db.First(&group, id).Error; err != nil {
+		errorResponse(c, 404, "用户组不存在")
+		return
+	}
+
+	var req struct {
+		DisplayName *string `json:"display_name" binding:"omitempty,min=1,max=100"`
+		Description *string `json:"description" binding:"omitempty,max=200"`
+		ParentID    *uint   `json:"parent_id"` // 传0表示移动到顶层
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		errorResponse(c, 400, validationErrorMessage(err))
+		return
+	}
+
+	updates := make(map[string]interface{})
+	if req.DisplayName != nil {
+		updates["display_name"] = *req.DisplayName
+	}
+	if req.Description != nil {
+		updates["description"] = *req.Description
+	}
+	if req.ParentID != nil {
+		if *req.ParentID == 0 {
+			updates["parent_id"] = nil
+		} else {
+			var parent UserGroup
+			if err := db.First(&parent, *req.ParentID).Error; err != nil {
+				errorResponse(c, 400, "上级用户组不存在")
+				return
+			}
+			// 防止形成循环嵌套
+			if groupParentCreatesCycle(group.ID, parent.ID) {
+				errorResponse(c, 400, "不能将用户组移动到自身或其下级组中")
+				return
+			}
+			updates["parent_id"] = parent.ID
+		}
+	}
+
+	if len(updates) > 0 {
+		if err := auditDB(c).Model(&group).Updates(updates).Error; err != nil {
+			errorResponse(c, 500, "更新用户组失败")
+			return
+		}
+	}
+
+	db.Preload("Roles").First(&group, group.ID)
+	successResponse(c, group)
+}
+
+// 删除用户组
+func deleteUserGroup(c *gin.Context) {
+	id := c.Param("id")
+	var group UserGroup
+	if err := db.First(&group, id).Error; err != nil {
+		errorResponse(c, 404, "用户组不存在")
+		return
+	}
+
+	// 存在下级组时不允许删除
+	var childCount int64
+	db.Model(&UserGroup{}).Where("parent_id = ?", group.ID).Count(&childCount)
+	if childCount > 0 {
+		errorResponse(c, 400, "请先删除或移动下级用户组")
+		return
+	}
+
+	if err := auditDB(c).Delete(&group).Error; err != nil {
+		errorResponse(c, 500, "删除用户组失败")
+		return
+	}
+
+	successResponse(c, gin.H{"message": "用户组删除成功"})
+}
+
+// 获取用户组成员（recursive=true 时包含下级组成员）
+func getUserGroupMembers(c *gin.Context) {
+	id := c.Param("id")
+	var group UserGroup
+	if err := db.First(&group, id).Error; err != nil {
+		errorResponse(c, 404, "用户组不存在")
+		return
+	}
+
+	groupIDs := []uint{group.ID}
+	if c.Query("recursive") == "true" {
+		groupIDs = getGroupDescendantIDs(group.ID)
+	}
+
+	var users []User
+	result := db.Where("id IN (?)", db.Table("user_group_members").Select("user_id").Where("user_group_id IN ?", groupIDs)).
+		Order("id").
+		Find(&users)
+	if result.Error != nil {
+		errorResponse(c, 500, "获取用户组成员失败")
+		return
+	}
+
+	successResponse(c, gin.H{
+		"members": users,
+		"total":   len(users),
+	})
+}
+
+// 添加用户组成员
+func addUserGroupMembers(c *gin.Context) {
+	id := c.Param("id")
+	var group UserGroup
+	if err := db.First(&group, id).Error; err != nil {
+		errorResponse(c, 404, "用户组不存在")
+		return
+	}
+
+	var req struct {
+		UserIDs []uint `json:"user_ids" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		errorResponse(c, 400, "请求参数错误")
+		return
+	}
+
+	var users []User
+	db.Where("id IN ?", req.UserIDs).Find(&users)
+	if len(users) == 0 {
+		errorResponse(c, 400, "用户不存在")
+		return
+	}
+
+	oldMembers := userGroupMemberNames(group.ID)
+	// 关联写入会连带 upsert 用户记录，不经过审计钩子，成员变更单独记录
+	if err := db.Model(&group).Association("Members").Append(&users); err != nil {
+		errorResponse(c, 500, "添加成员失败")
+		return
+	}
+	recordAuditChange(c, "user_groups", group.ID, "members", oldMembers, userGroupMemberNames(group.ID))
+
+	successResponse(c, gin.H{
+		"message": "成员添加成功",
+		"added":   len(users),
+	})
+}
+
+// 移除用户组成员
+func removeUserGroupMember(c *gin.Context) {
+	id := c.Param("id")
+	var group UserGroup
+	if err := db.First(&group, id).Error; err != nil {
+		errorResponse(c, 404, "用户组不存在")
+		return
+	}
+
+	userID, err := strconv.ParseUint(c.Param("user_id"), 10, 32)
+	if err != nil {
+		errorResponse(c, 400, "用户ID格式错误")
+		return
+	}
+
+	oldMembers := userGroupMemberNames(group.ID)
+	result := db.Exec("DELETE FROM user_group_members WHERE user_group_id = ? AND user_id = ?", group.ID, userID)
+	if result.Error != nil {
+		errorResponse(c, 500, "移除成员失败")
+		return
+	}
+	if result.RowsAffected == 0 {
+		errorResponse(c, 404, "该用户不是用户组成员")
+		return
+	}
+	recordAuditChange(c, "user_groups", group.ID, "members", oldMembers, userGroupMemberNames(group.ID))
+
+	successResponse(c, gin.H{"message": "成员移除成功"})
+}
+
+// 分配用户组角色（组内所有成员及下级组成员继承这些角色）
+func assignUserGroupRoles(c *gin.Context) {
+	id := c.Param("id")
+	var group UserGroup
+	if err := db.First(&group, id).Error; err != nil {
+		errorResponse(c, 404, "用户组不存在")
+		return
+	}
+
+	var req struct {
+		RoleIDs []uint `json:"role_ids"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		errorResponse(c, 400, "请求参数错误")
+		return
+	}
+
+	var roles []Role
+	if len(req.RoleIDs) > 0 {
+		db.Where("id IN ?", req.RoleIDs).Find(&roles)
+		if len(roles) != len(req.RoleIDs) {
+			errorResponse(c, 400, "角色不存在")
+			return
+		}
+	}
+
+	oldRoles := userGroupRoleNames(group.ID)
+	if err := db.Model(&group).Association("Roles").Replace(roles); err != nil {
+		errorResponse(c, 500, "角色分配失败")
+		return
+	}
+	recordAuditChange(c, "user_groups", group.ID, "roles", oldRoles, userGroupRoleNames(group.ID))
+
+	db.Preload("Roles").First(&group, group.ID)
+	successResponse(c, gin.H{
+		"message": "角色分配成功",
+		"group":   group,
+	})
+}
+
+// 获取用户所属的用户组
+func getUserGroupsOfUser(c *gin.Context) {
+	userID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		errorResponse(c, 400, "用户ID格式错误")
+		return
+	}
+
+	var groups []UserGroup
+	groupIDs := getUserGroupIDs(uint(userID))
+	if len(groupIDs) > 0 {
+		db.Preload("Roles").Where("id IN ?", groupIDs).Find(&groups)
+	}
+
+	successResponse(c, gin.H{
+		"groups": groups,
+		"total":  len(groups),
+	})
+}
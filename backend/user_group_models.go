@@ -0,0 +1,137 @@
+package main
+
+import (
+	"gorm.io/gorm"
+)
+
+// 用户组模型（独立于角色，用于按人群进行通知、文件共享和角色分配）
+type UserGroup struct {
+	ID          uint        `json:"id" gorm:"primaryKey"`
+	Name        string      `json:"name" gorm:"not null;uniqueIndex:idx_user_groups_name_active,where:deleted_at IS NULL"`
+	DisplayName string      `json:"display_name" gorm:"not null"`
+	Description string      `json:"description"`
+	ParentID    *uint       `json:"parent_id" gorm:"index"` // 上级用户组，子组成员同时属于上级组
+	Members     []User      `json:"members,omitempty" gorm:"many2many:user_group_members;"`
+	Roles       []Role      `json:"roles,omitempty" gorm:"many2many:user_group_roles;"`
+	Children    []UserGroup `json:"children,omitempty" gorm:"foreignKey:ParentID"`
+	gorm.Model
+}
+
+// 初始化用户组系统
+func initUserGroupSystem() error {
+	// 自动迁移数据库
+	return db.AutoMigrate(&UserGroup{})
+}
+
+// 获取用户所属的用户组ID（包含直接所属组的所有上级组）
+func getUserGroupIDs(userID uint) []uint {
+	var directIDs []uint
+	db.Table("user_group_members").
+		Select("user_group_members.user_group_id").
+		Joins("JOIN user_groups ON user_groups.id = user_group_members.user_group_id").
+		Where("user_group_members.user_id = ? AND user_groups.deleted_at IS NULL", userID).
+		Pluck("user_group_members.user_group_id", &directIDs)
+
+	return getGroupAncestorIDs(directIDs)
+}
+
+// 获取用户组及其所有上级组的ID
+func getGroupAncestorIDs(groupIDs []uint) []uint {
+	visited := make(map[uint]bool)
+	result := make([]uint, 0, len(groupIDs))
+	current := groupIDs
+
+	for len(current) > 0 {
+		next := []uint{}
+		for _, id := range current {
+			if visited[id] {
+				continue
+			}
+			visited[id] = true
+			result = append(result, id)
+		}
+
+		var parentIDs []uint
+		db.Model(&UserGroup{}).
+			Where("id IN ? AND parent_id IS NOT NULL", current).
+			Pluck("parent_id", &parentIDs)
+		for _, id := range parentIDs {
+			if !visited[id] {
+				next = append(next, id)
+			}
+		}
+		current = next
+	}
+
+	return result
+}
+
+// 用户组成员的用户名（按名称排序，用于记录成员变更）
+func userGroupMemberNames(groupID uint) []string {
+	names := []string{}
+	db.Table("users").
+		Joins("JOIN user_group_members ON user_group_members.user_id = users.id").
+		Where("user_group_members.user_group_id = ?", groupID).
+		Order("users.username").
+		Pluck("users.username", &names)
+	return names
+}
+
+// 用户组直接分配的角色名（按名称排序，用于记录角色变更）
+func userGroupRoleNames(groupID uint) []string {
+	names := []string{}
+	db.Table("roles").
+		Joins("JOIN user_group_roles ON user_group_roles.role_id = roles.id").
+		Where("user_group_roles.user_group_id = ?", groupID).
+		Order("roles.name").
+		Pluck("roles.name", &names)
+	return names
+}
+
+// 将 parentID 设为用户组的上级组是否会形成循环（上级组是自身或其下级组）
+func groupParentCreatesCycle(groupID, parentID uint) bool {
+	for _, ancestorID := range getGroupAncestorIDs([]uint{parentID}) {
+		if ancestorID == groupID {
+			return true
+		}
+	}
+	return false
+}
+
+// 获取用户组及其所有下级组的ID
+func getGroupDescendantIDs(groupID uint) []uint {
+	visited := map[uint]bool{groupID: true}
+	result := []uint{groupID}
+	current := []uint{groupID}
+
+	for len(current) > 0 {
+		var childIDs []uint
+		db.Model(&UserGroup{}).Where("parent_id IN ?", current).Pluck("id", &childIDs)
+
+		next := []uint{}
+		for _, id := range childIDs {
+			if !visited[id] {
+				visited[id] = true
+				result = append(result, id)
+				next = append(next, id)
+			}
+		}
+		current = next
+	}
+
+	return result
+}
+
+// 获取用户的所有有效角色ID（直接分配的角色和通过用户组继承的角色）
+func getUserRoleIDs(userID uint) []uint {
+	var roleIDs []uint
+	db.Table("user_roles").Where("user_id = ?", userID).Pluck("role_id", &roleIDs)
+
+	if groupIDs := getUserGroupIDs(userID); len(groupIDs) > 0 {
+		var groupRoleIDs []uint
+		db.Table("user_group_roles").Where("user_group_id IN ?", groupIDs).Pluck("role_id", &groupRoleIDs)
+		roleIDs = append(roleIDs, groupRoleIDs...)
+	}
+
+	return roleIDs
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// 变更用户状态（管理员）
+func changeUserStateAPI(c *gin.Context) {
+	id := c.Param("id")
+	var user User
+	if err := db.First(&user, id).Error; err != nil {
+		errorResponse(c, 404, "用户不存在")
+		return
+	}
+
+	var req struct {
+		State  string     `json:"state" binding:"required"`
+		Reason string     `json:"reason" binding:"max=200"`
+		Until  *time.Time `json:"until"` // 停用截止时间，为空表示无限期
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		errorResponse(c, 400, validationErrorMessage(err))
+		return
+	}
+
+	if !containsSlice(manualUserStates, req.State) {
+		errorResponse(c, 400, "不支持的用户状态: "+req.State)
+		return
+	}
+	if req.State == UserStateSuspended && req.Reason == "" {
+		errorResponse(c, 400, "停用账户必须填写原因")
+		return
+	}
+	if req.Until != nil && req.State != UserStateSuspended {
+		errorResponse(c, 400, "只有停用状态可以设置截止时间")
+		return
+	}
+	if req.Until != nil && !req.Until.After(time.Now()) {
+		errorResponse(c, 400, "截止时间必须晚于当前时间")
+		return
+	}
+
+	operatorID, _ := c.Get("user_id")
+	operatorName, _ := c.Get("username")
+	if operatorID.(uint) == user.ID && req.State != UserStateActive {
+		errorResponse(c, 400, "不能变更当前登录用户的状态")
+		return
+	}
+
+	err := auditDB(c).Transaction(func(tx *gorm.DB) error {
+		return changeUserState(tx, &user, req.State, req.Reason, req.Until, operatorID.(uint), operatorName.(string))
+	})
+	authUsers.invalidate(user.ID)
+	if err != nil {
+		errorResponse(c, 500, "变更用户状态失败")
+		return
+	}
+
+	user.Password = ""
+	setVersionETag(c, user.Version)
+	successResponse(c, user)
+}
+
+// 获取用户状态变更历史
+func getUserStateHistory(c *gin.Context) {
+	id := c.Param("id")
+	var user User
+	if err := db.First(&user, id).Error; err != nil {
+		errorResponse(c, 404, "用户不存在")
+		return
+	}
+
+	var history []UserStatusHistory
+	result := db.Where("user_id = ?", user.ID).Order("created_at DESC, id DESC").Find(&history)
+	if result.Error != nil {
+		errorResponse(c, 500, "获取状态历史失败")
+		return
+	}
+
+	successResponse(c, gin.H{
+		"state":   user.State,
+		"history": history,
+		"total":   len(history),
+	})
+}
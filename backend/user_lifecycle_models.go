@@ -0,0 +1,207 @@
+package main
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// 用户生命周期状态
+const (
+	UserStatePending   = "pending"   // 待激活
+	UserStateActive    = "active"    // 正常
+	UserStateLocked    = "locked"    // 登录失败次数过多被自动锁定
+	UserStateSuspended = "suspended" // 被管理员停用
+	UserStateExpired   = "expired"   // 账户已过期
+	UserStateArchived  = "archived"  // 已归档
+)
+
+// 管理员可以手动设置的状态（locked 和 expired 由系统自动进入）
+var manualUserStates = []string{UserStatePending, UserStateActive, UserStateSuspended, UserStateArchived}
+
+// 用户状态变更历史
+type UserStatusHistory struct {
+	ID           uint       `json:"id" gorm:"primaryKey"`
+	UserID       uint       `json:"user_id" gorm:"not null;index"`
+	FromState    string     `json:"from_state"`
+	ToState      string     `json:"to_state" gorm:"not null"`
+	Reason       string     `json:"reason"`
+	Until        *time.Time `json:"until"`                               // 锁定/停用的截止时间
+	OperatorID   uint       `json:"operator_id"`                         // 操作人ID，0表示系统自动变更
+	OperatorName string     `json:"operator_name" gorm:"default:system"` // 操作人用户名
+	CreatedAt    time.Time  `json:"created_at"`
+}
+
+// 初始化用户生命周期
+func initUserLifecycle() error {
+	// 自动迁移数据库
+	if err := db.AutoMigrate(&UserStatusHistory{}); err != nil {
+		return err
+	}
+
+	// 兼容旧数据：仅有 status=false 的用户视为已停用
+	return db.Model(&User{}).
+		Where("status = ? AND state = ?", false, UserStateActive).
+		Updates(map[string]interface{}{"state": UserStateSuspended, "suspended_reason": "旧版本禁用"}).Error
+}
+
+// 生成状态变更需要更新的字段（status 与 state 保持同步）
+func userStateUpdates(state, reason string, until *time.Time) map[string]interface{} {
+	updates := map[string]interface{}{
+		"state":  state,
+		"status": state == UserStateActive,
+	}
+	switch state {
+	case UserStateActive:
+		updates["failed_login_count"] = 0
+		updates["locked_until"] = nil
+		updates["suspended_reason"] = ""
+		updates["suspended_until"] = nil
+	case UserStateLocked:
+		updates["locked_until"] = until
+	case UserStateSuspended:
+		updates["suspended_reason"] = reason
+		updates["suspended_until"] = until
+	}
+	return updates
+}
+
+// 记录状态变更历史
+func recordUserStateHistory(tx *gorm.DB, userID uint, from, to, reason string, until *time.Time, operatorID uint, operatorName string) error {
+	if operatorName == "" {
+		operatorName = "system"
+	}
+	return tx.Create(&UserStatusHistory{
+		UserID:       userID,
+		FromState:    from,
+		ToState:      to,
+		Reason:       reason,
+		Until:        until,
+		OperatorID:   operatorID,
+		OperatorName: operatorName,
+		CreatedAt:    time.Now(),
+	}).Error
+}
+
+// 变更用户状态并记录历史（调用方在事务提交后调用 authUsers.invalidate，避免并发请求缓存旧状态）
+func changeUserState(tx *gorm.DB, user *User, state, reason string, until *time.Time, operatorID uint, operatorName string) error {
+	from := user.State
+	updates := userStateUpdates(state, reason, until)
+	updates["version"] = gorm.Expr("version + 1")
+	if err := tx.Model(&User{}).Where("id = ?", user.ID).Updates(updates).Error; err != nil {
+		return err
+	}
+	if err := recordUserStateHistory(tx, user.ID, from, state, reason, until, operatorID, operatorName); err != nil {
+		return err
+	}
+	return tx.First(user, user.ID).Error
+}
+
+// 检查用户是否有到期的状态变更（锁定到期、停用到期、账户过期）
+func dueUserStateTransition(user *User, now time.Time) (string, string) {
+	switch user.State {
+	case UserStateLocked:
+		if user.LockedUntil != nil && !user.LockedUntil.After(now) {
+			return UserStateActive, "锁定到期自动解锁"
+		}
+	case UserStateSuspended:
+		if user.SuspendedUntil != nil && !user.SuspendedUntil.After(now) {
+			return UserStateActive, "停用到期自动恢复"
+		}
+		return "", ""
+	}
+	if (user.State == UserStateActive || user.State == UserStateLocked) && user.ExpiresAt != nil && !user.ExpiresAt.After(now) {
+		return UserStateExpired, "账户到期"
+	}
+	return "", ""
+}
+
+// 立即应用单个用户到期的状态变更（登录时调用）
+func refreshUserState(user *User) {
+	if state, reason := dueUserStateTransition(user, time.Now()); state != "" {
+		if err := changeUserState(db, user, state, reason, nil, 0, ""); err != nil {
+			appLogger.Error("failed to refresh user state", "user_id", user.ID, "error", err)
+		}
+		authUsers.invalidate(user.ID)
+	}
+}
+
+// 记录一次登录失败，超过最大尝试次数时自动锁定，返回锁定截止时间
+func recordFailedLogin(user *User) *time.Time {
+	maxAttempts := configCache.GetInt("max_login_attempts", 5)
+	if maxAttempts <= 0 {
+		maxAttempts = 5
+	}
+	lockDuration := configCache.GetDuration("login_lock_minutes", time.Minute, 30*time.Minute)
+	if lockDuration <= 0 {
+		lockDuration = 30 * time.Minute
+	}
+
+	// 原子地增加失败次数并取回新值，并发的失败登录不会互相覆盖
+	err := db.Model(user).
+		Clauses(clause.Returning{Columns: []clause.Column{{Name: "failed_login_count"}}}).
+		UpdateColumn("failed_login_count", gorm.Expr("failed_login_count + ?", 1)).Error
+	if err != nil {
+		appLogger.Error("failed to record failed login", "user_id", user.ID, "error", err)
+		return nil
+	}
+	count := user.FailedLoginCount
+	if count < maxAttempts || user.State != UserStateActive {
+		return nil
+	}
+
+	// 仅从正常状态锁定，并发的失败登录只锁定一次
+	until := time.Now().Add(lockDuration)
+	reason := strconv.Itoa(count) + " 次登录失败自动锁定"
+	err = db.Transaction(func(tx *gorm.DB) error {
+		updates := userStateUpdates(UserStateLocked, reason, &until)
+		updates["version"] = gorm.Expr("version + 1")
+		result := tx.Model(&User{}).Where("id = ? AND state = ?", user.ID, UserStateActive).Updates(updates)
+		if result.Error != nil || result.RowsAffected == 0 {
+			return result.Error
+		}
+		return recordUserStateHistory(tx, user.ID, UserStateActive, UserStateLocked, reason, &until, 0, "")
+	})
+	if err != nil {
+		appLogger.Error("failed to lock user", "user_id", user.ID, "error", err)
+		return nil
+	}
+	authUsers.invalidate(user.ID)
+	if err := db.First(user, user.ID).Error; err != nil || user.State != UserStateLocked {
+		return nil
+	}
+	return user.LockedUntil
+}
+
+// 处理所有到期的状态变更
+func processScheduledUserTransitions(ctx context.Context) {
+	now := time.Now()
+	var users []User
+	db.Where("(state = ? AND locked_until <= ?) OR (state = ? AND suspended_until <= ?) OR (state IN ? AND expires_at <= ?)",
+		UserStateLocked, now,
+		UserStateSuspended, now,
+		[]string{UserStateActive, UserStateLocked}, now).
+		Find(&users)
+
+	for i := range users {
+		if ctx.Err() != nil {
+			return
+		}
+		state, reason := dueUserStateTransition(&users[i], now)
+		if state == "" {
+			continue
+		}
+		if err := changeUserState(db, &users[i], state, reason, nil, 0, ""); err != nil {
+			appLogger.Error("failed to transition user state", "user_id", users[i].ID, "state", state, "error", err)
+		}
+		authUsers.invalidate(users[i].ID)
+	}
+}
+
+// 启动用户状态定时任务
+func startUserLifecycleJob(interval time.Duration) {
+	jobRunner.Every("user_lifecycle", interval, processScheduledUserTransitions)
+}
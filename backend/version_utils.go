@@ -0,0 +1,86 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+	"gorm.io/gorm"
+)
+
+// 乐观锁版本冲突
+var errVersionConflict = errors.New("version conflict")
+
+// 字段校验规则对应的提示信息
+var validationMessages = map[string]string{
+	"required": "不能为空",
+	"email":    "邮箱格式错误",
+	"min":      "长度过短",
+	"max":      "长度过长",
+	"oneof":    "取值无效",
+}
+
+// 将请求绑定错误转换为字段级提示信息
+func validationErrorMessage(err error) string {
+	var errs validator.ValidationErrors
+	if !errors.As(err, &errs) || len(errs) == 0 {
+		return "请求参数错误"
+	}
+
+	messages := make([]string, 0, len(errs))
+	for _, fe := range errs {
+		msg, ok := validationMessages[fe.Tag()]
+		if !ok {
+			msg = "校验失败(" + fe.Tag() + ")"
+		}
+		messages = append(messages, fmt.Sprintf("字段 %s %s", jsonFieldName(fe), msg))
+	}
+	return "请求参数错误: " + strings.Join(messages, "; ")
+}
+
+// 将结构体字段名转换为JSON字段名（如 RealName -> real_name）
+func jsonFieldName(fe validator.FieldError) string {
+	name := fe.Field()
+	var b strings.Builder
+	for i, r := range name {
+		if r >= 'A' && r <= 'Z' {
+			if i > 0 {
+				b.WriteByte('_')
+			}
+			r += 'a' - 'A'
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// 递增版本号：修改关联（角色、权限）等不经过乐观锁检查的写入，也要使客户端持有的旧版本失效
+func bumpVersion(tx *gorm.DB, model interface{}, ids ...uint) error {
+	return tx.Model(model).Where("id IN ?", ids).Update("version", gorm.Expr("version + 1")).Error
+}
+
+// 设置资源版本的ETag响应头
+func setVersionETag(c *gin.Context, version uint) {
+	c.Header("ETag", fmt.Sprintf("\"%d\"", version))
+}
+
+// 获取客户端期望的资源版本：优先使用If-Match请求头，其次使用请求体中的version
+// 返回0表示客户端未指定版本（不做并发检查）
+func expectedVersion(c *gin.Context, bodyVersion *uint) (uint, error) {
+	ifMatch := strings.TrimSpace(c.GetHeader("If-Match"))
+	if ifMatch != "" && ifMatch != "*" {
+		tag := strings.Trim(strings.TrimPrefix(ifMatch, "W/"), "\"")
+		version, err := strconv.ParseUint(tag, 10, 32)
+		if err != nil {
+			return 0, errors.New("If-Match 格式错误")
+		}
+		return uint(version), nil
+	}
+	if bodyVersion != nil {
+		return *bodyVersion, nil
+	}
+	return 0, nil
+}